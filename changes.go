@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+const maxJournalChanges = 1000
+
+// taskChanges journals the changes of the pending tasks for incremental
+// polling. Tokens embed the journal epoch so they expire on restart.
+var taskChanges = &journal{epoch: time.Now().UnixNano()}
+
+type journal struct {
+	mux     sync.Mutex
+	epoch   int64
+	seq     int64
+	changes []*model.TaskChange
+}
+
+func (j *journal) record(changeType string, tk *model.Task) {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	j.seq++
+	j.changes = append(j.changes, &model.TaskChange{ChangeType: changeType, Task: tk, At: time.Now().UTC()})
+	if len(j.changes) > maxJournalChanges {
+		j.changes = j.changes[len(j.changes)-maxJournalChanges:]
+	}
+}
+
+func (j *journal) token() string {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	return j.tokenAt(j.seq)
+}
+
+func (j *journal) tokenAt(seq int64) string {
+	return fmt.Sprintf("%d-%d", j.epoch, seq)
+}
+
+// since returns the changes following the token, false when the token was
+// not issued by this journal or its changes were dropped.
+func (j *journal) since(token string) ([]*model.TaskChange, string, bool) {
+	splits := strings.SplitN(token, "-", 2)
+	if len(splits) != 2 {
+		return nil, "", false
+	}
+	epoch, err := strconv.ParseInt(splits[0], 10, 64)
+	if err != nil {
+		return nil, "", false
+	}
+	seq, err := strconv.ParseInt(splits[1], 10, 64)
+	if err != nil {
+		return nil, "", false
+	}
+
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	first := j.seq - int64(len(j.changes))
+	if epoch != j.epoch || seq < first || seq > j.seq {
+		return nil, "", false
+	}
+	return append([]*model.TaskChange{}, j.changes[seq-first:]...), j.tokenAt(j.seq), true
+}
+
+// journaledStore records the changes of the pending tasks in taskChanges.
+// Rescheduled tasks get new IDs and are journaled as created then deleted.
+type journaledStore struct {
+	store
+}
+
+func (s *journaledStore) Create(tk *model.Task) error {
+	_, err := s.store.GetTask(tk.AsFilename())
+	exists := err == nil
+	if err := s.store.Create(tk); err != nil {
+		return err
+	}
+	s.recordPending(tk.AsFilename(), exists)
+	return nil
+}
+
+func (s *journaledStore) SaveCallbacks(tk *model.Task) error {
+	if err := s.store.SaveCallbacks(tk); err != nil {
+		return err
+	}
+	s.recordPending(tk.AsFilename(), true)
+	return nil
+}
+
+func (s *journaledStore) Remove(id string) error {
+	if err := s.store.Remove(id); err != nil {
+		return err
+	}
+	taskChanges.record(model.ChangeDeleted, &model.Task{ID: id})
+	return nil
+}
+
+// MarkAsFailed removes the task from the pending ones.
+func (s *journaledStore) MarkAsFailed(id string) error {
+	if err := s.store.MarkAsFailed(id); err != nil {
+		return err
+	}
+	taskChanges.record(model.ChangeDeleted, &model.Task{ID: id})
+	return nil
+}
+
+func (s *journaledStore) PurgeExpired(before time.Time) (int, []string, error) {
+	count, removed, err := s.store.PurgeExpired(before)
+	for _, id := range removed {
+		taskChanges.record(model.ChangeDeleted, &model.Task{ID: id})
+	}
+	return count, removed, err
+}
+
+func (s *journaledStore) PatchTags(id string, patch model.TagPatch) error {
+	if err := s.store.PatchTags(id, patch); err != nil {
+		return err
+	}
+	if tk, err := s.store.GetTask(id); err == nil && tk.Status == model.StatusPending {
+		s.recordPending(id, true)
+	}
+	return nil
+}
+
+func (s *journaledStore) ReplaceDependency(oldID, newID string) ([]string, error) {
+	replaced, err := s.store.ReplaceDependency(oldID, newID)
+	for _, id := range replaced {
+		s.recordPending(id, true)
+	}
+	return replaced, err
+}
+
+func (s *journaledStore) recordPending(id string, exists bool) {
+	tk, err := s.store.GetTask(id)
+	if err != nil {
+		log.Printf("cannot journal change of task %s: %s", id, err)
+		return
+	}
+	// secrets are not kept in memory
+	tk.SecretEnv = nil
+	if exists {
+		taskChanges.record(model.ChangeUpdated, tk)
+	} else {
+		taskChanges.record(model.ChangeCreated, tk)
+	}
+}
+
+// listChanges returns the changes since the 'since' param token, or all
+// pending tasks as created without token.
+func listChanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "invalid method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	res := &model.TaskChanges{Changes: []*model.TaskChange{}}
+	if since := r.FormValue("since"); since != "" {
+		var ok bool
+		if res.Changes, res.Token, ok = taskChanges.since(since); !ok {
+			jsonError(w, "TOKEN_EXPIRED", fmt.Sprintf("token '%s' expired", since), http.StatusGone)
+			return
+		}
+	} else {
+		// taken first so that concurrent changes are polled again
+		res.Token = taskChanges.token()
+		tasks, err := taskStore.GetTasks()
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		now := time.Now().UTC()
+		for _, tk := range tasks {
+			res.Changes = append(res.Changes, &model.TaskChange{ChangeType: model.ChangeCreated, Task: tk, At: now})
+		}
+	}
+
+	b, err := json.Marshal(res)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// minAdaptiveListTimeout is the floor of adaptive list timeouts when min is
+// not set, so that the first listing or an empty one is not cancelled at once.
+const minAdaptiveListTimeout = 1 * time.Second
+
+// WithAdaptiveListTimeout bounds list requests by perTaskMs milliseconds per
+// task of the last listing, clamped between min and max, instead of the
+// client timeout. A min of zero or less defaults to 1 second.
+func WithAdaptiveListTimeout(perTaskMs float64, min, max time.Duration) ClientOption {
+	return func(c *Client) {
+		c.listTimeout = &adaptiveTimeout{perTaskMs: perTaskMs, min: min, max: max}
+	}
+}
+
+type adaptiveTimeout struct {
+	perTaskMs float64
+	min, max  time.Duration
+	lastCount int64
+}
+
+func (a *adaptiveTimeout) timeout() time.Duration {
+	d := time.Duration(a.perTaskMs * float64(atomic.LoadInt64(&a.lastCount)) * float64(time.Millisecond))
+	min := a.min
+	if min <= 0 {
+		min = minAdaptiveListTimeout
+	}
+	if d < min {
+		d = min
+	}
+	if a.max > 0 && d > a.max {
+		return a.max
+	}
+	return d
+}
+
+func (a *adaptiveTimeout) record(count int) {
+	atomic.StoreInt64(&a.lastCount, int64(count))
+}
+
+func (a *adaptiveTimeout) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, a.timeout())
+}
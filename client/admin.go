@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var compactPollInterval = time.Second
+
+var ErrAdminTokenRequired = errors.New("scheduler requires an admin token")
+
+type CompactResult struct {
+	TasksBefore, TasksAfter int
+	FreedBytes              int64
+	Duration                time.Duration
+}
+
+// CompactRegion has the scheduler compact the storage of the region tasks.
+// This admin operation needs the client token to be an admin one. The
+// scheduler status is polled until a compaction in progress completes, ctx
+// bounding the wait.
+func (c *Client) CompactRegion(ctx context.Context, region string) (*CompactResult, error) {
+	if region == "" {
+		return nil, errors.New("missing compact region")
+	}
+	addr := c.serviceURL()
+	addr.Path = "admin/compact"
+	query := addr.Query()
+	query.Set("region", region)
+	addr.RawQuery = query.Encode()
+
+	res, err := c.compactRequest(ctx, http.MethodPost, addr.String())
+	for err == nil && res == nil {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(compactPollInterval):
+		}
+		status := c.serviceURL()
+		status.Path = "admin/compact/status"
+		res, err = c.compactRequest(ctx, http.MethodGet, status.String())
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.cache.invalidateTasks()
+	return res, nil
+}
+
+// compactRequest returns a nil result while the compaction is in progress.
+func (c *Client) compactRequest(ctx context.Context, method, addr string) (*CompactResult, error) {
+	req, err := http.NewRequest(method, addr, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+		return nil, nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, ErrAdminTokenRequired
+	}
+	if err = notOKStatus(addr, resp); err != nil {
+		return nil, err
+	}
+
+	res := &CompactResult{}
+	if err = json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return nil, fmt.Errorf("cannot decode compact result from '%s': %s", addr, err)
+	}
+	return res, nil
+}
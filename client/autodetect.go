@@ -0,0 +1,82 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+const (
+	DefaultSocketPath   = "/tmp/awless-scheduler.sock"
+	SocketPathEnv       = "AWLESS_SCHEDULER_SOCK"
+	DefaultDiscoveryURL = "http://localhost:8082"
+
+	socketDetectTimeout = 500 * time.Millisecond
+)
+
+var (
+	ErrNoSocketEnv    = errors.New(SocketPathEnv + " is not set")
+	ErrSocketNotFound = errors.New("scheduler unix socket not found")
+)
+
+// WithDefaultSocketPath overrides the socket location checked by NewAutoDetect.
+func WithDefaultSocketPath(path string) ClientOption {
+	return func(c *Client) {
+		c.socketPath = path
+	}
+}
+
+// NewAutoDetect connects through the local scheduler unix socket when one
+// accepts connections, falling back to discovery at DefaultDiscoveryURL.
+// The socket path is taken from WithDefaultSocketPath, then SocketPathEnv,
+// then DefaultSocketPath.
+func NewAutoDetect(opts ...ClientOption) (*Client, error) {
+	path := probeOptions(opts).socketPath
+	if path == "" {
+		path = os.Getenv(SocketPathEnv)
+	}
+	if path == "" {
+		path = DefaultSocketPath
+	}
+
+	if socketConnectable(path) {
+		return NewFromServiceInfo(model.ServiceInfo{ServiceAddr: path, UnixSockMode: true}, opts...)
+	}
+	return New(DefaultDiscoveryURL, opts...)
+}
+
+// NewUnixSockFromEnv connects through the scheduler unix socket at
+// SocketPathEnv, without discovery.
+func NewUnixSockFromEnv(opts ...ClientOption) (*Client, error) {
+	path := os.Getenv(SocketPathEnv)
+	if path == "" {
+		return nil, ErrNoSocketEnv
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, ErrSocketNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return nil, fmt.Errorf("'%s' is not a unix socket", path)
+	}
+	return NewFromServiceInfo(model.ServiceInfo{ServiceAddr: path, UnixSockMode: true}, opts...)
+}
+
+func socketConnectable(path string) bool {
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+	conn, err := net.DialTimeout("unix", path, socketDetectTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
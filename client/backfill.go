@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type BackfillOptions struct {
+	Region string
+	// MaxOverdueDuration skips tasks overdue for longer, none if zero.
+	MaxOverdueDuration time.Duration
+}
+
+// BackfillMissed triggers at once the overdue tasks, typically missed while
+// the scheduler was down, and returns how many were backfilled. Tasks that
+// could not be backfilled are reported by ID in a *MultiError.
+func (c *Client) BackfillMissed(ctx context.Context, opts BackfillOptions) (int, error) {
+	tasks, err := c.ListOverdue(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	failures := make(map[string]error)
+	for _, tk := range tasks {
+		if opts.Region != "" && tk.Region != opts.Region {
+			continue
+		}
+		if overdue := tk.OverdueDuration(); opts.MaxOverdueDuration > 0 && overdue > opts.MaxOverdueDuration {
+			log.Printf("[INFO] SKIP_STALE task '%s' overdue by %s", tk.ID, overdue.Truncate(time.Second))
+			continue
+		}
+		switch _, err := c.backfill(ctx, tk.ID); err {
+		case nil:
+			count++
+		case ErrNotFound:
+			// executed or deleted meanwhile
+		default:
+			failures[tk.ID] = err
+		}
+	}
+	if len(failures) > 0 {
+		return count, &MultiError{Errors: failures}
+	}
+	return count, nil
+}
+
+// backfill returns the new id of the task triggered at once.
+func (c *Client) backfill(ctx context.Context, taskID string) (string, error) {
+	addr := c.serviceURL()
+	addr.Path = "tasks/" + taskID + "/backfill"
+
+	req, err := http.NewRequest(http.MethodPut, addr.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	c.cache.invalidateTasks()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", ErrNotFound
+	}
+	if err = notOKStatus(addr.String(), resp); err != nil {
+		return "", sentinel(err)
+	}
+
+	id, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("cannot read backfilled task id from '%s': %s", addr.String(), err)
+	}
+	return strings.TrimSpace(string(id)), nil
+}
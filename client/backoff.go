@@ -0,0 +1,80 @@
+package client
+
+import (
+	"errors"
+	"log"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// ExponentialBackoffConfig shapes the waits between the retries enabled
+// with WithRetry.
+type ExponentialBackoffConfig struct {
+	InitialInterval, MaxInterval time.Duration
+	Multiplier                   float64
+	// RandomizationFactor spreads each wait by up to this ratio around its
+	// value, e.g. 0.2 for waits between 80% and 120%.
+	RandomizationFactor float64
+	// MaxElapsedTime stops retrying once reached since the first attempt,
+	// none if zero.
+	MaxElapsedTime time.Duration
+}
+
+// DefaultBackoffConfig is a sensible base for WithBackoffConfig. Without
+// config, waits start at 100ms and double up to 10s.
+var DefaultBackoffConfig = ExponentialBackoffConfig{
+	InitialInterval:     100 * time.Millisecond,
+	MaxInterval:         30 * time.Second,
+	Multiplier:          1.5,
+	RandomizationFactor: 0.2,
+}
+
+func (cfg ExponentialBackoffConfig) Validate() error {
+	if cfg.Multiplier < 1 {
+		return errors.New("backoff multiplier must be at least 1")
+	}
+	if cfg.InitialInterval <= 0 {
+		return errors.New("backoff initial interval must be positive")
+	}
+	if cfg.MaxInterval < cfg.InitialInterval {
+		return errors.New("backoff max interval must not be less than initial interval")
+	}
+	if cfg.RandomizationFactor < 0 || cfg.RandomizationFactor > 1 {
+		return errors.New("backoff randomization factor must be between 0 and 1")
+	}
+	return nil
+}
+
+// WithBackoffConfig sets the waits between retries. Invalid configs are
+// logged and ignored.
+func WithBackoffConfig(cfg ExponentialBackoffConfig) ClientOption {
+	return func(c *Client) {
+		if err := cfg.Validate(); err != nil {
+			log.Printf("[WARN] backoff config ignored: %s", err)
+			return
+		}
+		c.transport.mux.Lock()
+		defer c.transport.mux.Unlock()
+		c.transport.backoff = &cfg
+	}
+}
+
+// interval is the wait after the given attempt, starting at 0.
+func (cfg *ExponentialBackoffConfig) interval(attempt int) time.Duration {
+	if cfg == nil {
+		return backoff(attempt)
+	}
+	wait := float64(cfg.InitialInterval) * math.Pow(cfg.Multiplier, float64(attempt))
+	if wait > float64(cfg.MaxInterval) {
+		wait = float64(cfg.MaxInterval)
+	}
+	if cfg.RandomizationFactor > 0 {
+		wait *= 1 + cfg.RandomizationFactor*(2*rand.Float64()-1)
+	}
+	return time.Duration(wait)
+}
+
+func (cfg *ExponentialBackoffConfig) elapsed(start time.Time, wait time.Duration) bool {
+	return cfg != nil && cfg.MaxElapsedTime > 0 && time.Since(start)+wait > cfg.MaxElapsedTime
+}
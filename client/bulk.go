@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+type BulkPostError struct {
+	Index int
+	Err   error
+}
+
+func (e *BulkPostError) Error() string {
+	return fmt.Sprintf("form %d: %s", e.Index, e.Err)
+}
+
+// BulkPost validates all forms before posting any, then posts them in order,
+// stopping at the first failure. The ids of each posted form are returned.
+func (c *Client) BulkPost(ctx context.Context, forms []Form) ([][]string, error) {
+	for i, f := range forms {
+		if err := c.validate(f); err != nil {
+			return nil, &BulkPostError{Index: i, Err: err}
+		}
+	}
+
+	var ids [][]string
+	for i, f := range forms {
+		if err := ctx.Err(); err != nil {
+			return ids, &BulkPostError{Index: i, Err: err}
+		}
+		if f.AllRegions {
+			regionIDs, err := c.postAllRegions(ctx, f)
+			if err != nil {
+				return ids, &BulkPostError{Index: i, Err: err}
+			}
+			ids = append(ids, regionIDs)
+			continue
+		}
+		id, err := c.submit(ctx, http.MethodPost, "tasks", f)
+		if err != nil {
+			return ids, &BulkPostError{Index: i, Err: err}
+		}
+		ids = append(ids, []string{id})
+	}
+	return ids, nil
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+// WithCacheTTL caches the decoded responses of task listings, task lookups
+// and regions for the given duration. Creating, rescheduling, updating or
+// deleting a task invalidates cached tasks.
+func WithCacheTTL(d time.Duration) ClientOption {
+	return func(c *Client) {
+		if d > 0 {
+			c.cache = &responseCache{ttl: d, lastCleanup: time.Now(), counters: c.transport.counters}
+		}
+	}
+}
+
+func (c *Client) ClearCache() {
+	c.cache.invalidate("")
+}
+
+type responseCache struct {
+	ttl      time.Duration
+	entries  sync.Map
+	counters *requestCounters
+
+	mux         sync.Mutex
+	lastCleanup time.Time
+}
+
+type cacheEntry struct {
+	path    string
+	value   interface{}
+	expires time.Time
+}
+
+func (rc *responseCache) load(key string) (interface{}, bool) {
+	if rc == nil {
+		return nil, false
+	}
+	v, ok := rc.entries.Load(key)
+	if !ok {
+		return nil, false
+	}
+	entry := v.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		rc.entries.Delete(key)
+		return nil, false
+	}
+	rc.counters.countCacheHit()
+	return entry.value, true
+}
+
+func (rc *responseCache) store(key, path string, v interface{}) {
+	if rc == nil {
+		return
+	}
+	now := time.Now()
+	rc.entries.Store(key, &cacheEntry{path: strings.TrimPrefix(path, "/"), value: v, expires: now.Add(rc.ttl)})
+
+	rc.mux.Lock()
+	defer rc.mux.Unlock()
+	if now.Sub(rc.lastCleanup) < rc.ttl {
+		return
+	}
+	rc.lastCleanup = now
+	rc.entries.Range(func(k, v interface{}) bool {
+		if now.After(v.(*cacheEntry).expires) {
+			rc.entries.Delete(k)
+		}
+		return true
+	})
+}
+
+// taskListings are the paths of the cached task listings and lookups.
+var taskListings = []string{"tasks", "failures", "done"}
+
+// invalidateTasks drops the cached tasks of every status.
+func (rc *responseCache) invalidateTasks() {
+	for _, path := range taskListings {
+		rc.invalidate(path)
+	}
+}
+
+func (rc *responseCache) invalidate(pathPrefix string) {
+	if rc == nil {
+		return
+	}
+	rc.entries.Range(func(k, v interface{}) bool {
+		if strings.HasPrefix(v.(*cacheEntry).path, pathPrefix) {
+			rc.entries.Delete(k)
+		}
+		return true
+	})
+}
+
+func copyTasks(tasks []*model.Task) []*model.Task {
+	if tasks == nil {
+		return nil
+	}
+	copied := make([]*model.Task, len(tasks))
+	for i, tk := range tasks {
+		copied[i] = copyTask(tk)
+	}
+	return copied
+}
+
+func copyTask(tk *model.Task) *model.Task {
+	copied := *tk
+	copied.DependsOn = append([]string(nil), tk.DependsOn...)
+	copied.Tags = copyTags(tk.Tags)
+	return &copied
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	if tags == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(tags))
+	for k, v := range tags {
+		copied[k] = v
+	}
+	return copied
+}
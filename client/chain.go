@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+const chainPlaceholderPrefix = "chain:"
+
+// ChainPlaceholder references the task of the form at the given index of a
+// chain, to be used in Form.DependsOn before the task id is known.
+func ChainPlaceholder(index int) string {
+	return fmt.Sprintf("%s%d", chainPlaceholderPrefix, index)
+}
+
+type FormChain struct {
+	forms []Form
+	order []int
+}
+
+// NewFormChain makes each form depend on the previous one. Forms without a
+// region inherit the region of the previous form.
+func NewFormChain(forms ...Form) (*FormChain, error) {
+	if len(forms) == 0 {
+		return nil, errors.New("empty form chain")
+	}
+
+	chain := &FormChain{}
+	var tasks []*model.Task
+	for i, f := range forms {
+		f.DependsOn = append([]string(nil), f.DependsOn...)
+		if i > 0 {
+			if f.Region == "" && !f.AllRegions {
+				f.Region = chain.forms[i-1].Region
+			}
+			f.DependsOn = append(f.DependsOn, ChainPlaceholder(i-1))
+		}
+		if f.AllRegions {
+			return nil, fmt.Errorf("chain form %d: cannot chain a form in all regions", i)
+		}
+		if err := f.Validate(); err != nil {
+			return nil, fmt.Errorf("chain form %d: %s", i, err)
+		}
+
+		var placeholders []string
+		for _, dep := range f.DependsOn {
+			if !strings.HasPrefix(dep, chainPlaceholderPrefix) {
+				continue
+			}
+			if index, ok := placeholderIndex(dep); !ok || index >= len(forms) {
+				return nil, fmt.Errorf("chain form %d: invalid placeholder '%s'", i, dep)
+			}
+			placeholders = append(placeholders, dep)
+		}
+
+		chain.forms = append(chain.forms, f)
+		tasks = append(tasks, &model.Task{ID: ChainPlaceholder(i), DependsOn: placeholders})
+	}
+
+	ordered, err := NewDependencyGraph(tasks).TopologicalOrder()
+	if err != nil {
+		return nil, err
+	}
+	for _, tk := range ordered {
+		index, _ := placeholderIndex(tk.ID)
+		chain.order = append(chain.order, index)
+	}
+
+	return chain, nil
+}
+
+// Submit posts the forms, replacing placeholders with the ids of the tasks
+// already created. Ids are returned in the order of the chain forms.
+func (fc *FormChain) Submit(ctx context.Context, c *Client) ([]string, error) {
+	ids := make([]string, len(fc.forms))
+	for _, index := range fc.order {
+		f := fc.forms[index]
+		deps := make([]string, len(f.DependsOn))
+		for i, dep := range f.DependsOn {
+			deps[i] = dep
+			if ref, ok := placeholderIndex(dep); ok {
+				deps[i] = ids[ref]
+			}
+		}
+		f.DependsOn = deps
+
+		if err := c.checkSchedule(f); err != nil {
+			return ids, fmt.Errorf("chain form %d: %s", index, err)
+		}
+		id, err := c.submit(ctx, http.MethodPost, "tasks", f)
+		if err != nil {
+			return ids, fmt.Errorf("chain form %d: %s", index, err)
+		}
+		ids[index] = id
+	}
+	return ids, nil
+}
+
+func placeholderIndex(dep string) (int, bool) {
+	if !strings.HasPrefix(dep, chainPlaceholderPrefix) {
+		return 0, false
+	}
+	index, err := strconv.Atoi(strings.TrimPrefix(dep, chainPlaceholderPrefix))
+	if err != nil || index < 0 {
+		return 0, false
+	}
+	return index, true
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+// ListChanged returns the changes of the pending tasks since the token of
+// the previous call, along with the token of the next one. Without token,
+// all pending tasks are returned as created. On ErrTokenExpired, poll again
+// without token.
+func (c *Client) ListChanged(ctx context.Context, sinceToken string) ([]*model.TaskChange, string, error) {
+	addr := c.serviceURL()
+	addr.Path = "changes"
+	if sinceToken != "" {
+		addr.RawQuery = url.Values{"since": {sinceToken}}.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, addr.String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if err = notOKStatus(addr.String(), resp); err != nil {
+		return nil, "", sentinel(err)
+	}
+
+	var res model.TaskChanges
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, "", fmt.Errorf("cannot decode changes from '%s': %s", addr.String(), err)
+	}
+	for _, change := range res.Changes {
+		if change.Task != nil && change.ChangeType != model.ChangeDeleted && change.Task.Status == "" {
+			change.Task.Status = model.StatusPending
+		}
+	}
+	return res.Changes, res.Token, nil
+}
@@ -1,175 +1,687 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/wallix/awless-scheduler/model"
 )
 
 type Client struct {
-	ServiceURL  *url.URL
-	serviceInfo *model.ServiceInfo
-	httpClient  *http.Client
+	ServiceURL       *url.URL
+	serviceInfo      *model.ServiceInfo
+	discoveryURL     string
+	httpClient       *http.Client
+	endpoints        *endpoints
+	transport        *transport
+	waitConcurrency  int
+	reachableTimeout time.Duration
+	cache            *responseCache
+	discoveryRetry   *discoveryRetry
+	socketPath       string
+	unixDialer       *net.Dialer
+	secretsKey       []byte
+	listTimeout      *adaptiveTimeout
+	maxOutputSize    int64
+	outputEncoding   OutputEncoding
+	dlq              DLQ
+	formDefaults     *Form
+
+	maxScheduleHorizon, minRunIn time.Duration
+
+	mux sync.RWMutex
 }
 
-func New(discoveryURL string) (*Client, error) {
+type ClientOption func(*Client)
+
+func WithTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.httpClient.Timeout = d
+	}
+}
+
+func New(discoveryURL string, opts ...ClientOption) (*Client, error) {
+	return NewWithContext(context.Background(), discoveryURL, opts...)
+}
+
+// NewWithContext is like New, ctx bounding the discovery and its retries.
+func NewWithContext(ctx context.Context, discoveryURL string, opts ...ClientOption) (*Client, error) {
 	httpClient := &http.Client{Timeout: 3 * time.Second}
-	resp, err := httpClient.Get(discoveryURL)
+	v, err := discoverWithRetry(ctx, httpClient, discoveryURL, probeOptions(opts).discoveryRetry)
+	if err != nil {
+		return nil, err
+	}
+	logCompatibility(v)
+	c, err := newFromServiceInfo(httpClient, v)
+	if err != nil {
+		return nil, err
+	}
+	c.discoveryURL = discoveryURL
+	return c.apply(opts), nil
+}
+
+// NewFromServiceInfo builds a client for an already known scheduler, skipping discovery.
+func NewFromServiceInfo(info model.ServiceInfo, opts ...ClientOption) (*Client, error) {
+	c, err := newFromServiceInfo(&http.Client{Timeout: 3 * time.Second}, &info)
+	if err != nil {
+		return nil, err
+	}
+	return c.apply(opts), nil
+}
+
+func (c *Client) apply(opts []ClientOption) *Client {
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func discover(ctx context.Context, httpClient *http.Client, discoveryURL string) (*model.ServiceInfo, error) {
+	req, err := http.NewRequest(http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	v := &model.ServiceInfo{}
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := ioutil.ReadAll(limitBody(resp.Body, defaultMaxResponseSize))
 	if err != nil {
 		return nil, fmt.Errorf("cannot read body at discovery enpoint '%s', status code %s. Error: %s", discoveryURL, resp.Status, err)
 	}
 	if err := json.Unmarshal(body, v); err != nil {
 		return nil, fmt.Errorf("cannot unmarshal json at discovery enpoint '%s': %s. Body was:\n%s", discoveryURL, err, body)
 	}
+	return v, nil
+}
 
+func newFromServiceInfo(httpClient *http.Client, v *model.ServiceInfo) (*Client, error) {
+	var c *Client
 	if v.UnixSockMode {
-		c := newUnixSock(v.ServiceAddr)
+		c = newUnixSock(v.ServiceAddr)
 		c.serviceInfo = v
-		return c, nil
+	} else {
+		addr, err := url.Parse(v.ServiceAddr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse scheduler service addr %s: %s", v.ServiceAddr, err)
+		}
+		c = &Client{
+			ServiceURL:  addr,
+			httpClient:  httpClient,
+			serviceInfo: v,
+		}
 	}
 
-	addr, err := url.Parse(v.ServiceAddr)
-	if err != nil {
-		return nil, fmt.Errorf("cannot parse scheduler service addr %s: %s", v.ServiceAddr, err)
-	}
+	c.transport = &transport{next: c.httpClient.Transport, counters: &requestCounters{}}
+	c.httpClient.Transport = c.transport
 
-	return &Client{
-		ServiceURL:  addr,
-		httpClient:  httpClient,
-		serviceInfo: v,
-	}, nil
+	return c, nil
 }
 
 func newUnixSock(u string) *Client {
+	dialer := &net.Dialer{Timeout: defaultUnixDialTimeout}
 	return &Client{
 		ServiceURL: &url.URL{Host: "unixsock", Scheme: "http"}, // context info only
 		httpClient: &http.Client{
 			Timeout: 3 * time.Second,
 			Transport: &http.Transport{
 				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
-					return net.Dial("unix", u)
+					return dialer.DialContext(ctx, "unix", u)
 				},
 			},
 		},
+		unixDialer: dialer,
 	}
 }
 
-type Form struct {
-	Region, RunIn, RevertIn string
-	Template                string
+func (c *Client) Ping() error {
+	return c.ping(context.Background())
 }
 
-func (c *Client) Ping() error {
-	addr := *c.ServiceURL
+func (c *Client) ping(ctx context.Context) error {
+	addr := c.serviceURL()
+
+	req, err := http.NewRequest(http.MethodGet, addr.String(), nil)
+	if err != nil {
+		return err
+	}
 
-	resp, err := c.httpClient.Get(addr.String())
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusUnauthorized {
+		return ErrTokenRejected
+	}
 	return notOKStatus(addr.String(), resp)
 }
 
+func (c *Client) serviceURL() url.URL {
+	if c.endpoints != nil {
+		if u, ok := c.endpoints.best(); ok {
+			return u
+		}
+	}
+
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return *c.ServiceURL
+}
+
+// SetServiceAddr points an HTTP client to another scheduler service address.
+func (c *Client) SetServiceAddr(addr string) error {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return fmt.Errorf("cannot parse scheduler service addr %s: %s", addr, err)
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.ServiceURL = u
+	info := *c.serviceInfo
+	info.ServiceAddr = addr
+	c.serviceInfo = &info
+	return nil
+}
+
 func (c *Client) ServiceInfo() model.ServiceInfo {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
 	return *c.serviceInfo
 }
 
 func (c *Client) ListTasks() ([]*model.Task, error) {
-	var tasks []*model.Task
+	return c.listTasks(context.Background(), "tasks", nil)
+}
+
+func (c *Client) ListFailures() ([]*model.Task, error) {
+	return c.listTasks(context.Background(), "failures", nil)
+}
+
+func (c *Client) listTasks(ctx context.Context, path string, query url.Values) ([]*model.Task, error) {
+	addr := c.serviceURL()
+	addr.Path = path
+	addr.RawQuery = query.Encode()
 
-	addr := *c.ServiceURL
-	addr.Path = "tasks"
+	if v, ok := c.cache.load(addr.String()); ok {
+		return copyTasks(v.([]*model.Task)), nil
+	}
 
-	resp, err := c.httpClient.Get(addr.String())
+	body, err := c.fetchTasks(ctx, addr)
 	if err != nil {
-		return tasks, err
+		return nil, err
+	}
+	return c.decodeTasks(addr, body)
+}
+
+// fetchTasks reads the whole task listing at addr, so that ctx may be
+// canceled before the listing is decoded.
+func (c *Client) fetchTasks(ctx context.Context, addr url.URL) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, addr.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := c.httpClient
+	if c.listTimeout != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = c.listTimeout.withTimeout(ctx)
+		defer cancel()
+		withoutTimeout := *c.httpClient
+		withoutTimeout.Timeout = 0
+		httpClient = &withoutTimeout
+	}
+
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if err = notOKStatus(addr.String(), resp); err != nil {
-		return tasks, err
+		return nil, err
 	}
 
-	if err = json.NewDecoder(resp.Body).Decode(&tasks); err != nil {
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (c *Client) decodeTasks(addr url.URL, body []byte) ([]*model.Task, error) {
+	var tasks []*model.Task
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&tasks); err != nil {
 		return tasks, err
 	}
+	c.cache.store(addr.String(), addr.Path, copyTasks(tasks))
+	if c.listTimeout != nil {
+		c.listTimeout.record(len(tasks))
+	}
 
 	return tasks, nil
 }
 
-func (c *Client) ListFailures() ([]*model.Task, error) {
-	var tasks []*model.Task
+func (c *Client) ListRegions() ([]string, error) {
+	return c.listRegions(context.Background())
+}
+
+func (c *Client) listRegions(ctx context.Context) ([]string, error) {
+	var regions []string
+
+	addr := c.serviceURL()
+	addr.Path = "regions"
 
-	addr := *c.ServiceURL
-	addr.Path = "failures"
+	if v, ok := c.cache.load(addr.String()); ok {
+		return append([]string(nil), v.([]string)...), nil
+	}
 
-	resp, err := c.httpClient.Get(addr.String())
+	req, err := http.NewRequest(http.MethodGet, addr.String(), nil)
 	if err != nil {
-		return tasks, err
+		return regions, err
+	}
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return regions, err
 	}
 	defer resp.Body.Close()
 
 	if err = notOKStatus(addr.String(), resp); err != nil {
-		return tasks, err
+		return regions, err
 	}
 
-	if err = json.NewDecoder(resp.Body).Decode(&tasks); err != nil {
-		return tasks, err
+	if err = json.NewDecoder(resp.Body).Decode(&regions); err != nil {
+		return regions, err
 	}
+	c.cache.store(addr.String(), addr.Path, append([]string(nil), regions...))
 
-	return tasks, nil
+	return regions, nil
 }
 
-func (c *Client) Post(f Form) error {
-	addr := *c.ServiceURL
-	addr.Path = "tasks"
-	query := addr.Query()
-	query.Add("region", f.Region)
-	if f.RunIn != "" {
-		query.Add("run", f.RunIn)
+// Post posts the form, once in every region known to the scheduler for all
+// regions forms. See PostAllRegions for the ids of the created tasks.
+func (c *Client) Post(f Form, opts ...PostOption) error {
+	_, err := c.postWithOptions(f, opts)
+	return err
+}
+
+// PostAllRegions posts the form once in every region known to the scheduler,
+// the form region being left empty, and returns the ids of the created
+// tasks. Partial failures are a *MultiRegionPostError along with the ids of
+// the tasks created.
+func (c *Client) PostAllRegions(f Form, opts ...PostOption) ([]string, error) {
+	f.AllRegions = true
+	return c.postWithOptions(f, opts)
+}
+
+func (c *Client) postWithOptions(f Form, opts []PostOption) ([]string, error) {
+	var options postOptions
+	for _, opt := range opts {
+		opt(&options)
 	}
-	if f.RevertIn != "" {
-		query.Add("revert", f.RevertIn)
+
+	ids, err := c.postForm(context.Background(), f)
+	if err != nil {
+		return ids, err
 	}
+
+	if options.confirmWait > 0 {
+		return ids, c.confirm(ids, options.confirmWait)
+	}
+	return ids, nil
+}
+
+// postForm posts the form bounded by ctx, once per region for all regions
+// forms.
+func (c *Client) postForm(ctx context.Context, f Form) ([]string, error) {
+	f = c.withFormDefaults(f)
+	if err := c.validate(f); err != nil {
+		return nil, err
+	}
+	if f.AllRegions {
+		return c.postAllRegions(ctx, f)
+	}
+	id, err := c.submit(ctx, http.MethodPost, "tasks", f)
+	if err != nil {
+		return nil, err
+	}
+	return []string{id}, nil
+}
+
+func (c *Client) postAllRegions(ctx context.Context, f Form) ([]string, error) {
+	regions, err := c.listRegions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list regions: %s", err)
+	}
+
+	var ids []string
+	failures := make(map[string]error)
+	for _, region := range regions {
+		regionForm := f
+		regionForm.AllRegions = false
+		regionForm.Region = region
+		id, err := c.submit(ctx, http.MethodPost, "tasks", regionForm)
+		if err != nil {
+			failures[region] = err
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	if len(failures) > 0 {
+		return ids, &MultiRegionPostError{Failures: failures}
+	}
+	return ids, nil
+}
+
+func (c *Client) Get(ctx context.Context, taskID string) (*model.Task, error) {
+	return c.getTask(ctx, taskID, nil)
+}
+
+// GetWithFallback gets the first of the tasks found, along with its id. It
+// stops at the first error other than ErrNotFound.
+func (c *Client) GetWithFallback(ctx context.Context, taskIDs ...string) (*model.Task, string, error) {
+	for _, id := range taskIDs {
+		tk, err := c.Get(ctx, id)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return tk, id, nil
+	}
+	return nil, "", ErrNotFound
+}
+
+func (c *Client) getTask(ctx context.Context, taskID string, query url.Values) (*model.Task, error) {
+	addr := c.serviceURL()
+	addr.Path = "tasks/" + taskID
 	addr.RawQuery = query.Encode()
 
-	resp, err := c.httpClient.Post(
-		addr.String(),
-		"application/text",
-		strings.NewReader(f.Template),
-	)
+	if v, ok := c.cache.load(addr.String()); ok {
+		return copyTask(v.(*model.Task)), nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, addr.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if err = notOKStatus(addr.String(), resp); err != nil {
+		return nil, err
+	}
+
+	tk := &model.Task{}
+	if err = json.NewDecoder(resp.Body).Decode(tk); err != nil {
+		return nil, err
+	}
+	c.cache.store(addr.String(), addr.Path, copyTask(tk))
+
+	return tk, nil
+}
+
+// Reschedule replaces the task with the given form. As a task id is derived
+// from its content and schedule, the returned id usually differs from taskID.
+func (c *Client) Reschedule(ctx context.Context, taskID string, f Form) (string, error) {
+	if err := c.validate(f); err != nil {
+		return "", err
+	}
+	if f.AllRegions {
+		return "", errors.New("cannot reschedule a task in all regions")
+	}
+
+	return c.submit(ctx, http.MethodPut, "tasks/"+taskID, f)
+}
+
+// UpdateContent replaces the template of a pending task, keeping its schedule.
+// As for Reschedule, the task id holding the template checksum, the task gets
+// a new id: see GetByContentHash. Tasks being executed fail with
+// ErrTaskAlreadyRunning.
+func (c *Client) UpdateContent(ctx context.Context, taskID string, newTemplate string) error {
+	if strings.TrimSpace(newTemplate) == "" {
+		return errors.New("empty task template")
+	}
+
+	addr := c.serviceURL()
+	addr.Path = "tasks/" + taskID
+
+	body, err := json.Marshal(map[string]string{"content": newTemplate})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPatch, addr.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	if err := notOKStatus(addr.String(), resp); err != nil {
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if err = notOKStatus(addr.String(), resp); err != nil {
+		return sentinel(err)
+	}
+	c.cache.invalidateTasks()
+	return nil
+}
+
+// TaskExists checks a task is pending or failed with a HEAD request, falling
+// back on Get for schedulers not supporting it.
+func (c *Client) TaskExists(ctx context.Context, taskID string) (bool, error) {
+	addr := c.serviceURL()
+	addr.Path = "tasks/" + taskID
+
+	req, err := http.NewRequest(http.MethodHead, addr.String(), nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	case http.StatusMethodNotAllowed:
+		_, err = c.Get(ctx, taskID)
+		if err == ErrNotFound {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	return false, notOKStatus(addr.String(), resp)
+}
+
+func (c *Client) Delete(ctx context.Context, taskID string) error {
+	addr := c.serviceURL()
+	addr.Path = "tasks/" + taskID
+
+	req, err := http.NewRequest(http.MethodDelete, addr.String(), nil)
+	if err != nil {
 		return err
 	}
 
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if err = notOKStatus(addr.String(), resp); err != nil {
+		return err
+	}
+	c.cache.invalidateTasks()
 	return nil
 }
 
+// postContext is Post bounded by ctx, for forms in a single region.
+func (c *Client) postContext(ctx context.Context, f Form) (string, error) {
+	f = c.withFormDefaults(f)
+	if err := c.validate(f); err != nil {
+		return "", err
+	}
+	if f.AllRegions {
+		return "", errors.New("cannot post a form in all regions with a context")
+	}
+	return c.submit(ctx, http.MethodPost, "tasks", f)
+}
+
+func (c *Client) submit(ctx context.Context, method, path string, f Form) (string, error) {
+	req, err := c.newFormRequest(ctx, method, path, f)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if err := notOKStatus(req.URL.String(), resp); err != nil {
+		return "", err
+	}
+	c.cache.invalidateTasks()
+
+	id, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("cannot read task id from '%s': %s", req.URL.String(), err)
+	}
+
+	return strings.TrimSpace(string(id)), nil
+}
+
+func (c *Client) newFormRequest(ctx context.Context, method, path string, f Form) (*http.Request, error) {
+	template, err := f.Render()
+	if err != nil {
+		return nil, err
+	}
+	f.Template = template
+
+	addr := c.serviceURL()
+	addr.Path = path
+	query := addr.Query()
+	query.Add("region", f.Region)
+	run, err := f.runIn(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if run != "" {
+		query.Add("run", run)
+	}
+	if f.RevertIn != "" {
+		query.Add("revert", f.RevertIn)
+	}
+	for _, id := range f.DependsOn {
+		query.Add("depends_on", id)
+	}
+	if f.Timezone != "" {
+		query.Add("tz", f.Timezone)
+	}
+	if language := f.contentLanguage(); language != "" {
+		query.Add("content_language", language)
+	}
+	if owner := c.owner(f); owner != "" {
+		query.Add("owner", owner)
+	}
+	if f.CronExpr != "" {
+		query.Add("cron", f.CronExpr)
+	}
+	if f.MaxRetries > 0 {
+		query.Add("max_retries", strconv.Itoa(f.MaxRetries))
+	}
+	var tagKeys []string
+	for k := range f.Tags {
+		tagKeys = append(tagKeys, k)
+	}
+	sort.Strings(tagKeys)
+	for _, k := range tagKeys {
+		query.Add("tag", k+"="+f.Tags[k])
+	}
+	if f.Description != "" {
+		query.Add("description", f.Description)
+	}
+	addr.RawQuery = query.Encode()
+
+	if f.AfterSuccess != nil || f.OnFailure != nil || len(f.SecretEnv) > 0 {
+		secrets, encryption, err := c.encryptSecrets(f.SecretEnv)
+		if err != nil {
+			return nil, err
+		}
+		body, err := json.Marshal(struct {
+			Template                string
+			ContentType             string            `json:",omitempty"`
+			SecretEnv               map[string]string `json:",omitempty"`
+			SecretEncryption        string            `json:",omitempty"`
+			AfterSuccess, OnFailure *model.Callback
+		}{f.Template, f.ContentType, secrets, encryption, f.AfterSuccess.callback(), f.OnFailure.callback()})
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequest(method, addr.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", model.FormContentType)
+		return req.WithContext(ctx), nil
+	}
+
+	req, err := http.NewRequest(method, addr.String(), strings.NewReader(f.Template))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", f.contentType())
+
+	return req.WithContext(ctx), nil
+}
+
 func notOKStatus(addr string, resp *http.Response) error {
-	if code := resp.StatusCode; code != http.StatusOK {
+	if code := resp.StatusCode; code == http.StatusTooManyRequests {
+		return &RateLimitError{RetryAfter: retryAfter(resp.Header.Get("Retry-After"), time.Now())}
+	} else if code != http.StatusOK {
 		body, _ := ioutil.ReadAll(resp.Body)
-		return fmt.Errorf("Got %d status instead of 200 from '%s': %q", code, addr, body)
+		if serverErr := parseServerError(code, body); serverErr != nil {
+			return serverErr
+		}
+		return fmt.Errorf("Got %d status instead of 200 from '%s' (%s): %q", code, addr, resp.Header.Get("Content-Type"), body)
 	}
 
 	return nil
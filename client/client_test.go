@@ -1,13 +1,31 @@
 package client
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/wallix/awless-scheduler/model"
 )
@@ -56,6 +74,143 @@ func TestUnixSockClient(t *testing.T) {
 	if got, want := len(tasks), 0; got != want {
 		t.Fatalf("got %d, want %d", got, want)
 	}
+
+	if !cli.Reachable(context.Background()) {
+		t.Fatal("expected unix sock scheduler to be reachable")
+	}
+	if err = cli.TestConnectivity(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	detected, err := NewAutoDetect(WithDefaultSocketPath(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !detected.ServiceInfo().UnixSockMode {
+		t.Fatal("expected auto detected client in unix sock mode")
+	}
+	if _, err := detected.ListTasks(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Setenv(SocketPathEnv, os.Getenv(SocketPathEnv))
+	os.Unsetenv(SocketPathEnv)
+	if _, err = NewUnixSockFromEnv(); err != ErrNoSocketEnv {
+		t.Fatalf("got %v, want %v", err, ErrNoSocketEnv)
+	}
+	os.Setenv(SocketPathEnv, "missing.sock")
+	if _, err = NewUnixSockFromEnv(); err != ErrSocketNotFound {
+		t.Fatalf("got %v, want %v", err, ErrSocketNotFound)
+	}
+	os.Setenv(SocketPathEnv, "client_test.go")
+	if _, err = NewUnixSockFromEnv(); err == nil {
+		t.Fatal("expected not a socket error")
+	}
+	os.Setenv(SocketPathEnv, filename)
+	fromEnv, err := NewUnixSockFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fromEnv.ListTasks(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnixDialTimeout(t *testing.T) {
+	info := model.ServiceInfo{ServiceAddr: "test-dial-timeout.sock", UnixSockMode: true}
+	cli, err := NewFromServiceInfo(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cli.unixDialer.Timeout, defaultUnixDialTimeout; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if cli, err = NewFromServiceInfo(info, WithUnixDialTimeout(5*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cli.unixDialer.Timeout, 5*time.Second; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestUnixSockKeepalive(t *testing.T) {
+	filename := "test-keepalive.sock"
+	defer os.Remove(filename)
+
+	serve := func() *http.Server {
+		os.Remove(filename)
+		l, err := net.Listen("unix", filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("id"))
+		})}
+		go s.Serve(l)
+		return s
+	}
+
+	cli, err := NewFromServiceInfo(model.ServiceInfo{ServiceAddr: filename, UnixSockMode: true}, WithUnixSocketKeepalive(time.Minute), WithSocketWatcher(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := Form{Region: "us-west-1", Template: "create user name=toto"}
+
+	s := serve()
+	if err = cli.Post(f); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s = serve()
+	defer s.Close()
+	if err = cli.Post(f); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(filename); !os.SameFile(cli.transport.socketWatcher.info, info) {
+		t.Fatal("expected socket watcher to see the recreated socket")
+	}
+	cli.ResetTransport()
+	if err = cli.Post(f); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnixSockKeepaliveResendsOnlyIdempotentRequests(t *testing.T) {
+	filename := "test-keepalive-resend.sock"
+	defer os.Remove(filename)
+	os.Remove(filename)
+	l, err := net.Listen("unix", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requests int32
+	s := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	})}
+	go s.Serve(l)
+	defer s.Close()
+
+	cli, err := NewFromServiceInfo(model.ServiceInfo{ServiceAddr: filename, UnixSockMode: true}, WithUnixSocketKeepalive(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = cli.Post(Form{Region: "us-west-1", Template: "create user name=toto"}); err == nil {
+		t.Fatal("expected error")
+	}
+	if got, want := atomic.LoadInt32(&requests), int32(1); got != want {
+		t.Fatalf("got %d posts, want %d", got, want)
+	}
+
+	atomic.StoreInt32(&requests, 0)
+	if _, err = cli.ListTasks(); err == nil {
+		t.Fatal("expected error")
+	}
+	if got, want := atomic.LoadInt32(&requests), int32(2); got != want {
+		t.Fatalf("got %d lists, want %d", got, want)
+	}
 }
 
 func TestHTTPClient(t *testing.T) {
@@ -101,4 +256,3193 @@ func TestHTTPClient(t *testing.T) {
 	if got, want := len(tasks), 0; got != want {
 		t.Fatalf("got %d, want %d", got, want)
 	}
+
+	if !cli.Reachable(context.Background()) {
+		t.Fatal("expected http scheduler to be reachable")
+	}
+	if err = cli.TestConnectivity(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := l.Addr().String()
+	l.Close()
+	if err = cli.SetServiceAddr("http://" + closedAddr); err != nil {
+		t.Fatal(err)
+	}
+	connErr, ok := cli.TestConnectivity(context.Background()).(*ConnectivityError)
+	if !ok {
+		t.Fatal("expected connectivity error")
+	}
+	if got, want := connErr.Addr, closedAddr; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if connErr.Err == nil {
+		t.Fatal("expected underlying dial error")
+	}
+}
+
+func TestTaskQueue(t *testing.T) {
+	var posted int32
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			atomic.AddInt32(&posted, 1)
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var failed int32
+	q := cli.NewQueue(ctx, QueueOptions{
+		BufferSize: 2,
+		MaxRPS:     100,
+		OnError:    func(Form, error) { atomic.AddInt32(&failed, 1) },
+	})
+
+	for i := 0; i < 2; i++ {
+		q.Enqueue(Form{Region: "us-west-1", Template: "create user name=toto"})
+	}
+
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer flushCancel()
+	if err := q.Flush(flushCtx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := atomic.LoadInt32(&posted), int32(2); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if got, want := atomic.LoadInt32(&failed), int32(0); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+}
+
+func TestTaskQueueCancel(t *testing.T) {
+	started := make(chan struct{})
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		close(started)
+		<-r.Context().Done()
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	q := cli.NewQueue(ctx, QueueOptions{OnError: func(f Form, err error) { errs <- err }})
+
+	q.Enqueue(Form{Region: "us-west-1", Template: "create user name=toto"})
+	<-started
+	cancel()
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("in-flight post not cancelled")
+	}
+
+	q.Enqueue(Form{Region: "us-west-1", Template: "create user name=tata"})
+	if err := <-errs; err != ErrQueueClosed {
+		t.Fatalf("got %v, want %v", err, ErrQueueClosed)
+	}
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer flushCancel()
+	if err := q.Flush(flushCtx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestClient(t *testing.T, schedulerURL string) *Client {
+	discoveryService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := json.Marshal(&model.ServiceInfo{ServiceAddr: schedulerURL})
+		w.Write(b)
+	}))
+	defer discoveryService.Close()
+
+	cli, err := New(discoveryService.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cli
+}
+
+func TestDiscoveryRetry(t *testing.T) {
+	var attempts int32
+	discoveryService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(&model.ServiceInfo{ServiceAddr: "http://localhost:9096"})
+	}))
+	defer discoveryService.Close()
+
+	if _, err := New(discoveryService.URL, WithDiscoveryRetry(3, time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	atomic.StoreInt32(&attempts, 0)
+	_, err := New(discoveryService.URL, WithDiscoveryRetry(2, time.Millisecond))
+	if discoveryErr, ok := err.(*DiscoveryError); !ok || len(discoveryErr.Attempts) != 2 {
+		t.Fatalf("got %v, want discovery error with 2 attempts", err)
+	}
+}
+
+func TestCallbackForms(t *testing.T) {
+	var body struct {
+		Template                string
+		AfterSuccess, OnFailure *model.Callback
+	}
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte("id"))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	f := Form{
+		Region:       "us-west-1",
+		Template:     "create user name=toto",
+		AfterSuccess: &Form{RunIn: "1m", Template: "attach user name=toto group=admins"},
+	}
+	if err := cli.Post(f); err != nil {
+		t.Fatal(err)
+	}
+	if body.AfterSuccess == nil || body.OnFailure != nil {
+		t.Fatalf("got %+v, want only after success callback", body)
+	}
+	if got, want := body.AfterSuccess.RunIn, "1m"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	f.AfterSuccess.AfterSuccess = &Form{Template: "delete user name=toto"}
+	if err := f.Validate(); err == nil {
+		t.Fatal("expected error on nested callback")
+	}
+}
+
+func TestFormContentType(t *testing.T) {
+	var contentType string
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		w.Write([]byte("id"))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	f := Form{Region: "us-west-1", Template: "create user name=toto"}
+	if err := cli.Post(f); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := contentType, DefaultContentType; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	f.ContentType = "application/json; charset=utf-8"
+	if err := cli.Post(f); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := contentType, f.ContentType; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	f.ContentType = "application/"
+	if err := f.Validate(); err == nil {
+		t.Fatal("expected error on invalid content type")
+	}
+}
+
+func TestFormTimezone(t *testing.T) {
+	var tz string
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tz = r.FormValue("tz")
+		w.Write([]byte("id"))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	f := Form{Region: "us-west-1", RunIn: "2m", Template: "create user name=toto", Timezone: "America/New_York"}
+	if err := cli.Post(f); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tz, "America/New_York"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	f.Timezone = "Mars/Olympus_Mons"
+	err := f.Validate()
+	if err == nil || !strings.Contains(err.Error(), "timezone") {
+		t.Fatalf("got %v, want invalid timezone error", err)
+	}
+}
+
+func TestListByContentType(t *testing.T) {
+	var query string
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("content_type")
+		json.NewEncoder(w).Encode([]*model.Task{
+			{ID: "1", Content: "create user name=toto", Region: "us-west-1"},
+			{ID: "2", Content: "{}", ContentType: "application/json", Region: "us-west-1"},
+		})
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	tasks, err := cli.ListByContentType(context.Background(), "application/json; charset=utf-8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := query, "application/json; charset=utf-8"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if len(tasks) != 2 || tasks[0].ID != "2" || tasks[1].ID != "2" {
+		t.Fatalf("got %v, want only task 2 for each status", tasks)
+	}
+}
+
+func TestSecretEncryption(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)})
+
+	var rawQuery string
+	var body struct {
+		SecretEnv        map[string]string
+		SecretEncryption string
+	}
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawQuery = r.URL.RawQuery
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte("id"))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	cli = cli.apply([]ClientOption{WithSecretEncryption(publicKey)})
+
+	f := Form{Region: "us-west-1", Template: "create user name=toto password={password}", SecretEnv: map[string]string{"password": "s3cr3t"}}
+	if err = cli.Post(f); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(rawQuery, "s3cr3t") {
+		t.Fatalf("got secret in query %s", rawQuery)
+	}
+	if got, want := body.SecretEncryption, model.SecretEncryptionRSAOAEP; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	cipher, err := base64.StdEncoding.DecodeString(body.SecretEnv["password"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, key, cipher, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(plain), "s3cr3t"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestPreflightCheck(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/version":
+			json.NewEncoder(w).Encode(model.VersionInfo{APIVersion: APIVersion})
+		case "/regions":
+			json.NewEncoder(w).Encode([]string{"eu-west-1", "us-west-1"})
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	res, err := cli.PreflightCheck(context.Background(), "us-west-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.OK {
+		t.Fatalf("got issues %v, want none", res.Issues)
+	}
+	res, err = cli.PreflightCheck(context.Background(), "mars-north-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OK || res.RegionSupported || len(res.Issues) != 1 {
+		t.Fatalf("got %+v, want only region issue", res)
+	}
+}
+
+func TestSubmitFromDir(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(strings.TrimPrefix(string(body), "create user name=")))
+	}))
+	defer schedulerService.Close()
+
+	dir, err := ioutil.TempDir("", "forms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"b.json":     `{"Region": "us-west-1", "Template": "create user name=tata"}`,
+		"a.json":     `{"Region": "us-west-1", "Template": "create user name=toto"}`,
+		"readme.txt": "not a form",
+		"c.yaml":     "Region: us-west-1",
+	} {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	var submitted []string
+	ids, err := cli.SubmitFromDir(context.Background(), dir, SubmitDirOptions{
+		OnSubmit: func(path, id string) { submitted = append(submitted, filepath.Base(path)) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(ids, ","), "toto,tata"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := strings.Join(submitted, ","), "a.json,b.json"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestTaskExists(t *testing.T) {
+	var headSupported bool
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodHead && !headSupported:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.URL.Path != "/tasks/1":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			json.NewEncoder(w).Encode(&model.Task{ID: "1", Content: "create user name=toto", Region: "us-west-1"})
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	for _, headSupported = range []bool{true, false} {
+		if exists, err := cli.TaskExists(context.Background(), "1"); err != nil || !exists {
+			t.Fatalf("got %t, %v, want existing task (head supported: %t)", exists, err, headSupported)
+		}
+		if exists, err := cli.TaskExists(context.Background(), "2"); err != nil || exists {
+			t.Fatalf("got %t, %v, want missing task (head supported: %t)", exists, err, headSupported)
+		}
+	}
+}
+
+func TestDescribeForm(t *testing.T) {
+	var path, run string
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, run = r.URL.Path, r.URL.Query().Get("run")
+		json.NewEncoder(w).Encode(model.FormDescription{ParsedRegion: "us-west-1", TemplateLineCount: 1, Warnings: []string{"w"}})
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	desc, err := cli.DescribeForm(context.Background(), Form{Region: "us-west-1", RunIn: "2m", Template: "create user name=toto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := path+"?run="+run, "/tasks/describe?run=2m"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := desc.TemplateLineCount, 1; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if _, err = cli.DescribeForm(context.Background(), Form{AllRegions: true, Template: "create user name=toto"}); err == nil {
+		t.Fatal("expected error on all regions form")
+	}
+}
+
+func TestAdaptiveListTimeout(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		json.NewEncoder(w).Encode([]*model.Task{{ID: "1"}, {ID: "2"}, {ID: "3"}})
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	cli = cli.apply([]ClientOption{WithTimeout(50 * time.Millisecond), WithAdaptiveListTimeout(1000, time.Second, 2*time.Second)})
+
+	if _, err := cli.ListTasks(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cli.listTimeout.timeout(), 2*time.Second; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	cli.listTimeout.record(1)
+	if got, want := cli.listTimeout.timeout(), time.Second; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	noFloor := &adaptiveTimeout{perTaskMs: 1000}
+	if got, want := noFloor.timeout(), minAdaptiveListTimeout; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestExportPrometheusTextFormat(t *testing.T) {
+	now := time.Now().UTC()
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/failures" {
+			json.NewEncoder(w).Encode([]*model.Task{{ID: "3", RunAt: model.FlexibleTime(now.Add(-time.Hour)), Region: "eu-west-1"}})
+			return
+		}
+		json.NewEncoder(w).Encode([]*model.Task{
+			{ID: "1", RunAt: model.FlexibleTime(now.Add(time.Hour)), Region: "us-west-1"},
+			{ID: "2", RunAt: model.FlexibleTime(now.Add(-time.Minute)), Region: "us-west-1"},
+		})
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	var buf bytes.Buffer
+	if err := cli.ExportPrometheusTextFormat(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`awless_scheduler_tasks{status="pending"} 2`,
+		`awless_scheduler_tasks{status="failed"} 1`,
+		"awless_scheduler_overdue_tasks 1",
+		`awless_scheduler_region_tasks{region="us-west-1"} 2`,
+		fmt.Sprintf("awless_scheduler_next_run_timestamp_seconds %d", now.Add(time.Hour).Unix()),
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Fatalf("got\n%s\nwant line %s", buf.String(), line)
+		}
+	}
+}
+
+func TestCompareStats(t *testing.T) {
+	newScheduler := func(tasks ...*model.Task) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/failures" {
+				w.Write([]byte("[]"))
+				return
+			}
+			json.NewEncoder(w).Encode(tasks)
+		}))
+	}
+	at := func(hours int) model.FlexibleTime {
+		return model.FlexibleTime(time.Date(2017, 6, 1, hours, 0, 0, 0, time.UTC))
+	}
+	primary := newScheduler(
+		&model.Task{ID: "1", Content: "create user name=toto", RunAt: at(1), Region: "us-west-1"},
+		&model.Task{ID: "2", Content: "create user name=tata", RunAt: at(2), Region: "us-west-1"},
+		&model.Task{ID: "4", Content: "create user name=tyty", RunAt: at(4), Region: "us-west-1"},
+	)
+	defer primary.Close()
+	standby := newScheduler(
+		&model.Task{ID: "4", Content: "create user name=tyty", RunAt: at(4), Region: "us-west-1"},
+		&model.Task{ID: "5", Content: "create user name=titi", RunAt: at(2), Region: "us-west-1"},
+		&model.Task{ID: "3", Content: "create user name=tutu", RunAt: at(3), Region: "us-west-1"},
+	)
+	defer standby.Close()
+
+	cli := newTestClient(t, primary.URL)
+	comparison, err := cli.CompareStats(context.Background(), newTestClient(t, standby.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comparison.OnlyInSelf) != 1 || comparison.OnlyInSelf[0].ID != "1" {
+		t.Fatalf("got %v, want only task 1 in self", comparison.OnlyInSelf)
+	}
+	if len(comparison.OnlyInOther) != 1 || comparison.OnlyInOther[0].ID != "3" {
+		t.Fatalf("got %v, want only task 3 in other", comparison.OnlyInOther)
+	}
+	if len(comparison.ContentMismatches) != 1 || comparison.ContentMismatches[0][0].ID != "2" || comparison.ContentMismatches[0][1].ID != "5" {
+		t.Fatalf("got %v, want task 2 content mismatch", comparison.ContentMismatches)
+	}
+}
+
+func TestGetByContentHash(t *testing.T) {
+	now := time.Now().UTC()
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/failures" {
+			w.Write([]byte("[]"))
+			return
+		}
+		json.NewEncoder(w).Encode([]*model.Task{
+			{ID: "1", Content: "create user name=toto", RunAt: model.FlexibleTime(now), Region: "us-west-1"},
+			{ID: "2", Content: "create user name=toto", RunAt: model.FlexibleTime(now.Add(time.Hour)), Region: "us-west-1"},
+			{ID: "3", Content: "create user name=tata", RunAt: model.FlexibleTime(now.Add(2 * time.Hour)), Region: "us-west-1"},
+		})
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	tk, err := cli.GetByContentHash(context.Background(), model.ContentHash("create user name=toto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tk.ID, "2"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if _, err = cli.GetByContentHash(context.Background(), model.ContentHash("create user name=titi")); err != ErrNotFound {
+		t.Fatalf("got %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestTaskGroups(t *testing.T) {
+	var mux sync.Mutex
+	groups := make(map[string]string)
+	var deleted []string
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		switch {
+		case r.Method == http.MethodPost:
+			id := fmt.Sprintf("%d", len(groups)+1)
+			groups[id] = r.Header.Get(model.TaskGroupHeader)
+			w.Write([]byte(id))
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/tasks/"))
+		case r.URL.Path == "/failures":
+			w.Write([]byte("[]"))
+		default:
+			var tasks []*model.Task
+			for id, group := range groups {
+				if r.FormValue("group") == "" || r.FormValue("group") == group {
+					tasks = append(tasks, &model.Task{ID: id, Group: group, Region: "us-west-1"})
+				}
+			}
+			json.NewEncoder(w).Encode(tasks)
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	if _, err := cli.NewChildClient(context.Background(), ""); err == nil {
+		t.Fatal("expected error for empty group")
+	}
+	child, err := cli.NewChildClient(context.Background(), "deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := Form{Region: "us-west-1", Template: "create user name=toto"}
+	if err = child.Post(form); err != nil {
+		t.Fatal(err)
+	}
+	if err = cli.Post(form); err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err := cli.ListByGroup(context.Background(), "deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(tasks), 1; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if got, want := tasks[0].Group, "deploy"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	if err = cli.CancelGroup(context.Background(), "deploy"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := deleted, []string{tasks[0].ID}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestTaskTimeline(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/failures" {
+			w.Write([]byte("[]"))
+			return
+		}
+		json.NewEncoder(w).Encode([]*model.Task{
+			{ID: "1", RunAt: model.FlexibleTime(now.Add(2 * time.Hour)), Region: "us-west-1"},
+			{ID: "2", RunAt: model.FlexibleTime(now), RevertAt: model.FlexibleTime(now.Add(3 * time.Hour)), Region: "us-west-1"},
+			{ID: "3", RunAt: model.FlexibleTime(now.Add(4 * time.Hour)), Region: "us-west-1"},
+		})
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	events, err := cli.TaskTimeline(context.Background(), ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range events {
+		got = append(got, e.Task.ID+":"+e.EventType)
+	}
+	if want := []string{"2:run", "1:run", "2:revert", "3:run"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if !events[0].OverlapsWith(events[1]) {
+		t.Fatal("expected task 1 to run while task 2 is applied")
+	}
+	if events[0].OverlapsWith(events[3]) {
+		t.Fatal("expected task 3 to run after task 2 is reverted")
+	}
+}
+
+func TestSortTasksBy(t *testing.T) {
+	now := time.Now().UTC()
+	tasks := []*model.Task{
+		{ID: "c", RunAt: model.FlexibleTime(now), Region: "us-west-1"},
+		{ID: "a", RunAt: model.FlexibleTime(now.Add(time.Hour)), Region: "eu-west-1"},
+		{ID: "b", RunAt: model.FlexibleTime(now), Region: "eu-west-1"},
+	}
+	ids := func() (ids []string) {
+		for _, tk := range tasks {
+			ids = append(ids, tk.ID)
+		}
+		return
+	}
+
+	SortTasksBy(tasks, SortByRunAt, SortAscending)
+	if got, want := ids(), []string{"b", "c", "a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	SortTasksBy(tasks, SortByRegion, SortDescending)
+	if got, want := ids(), []string{"c", "a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	SortTasksBy(tasks, SortByID, SortDescending)
+	if got, want := ids(), []string{"c", "b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestIsCompatible(t *testing.T) {
+	var apiVersion int32
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(model.VersionInfo{Version: "test", APIVersion: int(atomic.LoadInt32(&apiVersion))})
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	tcases := []struct {
+		apiVersion   int32
+		ok           bool
+		withWarnings bool
+	}{
+		{0, false, false},
+		{MinSupportedAPIVersion, true, false},
+		{MaxSupportedAPIVersion + 1, true, true},
+	}
+	for _, tcase := range tcases {
+		atomic.StoreInt32(&apiVersion, tcase.apiVersion)
+		ok, warnings, err := cli.IsCompatible(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tcase.ok || (len(warnings) > 0) != tcase.withWarnings {
+			t.Fatalf("API version %d: got %t %v", tcase.apiVersion, ok, warnings)
+		}
+	}
+}
+
+func TestListenForDeadLetterQueue(t *testing.T) {
+	var mux sync.Mutex
+	failures := map[string]bool{"1": true, "2": true}
+	var deleted []string
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		if r.Method == http.MethodDelete {
+			id := strings.TrimPrefix(r.URL.Path, "/dlq/")
+			delete(failures, id)
+			deleted = append(deleted, id)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, id := range []string{"1", "2"} {
+			if failures[id] {
+				b, _ := json.Marshal(&model.Task{ID: id, Status: model.StatusFailed, Region: "us-west-1"})
+				fmt.Fprintf(w, "event: task\ndata: %s\n\n", b)
+			}
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	cli = cli.apply([]ClientOption{WithDLQ(DLQ{MaxReprocessAttempts: 2})})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	handled := make(map[string]int)
+	err := cli.ListenForDeadLetterQueue(ctx, func(ctx context.Context, tk *model.Task) error {
+		handled[tk.ID]++
+		if tk.ID == "2" {
+			return errors.New("cannot reprocess")
+		}
+		return nil
+	})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if got, want := handled, map[string]int{"1": 1, "2": 2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	if got, want := deleted, []string{"1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestValidateWithMode(t *testing.T) {
+	f := Form{Template: "", RunIn: "1d", RevertIn: "-1h"}
+
+	issues, err := f.ValidateWithMode(StrictValidation)
+	if err == nil || err.Error() != "missing form region" {
+		t.Fatalf("got %v, want missing region error", err)
+	}
+	if got, want := len(issues), 1; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+
+	issues, err = f.ValidateWithMode(PermissiveValidation)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	severities := make(map[string]ValidationSeverity)
+	for _, issue := range issues {
+		severities[issue.Field] = issue.Severity
+	}
+	if got, want := severities, map[string]ValidationSeverity{"Region": SeverityError, "Template": SeverityWarning, "RunIn": SeverityError}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	f = Form{Region: "us-west-1", Template: "create user name=toto", ContentLanguage: ContentLanguageAwless}
+	if issues, err = f.ValidateWithMode(PermissiveValidation); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := issues, []ValidationIssue{{Field: "RunIn", Message: "no form run duration: task runs at once", Severity: SeverityInfo}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestContentLanguage(t *testing.T) {
+	for template, want := range map[string]string{
+		"create user name=toto":                       ContentLanguageAwless,
+		"# comment\ninst = create instance name=toto": ContentLanguageAwless,
+		"#!/bin/bash\naws s3 ls":                      ContentLanguageBash,
+		"set -e\naws s3 ls":                           ContentLanguageBash,
+		"resource \"aws_instance\" \"web\" {\n}":      ContentLanguageHCL,
+		"terraform {\n}":                              ContentLanguageHCL,
+		"settings are unknown":                        "",
+		"":                                            "",
+	} {
+		if got := DetectContentLanguage(template); got != want {
+			t.Fatalf("%q: got %q, want %q", template, got, want)
+		}
+	}
+
+	var languages []string
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		languages = append(languages, r.FormValue("content_language"))
+		w.Write([]byte("1"))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	for _, f := range []Form{
+		{Region: "us-west-1", Template: "create user name=toto"},
+		{Region: "us-west-1", Template: "create user name=toto", ContentLanguage: "custom"},
+	} {
+		if err := cli.Post(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := languages, []string{ContentLanguageAwless, "custom"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	issues, err := Form{Region: "us-west-1", RunIn: "1m", Template: "create user name=toto"}.ValidateWithMode(PermissiveValidation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := issues, []ValidationIssue{{Field: "ContentLanguage", Message: "form content language detected as awless", Severity: SeverityInfo}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestGetWithOptions(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// ignores the fields param
+		json.NewEncoder(w).Encode(&model.Task{ID: "1", Content: "create user name=toto", RunAt: model.FlexibleTime(now), Region: "us-west-1", Status: model.StatusPending})
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	tk, err := cli.GetWithOptions(context.Background(), "1", GetOptions{Fields: []string{"id", "region", "run_at"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tk, (&model.Task{ID: "1", RunAt: model.FlexibleTime(now), Region: "us-west-1"}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+	if tk, err = cli.GetWithOptions(context.Background(), "1", GetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tk.Content, "create user name=toto"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if _, err = cli.GetWithOptions(context.Background(), "1", GetOptions{Fields: []string{"creator"}}); err == nil {
+		t.Fatal("expected error for unknown field")
+	}
+}
+
+func TestTrace(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Envoy-Upstream-Service-Time", "12")
+		json.NewEncoder(w).Encode(model.TraceResult{Hops: []model.TraceHop{
+			{PodName: "envoy", Latency: time.Millisecond},
+			{PodName: "scheduler-0", NodeName: "node-1"},
+		}})
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	res, err := cli.Trace(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(res.Hops), 2; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if res.Hops[0].Latency != time.Millisecond || res.Hops[1].Latency == 0 {
+		t.Fatalf("got latencies %s and %s", res.Hops[0].Latency, res.Hops[1].Latency)
+	}
+	if got, want := cli.RequestStats().LastUpstreamServiceTime, 12*time.Millisecond; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestListChanged(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/changes" {
+			t.Fatalf("got path %s", r.URL.Path)
+		}
+		switch r.FormValue("since") {
+		case "":
+			json.NewEncoder(w).Encode(model.TaskChanges{Token: "1-2", Changes: []*model.TaskChange{
+				{ChangeType: model.ChangeCreated, Task: &model.Task{ID: "1", Region: "us-west-1"}},
+			}})
+		case "1-2":
+			json.NewEncoder(w).Encode(model.TaskChanges{Token: "1-3", Changes: []*model.TaskChange{
+				{ChangeType: model.ChangeDeleted, Task: &model.Task{ID: "1"}},
+			}})
+		default:
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte(`{"error":"TOKEN_EXPIRED","message":"token expired"}`))
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	changes, token, err := cli.ListChanged(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := token, "1-2"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if len(changes) != 1 || changes[0].ChangeType != model.ChangeCreated || changes[0].Task.Status != model.StatusPending {
+		t.Fatalf("got %+v", changes)
+	}
+
+	changes, token, err = cli.ListChanged(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "1-3" || len(changes) != 1 || changes[0].ChangeType != model.ChangeDeleted || changes[0].Task.ID != "1" {
+		t.Fatalf("got %s and %+v", token, changes)
+	}
+
+	if _, _, err = cli.ListChanged(context.Background(), "0-1"); err != ErrTokenExpired {
+		t.Fatalf("got %v, want %v", err, ErrTokenExpired)
+	}
+}
+
+func TestBackfillMissed(t *testing.T) {
+	now := time.Now().UTC()
+	var mux sync.Mutex
+	var backfilled []string
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			mux.Lock()
+			backfilled = append(backfilled, r.URL.Path)
+			mux.Unlock()
+			w.Write([]byte("new-id"))
+			return
+		}
+		json.NewEncoder(w).Encode([]*model.Task{
+			{ID: "1", RunAt: model.FlexibleTime(now.Add(-time.Hour)), Region: "us-west-1"},
+			{ID: "2", RunAt: model.FlexibleTime(now.Add(-2 * time.Hour)), Region: "eu-west-1"},
+			{ID: "3", RunAt: model.FlexibleTime(now.Add(-3 * time.Hour)), Region: "us-west-1"},
+			{ID: "4", RunAt: model.FlexibleTime(now.Add(time.Hour)), Region: "us-west-1"},
+		})
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	count, err := cli.BackfillMissed(context.Background(), BackfillOptions{Region: "us-west-1", MaxOverdueDuration: 2 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := count, 1; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if got, want := backfilled, []string{"/tasks/1/backfill"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestCompactRegion(t *testing.T) {
+	defer func(interval time.Duration) { compactPollInterval = interval }(compactPollInterval)
+	compactPollInterval = 10 * time.Millisecond
+
+	var statusCalls int32
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/admin/compact":
+			if r.URL.Query().Get("region") != "us-west-1" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.WriteHeader(http.StatusAccepted)
+		case "/admin/compact/status":
+			if atomic.AddInt32(&statusCalls, 1) < 2 {
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			json.NewEncoder(w).Encode(CompactResult{TasksBefore: 10, TasksAfter: 10, FreedBytes: 512, Duration: time.Second})
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	if _, err := cli.CompactRegion(context.Background(), "eu-west-1"); err != ErrAdminTokenRequired {
+		t.Fatalf("got %v, want %v", err, ErrAdminTokenRequired)
+	}
+	res, err := cli.CompactRegion(context.Background(), "us-west-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := *res, (CompactResult{TasksBefore: 10, TasksAfter: 10, FreedBytes: 512, Duration: time.Second}); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if got, want := atomic.LoadInt32(&statusCalls), int32(2); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+}
+
+func TestTaskDependencyChain(t *testing.T) {
+	deps := map[string][]string{"d": {"b", "c"}, "c": {"a"}, "b": {"a", "executed"}, "a": nil, "x": {"y"}, "y": {"x"}}
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/tasks/")
+		dependsOn, ok := deps[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(&model.Task{ID: id, DependsOn: dependsOn, Region: "us-west-1"})
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	chain, err := cli.TaskDependencyChain(context.Background(), "d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, tk := range chain {
+		ids = append(ids, tk.ID)
+	}
+	if got, want := ids, []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if _, err = cli.TaskDependencyChain(context.Background(), "x"); err == nil || err.(*CyclicDependencyError).Unwrap() != ErrCyclicDependency {
+		t.Fatalf("got %v, want %v", err, ErrCyclicDependency)
+	}
+}
+
+func TestSetDebug(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "debugged")
+		w.Write([]byte("[]"))
+	}))
+	defer schedulerService.Close()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	cli := newTestClient(t, schedulerService.URL)
+	if _, err := cli.ListTasks(); err != nil {
+		t.Fatal(err)
+	}
+	if logs.Len() > 0 {
+		t.Fatalf("unexpected logs: %s", logs.String())
+	}
+
+	cli.SetDebug(true)
+	req, _ := http.NewRequest(http.MethodGet, schedulerService.URL+"/tasks", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := cli.httpClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	out := logs.String()
+	for _, want := range []string{"[DEBUG] request GET", "Authorization: [redacted]", "X-Test: debugged", "[]"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in logs: %s", want, out)
+		}
+	}
+	if strings.Contains(out, "secret") {
+		t.Fatalf("token logged: %s", out)
+	}
+}
+
+func TestSetDebugRedactsSecrets(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("1"))
+	}))
+	defer schedulerService.Close()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	cli := newTestClient(t, schedulerService.URL)
+	cli.SetDebug(true)
+	if err := cli.Post(Form{Region: "us-west-1", Template: "create user name=toto password={password}", SecretEnv: map[string]string{"password": "s3cr3t"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	out := logs.String()
+	if !strings.Contains(out, `"SecretEnv":{"password":"[redacted]"}`) {
+		t.Fatalf("missing redacted secrets in logs: %s", out)
+	}
+	if strings.Contains(out, "s3cr3t") {
+		t.Fatalf("secret logged: %s", out)
+	}
+}
+
+func TestWaitUntilReady(t *testing.T) {
+	var polls int32
+	discoveryService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&polls, 1)
+		json.NewEncoder(w).Encode(&model.ServiceInfo{ServiceAddr: "http://127.0.0.1:1", QueueDepth: 4 - int(n), WorkerCount: 1, MaxWorkers: 4})
+	}))
+	defer discoveryService.Close()
+
+	cli, err := New(discoveryService.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	criteria := ReadinessCriteria{MaxQueueDepth: 1, MinWorkerAvailability: 0.5, PollInterval: 10 * time.Millisecond}
+	if err = cli.WaitUntilReady(context.Background(), criteria); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cli.ServiceInfo().QueueDepth, 1; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	criteria.MinWorkerAvailability = 0.9
+	err = cli.WaitUntilReady(ctx, criteria)
+	if err == nil || !strings.Contains(err.Error(), "min worker availability") {
+		t.Fatalf("got %v, want unmet worker availability", err)
+	}
+
+	noDiscovery, err := NewFromServiceInfo(model.ServiceInfo{ServiceAddr: "http://127.0.0.1:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err = noDiscovery.WaitUntilReady(ctx, criteria); err == nil || !strings.Contains(err.Error(), "without discovery") {
+		t.Fatalf("got %v, want missing discovery error", err)
+	}
+}
+
+func TestClientPool(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer healthy.Close()
+	unhealthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	unhealthy.Close()
+
+	var created int32
+	pool, err := NewClientPool(1, func() (*Client, error) {
+		addr := healthy.URL
+		switch atomic.AddInt32(&created, 1) {
+		case 1:
+			addr = unhealthy.URL
+		case 2:
+			return nil, errors.New("factory failure")
+		}
+		return NewFromServiceInfo(model.ServiceInfo{ServiceAddr: addr})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err = pool.Acquire(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	pool.Release(first)
+	second, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first {
+		t.Fatal("expected unhealthy client to be replaced")
+	}
+	if got, want := second.ServiceURL.String(), healthy.URL; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := atomic.LoadInt32(&created), int32(3); got != want {
+		t.Fatalf("got %d clients created, want %d", got, want)
+	}
+}
+
+func TestSetFormDefaults(t *testing.T) {
+	var mux sync.Mutex
+	var queries []url.Values
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		r.ParseForm()
+		queries = append(queries, r.URL.Query())
+		w.Write([]byte("id"))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	cli.SetFormDefaults(Form{Region: "eu-west-1", RunIn: "1h"})
+
+	if err := cli.Post(Form{Template: "create user name=toto"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.Post(Form{Region: "us-west-1", RunAtExpression: "in 2 hours", Template: "create user name=toto"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := queries[0].Get("region"), "eu-west-1"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := queries[0].Get("run"), "1h"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := queries[1].Get("region"), "us-west-1"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got := queries[1].Get("run"); got == "1h" || got == "" {
+		t.Fatalf("got run %q, want run of the expression", got)
+	}
+
+	cli.SetFormDefaults(Form{SecretEnv: map[string]string{"a": "1", "b": "2"}})
+	if got, want := cli.withFormDefaults(Form{SecretEnv: map[string]string{"b": "3"}}).SecretEnv, map[string]string{"a": "1", "b": "3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestPostWithRollback(t *testing.T) {
+	runForm := Form{Region: "us-west-1", RunIn: "1m", Template: "create user name=toto"}
+	revertForm := Form{Region: "us-west-1", RunIn: "2h", Template: "delete user name=toto"}
+
+	pairService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tasks/pair" {
+			t.Fatalf("got path %s", r.URL.Path)
+		}
+		var pair model.TaskPair
+		if err := json.NewDecoder(r.Body).Decode(&pair); err != nil {
+			t.Fatal(err)
+		}
+		run, _ := url.ParseQuery(pair.Run.Query)
+		if run.Get("run") != "1m" || pair.Run.Body != runForm.Template || pair.Revert.Body != revertForm.Template {
+			t.Fatalf("got pair %+v", pair)
+		}
+		json.NewEncoder(w).Encode(model.TaskPairIDs{RunID: "run", RevertID: "revert"})
+	}))
+	defer pairService.Close()
+
+	cli := newTestClient(t, pairService.URL)
+	runID, revertID, err := cli.PostWithRollback(context.Background(), runForm, revertForm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runID != "run" || revertID != "revert" {
+		t.Fatalf("got %s and %s", runID, revertID)
+	}
+
+	var dependsOn []string
+	legacyService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/tasks/pair" {
+			http.Error(w, "invalid method", http.StatusMethodNotAllowed)
+			return
+		}
+		r.ParseForm()
+		if deps := r.Form["depends_on"]; len(deps) > 0 {
+			dependsOn = deps
+			w.Write([]byte("revert"))
+			return
+		}
+		w.Write([]byte("run"))
+	}))
+	defer legacyService.Close()
+
+	cli = newTestClient(t, legacyService.URL)
+	if runID, revertID, err = cli.PostWithRollback(context.Background(), runForm, revertForm); err != nil {
+		t.Fatal(err)
+	}
+	if runID != "run" || revertID != "revert" {
+		t.Fatalf("got %s and %s", runID, revertID)
+	}
+	if got, want := dependsOn, []string{"run"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatalf("unexpected request %s", r.URL)
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	cli.SetBaseHeaders(http.Header{"X-Team": {"ops"}})
+	child, err := cli.NewChildClient(context.Background(), "deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := child.Inspect(Form{Region: "us-west-1", RunIn: "2m", Template: "create user name=toto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != http.MethodPost || req.URL.Path != "/tasks" || req.URL.Query().Get("run") != "2m" {
+		t.Fatalf("got %s %s", req.Method, req.URL)
+	}
+	if req.Header.Get("X-Team") != "ops" || req.Header.Get(model.TaskGroupHeader) != "deploy" {
+		t.Fatalf("got headers %v", req.Header)
+	}
+	body, _ := ioutil.ReadAll(req.Body)
+	if got, want := string(body), "create user name=toto"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if _, err = cli.Inspect(Form{Template: "create user name=toto"}); err == nil {
+		t.Fatal("expected validation error")
+	}
+
+	if req, err = cli.InspectList(ListOptions{Status: model.StatusFailed, Group: "deploy"}); err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != http.MethodGet || req.URL.Path != "/failures" || req.URL.Query().Get("group") != "deploy" || req.Header.Get("X-Team") != "ops" {
+		t.Fatalf("got %s %s with headers %v", req.Method, req.URL, req.Header)
+	}
+	if _, err = cli.InspectList(ListOptions{Status: "unknown"}); err == nil {
+		t.Fatal("expected unknown status error")
+	}
+}
+
+func TestScheduleAt(t *testing.T) {
+	var runs, reverts []string
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		runs, reverts = append(runs, r.FormValue("run")), append(reverts, r.FormValue("revert"))
+		w.Write([]byte("1"))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	runAt := time.Now().Add(time.Hour)
+	if _, err := cli.ScheduleAt(context.Background(), runAt, "us-west-1", "create user name=toto"); err != nil {
+		t.Fatal(err)
+	}
+	id, err := cli.ScheduleAtWithRevert(context.Background(), runAt, runAt.Add(time.Hour), "us-west-1", "create user name=toto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := id, "1"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := runs, []string{"1h0m0s", "1h0m0s"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := reverts, []string{"", "2h0m0s"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if _, err = cli.ScheduleAt(context.Background(), time.Now().Add(-time.Minute), "us-west-1", "create user name=toto"); err == nil {
+		t.Fatal("expected past run time error")
+	}
+	if _, err = cli.ScheduleAtWithRevert(context.Background(), runAt, runAt, "us-west-1", "create user name=toto"); err == nil {
+		t.Fatal("expected revert time error")
+	}
+	if _, err = cli.ScheduleAt(context.Background(), runAt, "", "create user name=toto"); err == nil {
+		t.Fatal("expected missing region error")
+	}
+	if got, want := len(runs), 2; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+
+	revertAt := runAt.Add(30 * time.Minute)
+	if _, err = cli.PostAt(context.Background(), runAt, nil, "us-west-1", "create user name=toto"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cli.PostAt(context.Background(), runAt, &revertAt, "us-west-1", "create user name=toto"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := reverts[2:], []string{"", "1h30m0s"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestPostMultiStep(t *testing.T) {
+	var mux sync.Mutex
+	var posted []url.Values
+	var groups []string
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		mux.Lock()
+		defer mux.Unlock()
+		posted = append(posted, r.Form)
+		groups = append(groups, r.Header.Get(model.TaskGroupHeader))
+		fmt.Fprintf(w, "task-%d", len(posted))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	res, err := cli.PostMultiStep(context.Background(), Pipeline{Name: "deploy", Region: "us-west-1", Steps: []PipelineStep{
+		{Name: "pre-checks", Template: "check instance id=1 state=running timeout=10", RunOffsetFromPrevious: time.Minute, OnFailure: FailureContinue},
+		{Name: "apply", Template: "create user name=toto", RunOffsetFromPrevious: time.Minute, RevertIn: time.Hour},
+		{Name: "notify", Template: "create topic name=done", RunOffsetFromPrevious: time.Minute},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PipelineStepResult{{"pre-checks", "task-1"}, {"apply", "task-2"}, {"notify", "task-3"}}
+	if !reflect.DeepEqual(res.Steps, want) {
+		t.Fatalf("got %v, want %v", res.Steps, want)
+	}
+	if posted[0].Get("run") != "1m0s" || posted[1].Get("run") != "2m0s" || posted[1].Get("revert") != "1h2m0s" || posted[2].Get("run") != "3m0s" {
+		t.Fatalf("got %v", posted)
+	}
+	// pre-checks continue on failure
+	if len(posted[1]["depends_on"]) != 0 || !reflect.DeepEqual(posted[2]["depends_on"], []string{"task-2"}) {
+		t.Fatalf("got dependencies %v and %v", posted[1]["depends_on"], posted[2]["depends_on"])
+	}
+	if got, want := groups, []string{"deploy", "deploy", "deploy"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	_, err = cli.PostMultiStep(context.Background(), Pipeline{Region: "us-west-1", Steps: []PipelineStep{
+		{Name: "apply", Template: "create user name=toto"},
+		{Name: "notify", Template: "create topic name=done", OnFailure: FailurePolicy(2)},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "step notify: unsupported failure policy") {
+		t.Fatalf("got %v, want unsupported policy error", err)
+	}
+	if _, err = cli.PostMultiStep(context.Background(), Pipeline{Region: "us-west-1"}); err == nil {
+		t.Fatal("expected empty pipeline error")
+	}
+}
+
+func TestGetHistory(t *testing.T) {
+	var limits []string
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tasks/1/history":
+			limits = append(limits, r.URL.Query().Get("limit"))
+			json.NewEncoder(w).Encode([]*model.HistoryEntry{
+				{RunID: "run-2", Status: model.StatusFailed, ExitCode: 1, OutputSnippet: "access denied"},
+				{RunID: "run-1", Status: model.StatusDone},
+			})
+		case "/tasks/2/history":
+			w.Write([]byte("null"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	entries, err := cli.GetHistory(context.Background(), "1", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].RunID != "run-2" || entries[0].ExitCode != 1 || entries[1].Status != model.StatusDone {
+		t.Fatalf("got %+v", entries)
+	}
+	if _, err = cli.GetHistory(context.Background(), "1", 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := limits, []string{"2", ""}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	if entries, err = cli.GetHistory(context.Background(), "2", 0); err != nil {
+		t.Fatal(err)
+	}
+	if entries == nil || len(entries) != 0 {
+		t.Fatalf("got %#v, want empty entries", entries)
+	}
+	if _, err = cli.GetHistory(context.Background(), "3", 0); err == nil {
+		t.Fatal("expected error for unknown task")
+	}
+}
+
+func TestGetReverted(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tasks/1/history":
+			json.NewEncoder(w).Encode([]*model.HistoryEntry{
+				{RunID: "2-1", Status: model.StatusReverted, RevertTaskID: "2"},
+				{RunID: "1-1", Status: model.StatusDone},
+			})
+		case "/tasks/3/history":
+			json.NewEncoder(w).Encode([]*model.HistoryEntry{{RunID: "3-1", Status: model.StatusDone}})
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	reverted, entry, err := cli.GetReverted(context.Background(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reverted || entry.RevertTaskID != "2" {
+		t.Fatalf("got %t and %+v", reverted, entry)
+	}
+	if reverted, entry, err = cli.GetReverted(context.Background(), "3"); err != nil || reverted || entry != nil {
+		t.Fatalf("got %t, %+v and %v", reverted, entry, err)
+	}
+	if _, _, err = cli.GetReverted(context.Background(), "4"); err == nil {
+		t.Fatal("expected server error")
+	}
+}
+
+func TestPostIdempotent(t *testing.T) {
+	var posts int32
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			atomic.AddInt32(&posts, 1)
+			w.Write([]byte("new"))
+			return
+		}
+		json.NewEncoder(w).Encode([]*model.Task{
+			{ID: "other-region", Content: "create user name=toto", Region: "eu-west-1"},
+			{ID: "existing", Content: "create  user name=toto\n\ncreate group name=admins\n", Region: "us-west-1"},
+		})
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	id, existed, err := cli.PostIdempotent(context.Background(), Form{Region: "us-west-1", Template: "  create user name=toto\ncreate group  name=admins"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "existing" || !existed {
+		t.Fatalf("got %s and %t", id, existed)
+	}
+
+	id, existed, err = cli.PostIdempotent(context.Background(), Form{Region: "us-west-1", Template: "create user name=toto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "new" || existed {
+		t.Fatalf("got %s and %t", id, existed)
+	}
+	if got, want := atomic.LoadInt32(&posts), int32(1); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+}
+
+func TestListMine(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tasks" && r.URL.Path != "/failures" {
+			t.Fatalf("unexpected request %s", r.URL)
+		}
+		if got, want := r.URL.Query().Get("owner"), "alice"; got != want {
+			t.Fatalf("got owner %q, want %q", got, want)
+		}
+		json.NewEncoder(w).Encode([]*model.Task{{ID: "mine", Owner: "alice"}, {ID: "other", Owner: "bob"}})
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	if _, err := cli.ListMine(context.Background()); err == nil {
+		t.Fatal("expected no identity error")
+	}
+	WithBearerToken("opaque")(cli)
+	if _, err := cli.ListMine(context.Background()); err == nil {
+		t.Fatal("expected no identity error with opaque token")
+	}
+
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"alice"}`))
+	WithBearerToken("eyJhbGciOiJIUzI1NiJ9." + claims + ".sig")(cli)
+	tasks, err := cli.ListMine(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 || tasks[0].ID != "mine" || tasks[1].ID != "mine" {
+		t.Fatalf("got %v", tasks)
+	}
+
+	req, err := cli.Inspect(Form{Region: "us-west-1", Template: "create user name=toto"}, WithConfirmation(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := req.URL.Query().Get("owner"), "alice"; got != want {
+		t.Fatalf("got owner %q, want %q", got, want)
+	}
+	if req, err = cli.Inspect(Form{Region: "us-west-1", Template: "create user name=toto", Owner: "ci"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := req.URL.Query().Get("owner"), "ci"; got != want {
+		t.Fatalf("got owner %q, want %q", got, want)
+	}
+
+	forwarded := cli.ForwardAuth(context.Background(), "eyJhbGciOiJIUzI1NiJ9."+base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"carol"}`))+".sig")
+	if got, want := forwarded.identity(), "carol"; got != want {
+		t.Fatalf("got identity %q, want %q", got, want)
+	}
+}
+
+func TestPostPreview(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatalf("unexpected request %s", r.URL)
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	cli.SetFormDefaults(Form{Vars: map[string]string{"name": "toto", "unused": "x"}})
+
+	f := Form{Region: "us-west-1", Template: "create user name={name} password={secret.password}\ncreate group name={group}", Vars: map[string]string{"group": "admins"}}
+	preview, err := cli.PostPreview(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := preview, "create user name=toto password={secret.password}\ncreate group name=admins"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if preview, err = cli.PostPreview(context.Background(), Form{Template: "create group name=admins"}); err != nil || preview != "create group name=admins" {
+		t.Fatalf("got %q, %v", preview, err)
+	}
+	if preview, err = (Form{Template: "create user name={name}"}).Preview(); err != nil || preview != "create user name={name}" {
+		t.Fatalf("got %q, %v", preview, err)
+	}
+
+	f.Vars["typo"] = "x"
+	if _, err = f.Preview(); err == nil || !strings.Contains(err.Error(), "typo") {
+		t.Fatalf("got %v", err)
+	}
+	if err = f.Validate(); err == nil {
+		t.Fatal("expected validation error")
+	}
+}
+
+func TestPostRecurring(t *testing.T) {
+	var query url.Values
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write([]byte("1"))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	if _, err := cli.PostRecurring(context.Background(), "0 25 * * *", "us-west-1", "create user name=toto", RecurringOptions{}); err == nil {
+		t.Fatal("expected cron error")
+	}
+	if query != nil {
+		t.Fatalf("unexpected request with %v", query)
+	}
+
+	id, err := cli.PostRecurring(context.Background(), "*/5 * * * *", "us-west-1", "create user name=toto", RecurringOptions{
+		MaxRetries:  2,
+		Tags:        map[string]string{"team": "ops", "env": "prod"},
+		Description: "rotate users",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "1" {
+		t.Fatalf("got %s, want 1", id)
+	}
+	if got, want := query.Get("cron"), "*/5 * * * *"; got != want {
+		t.Fatalf("got cron %q, want %q", got, want)
+	}
+	if query.Get("max_retries") != "2" || query.Get("description") != "rotate users" {
+		t.Fatalf("got %v", query)
+	}
+	if got, want := query["tag"], []string{"env=prod", "team=ops"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got tags %v, want %v", got, want)
+	}
+	if run, err := time.ParseDuration(query.Get("run")); err != nil || run <= 0 || run > 5*time.Minute {
+		t.Fatalf("got run %q, %v", query.Get("run"), err)
+	}
+
+	if err = (Form{Region: "us-west-1", Template: "create user name=toto", Tags: map[string]string{"a=b": "c"}}).Validate(); err == nil {
+		t.Fatal("expected tag key error")
+	}
+}
+
+func TestGetStatus(t *testing.T) {
+	var supported bool
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tasks/1/status":
+			if !supported {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{"status":"done"}`))
+		case "/tasks/1":
+			json.NewEncoder(w).Encode(&model.Task{ID: "1", Content: "create user name=toto", Status: model.StatusPending})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	status, err := cli.GetStatus(context.Background(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := status, model.StatusPending; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if _, err = cli.GetStatus(context.Background(), "2"); err != ErrNotFound {
+		t.Fatalf("got %v, want %v", err, ErrNotFound)
+	}
+
+	supported = true
+	if status, err = cli.GetStatus(context.Background(), "1"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := status, model.StatusDone; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestListByStatus(t *testing.T) {
+	now := time.Now().UTC()
+	var paths []string
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/tasks":
+			json.NewEncoder(w).Encode([]*model.Task{
+				{ID: "running", RunAt: model.FlexibleTime(now), Region: "us-west-1", Status: model.StatusRunning},
+				{ID: "pending", RunAt: model.FlexibleTime(now.Add(time.Hour)), Region: "us-west-1", Status: model.StatusPending},
+			})
+		case "/done":
+			json.NewEncoder(w).Encode([]*model.Task{{ID: "done", RunAt: model.FlexibleTime(now.Add(-time.Hour)), Region: "us-west-1"}})
+		case "/failures":
+			json.NewEncoder(w).Encode([]*model.Task{{ID: "failed", RunAt: model.FlexibleTime(now.Add(-time.Hour)), Region: "us-west-1"}})
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	if _, err := cli.ListByStatus(context.Background(), "unknown"); err == nil || len(paths) > 0 {
+		t.Fatalf("expected unknown status error without request, got %v after %v", err, paths)
+	}
+	for _, tcase := range []struct {
+		list func(context.Context) ([]*model.Task, error)
+		want string
+	}{
+		{cli.ListPending, "pending"},
+		{cli.ListRunning, "running"},
+		{cli.ListDone, "done"},
+		{cli.ListFailed, "failed"},
+	} {
+		tasks, err := tcase.list(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tasks) != 1 || tasks[0].ID != tcase.want || tasks[0].Status != tcase.want {
+			t.Fatalf("got %v, want task %s", tasks, tcase.want)
+		}
+	}
+}
+
+func TestPatchTags(t *testing.T) {
+	var patches []model.TagPatch
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch {
+			t.Fatalf("unexpected request %s %s", r.Method, r.URL)
+		}
+		if r.URL.Path != "/tasks/1/tags" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"TASK_NOT_FOUND","message":"task not found"}`))
+			return
+		}
+		var patch model.TagPatch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			t.Fatal(err)
+		}
+		patches = append(patches, patch)
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	if err := cli.PatchTags(context.Background(), "1", map[string]string{"deployed": "true"}, map[string]string{"stage": "", "env": ""}); err != nil {
+		t.Fatal(err)
+	}
+	want := []model.TagPatch{{Add: map[string]string{"deployed": "true"}, Remove: []string{"env", "stage"}}}
+	if !reflect.DeepEqual(patches, want) {
+		t.Fatalf("got %#v, want %#v", patches, want)
+	}
+
+	if err := cli.PatchTags(context.Background(), "1", map[string]string{"env": "prod"}, map[string]string{"env": ""}); err != model.ErrConflictingTagOperation {
+		t.Fatalf("got %v, want %v", err, model.ErrConflictingTagOperation)
+	}
+	if err := cli.PatchTags(context.Background(), "2", map[string]string{"deployed": "true"}, nil); err != ErrNotFound {
+		t.Fatalf("got %v, want %v", err, ErrNotFound)
+	}
+	if len(patches) != 1 {
+		t.Fatalf("got %d patches, want 1", len(patches))
+	}
+}
+
+func TestPostWithConfirmation(t *testing.T) {
+	var gets int32
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost:
+			w.Write([]byte("1"))
+		case r.URL.Path == "/tasks/1" && atomic.AddInt32(&gets, 1) > 2:
+			json.NewEncoder(w).Encode(&model.Task{ID: "1", Content: "create user name=toto", Status: model.StatusPending})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	f := Form{Region: "us-west-1", Template: "create user name=toto"}
+
+	if err := cli.Post(f, WithConfirmation(2*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := atomic.LoadInt32(&gets), int32(3); got != want {
+		t.Fatalf("got %d gets, want %d", got, want)
+	}
+
+	atomic.StoreInt32(&gets, -100)
+	err := cli.Post(f, WithConfirmation(300*time.Millisecond))
+	timeoutErr, ok := err.(*ErrConfirmationTimeout)
+	if !ok {
+		t.Fatalf("got %v, want confirmation timeout", err)
+	}
+	if got, want := timeoutErr.TaskID, "1"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestListWithDeadline(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tasks" {
+			w.Write([]byte("[]"))
+			return
+		}
+		json.NewEncoder(w).Encode([]*model.Task{
+			{ID: "1", Region: "us-west-1"},
+			{ID: "2", Region: "eu-west-1"},
+		})
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	tasks, err := cli.ListWithDeadline(time.Now().Add(time.Second), ListOptions{Region: "eu-west-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != "2" {
+		t.Fatalf("got %+v", tasks)
+	}
+
+	if _, err = cli.ListWithDeadline(time.Now().Add(-time.Second), ListOptions{}); err == nil {
+		t.Fatal("expected error listing past deadline")
+	}
+}
+
+func TestListOverdue(t *testing.T) {
+	now := time.Now().UTC()
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tasks" {
+			t.Fatalf("unexpected listing of %s", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode([]*model.Task{
+			{ID: "1", RunAt: model.FlexibleTime(now.Add(time.Hour))},
+			{ID: "2", RunAt: model.FlexibleTime(now.Add(-time.Minute))},
+			{ID: "3", RunAt: model.FlexibleTime(now.Add(-time.Hour))},
+		})
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	tasks, err := cli.ListOverdue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, tk := range tasks {
+		ids = append(ids, tk.ID)
+	}
+	if got, want := ids, []string{"3", "2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got := tasks[0].OverdueDuration(); got < time.Hour || got > time.Hour+time.Minute {
+		t.Fatalf("got overdue duration %s, want about 1h", got)
+	}
+}
+
+func TestListWithTimeout(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		json.NewEncoder(w).Encode([]*model.Task{{ID: "1"}})
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	if _, err := cli.ListWithTimeout(20*time.Millisecond, ListOptions{Status: model.StatusPending}); err == nil {
+		t.Fatal("expected timeout error")
+	}
+
+	tasks, err := cli.ListWithTimeout(0, ListOptions{Status: model.StatusPending})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != "1" {
+		t.Fatalf("got %+v", tasks)
+	}
+}
+
+func TestUpdateContent(t *testing.T) {
+	var content string
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch {
+			t.Fatalf("unexpected request %s %s", r.Method, r.URL)
+		}
+		switch r.URL.Path {
+		case "/tasks/1":
+			var patch struct{ Content string }
+			json.NewDecoder(r.Body).Decode(&patch)
+			content = patch.Content
+			w.Write([]byte("2"))
+		case "/tasks/3":
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error":"TASK_RUNNING","message":"task '3' is running"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	if err := cli.UpdateContent(context.Background(), "1", "create user name=titi"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := content, "create user name=titi"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if err := cli.UpdateContent(context.Background(), "1", " "); err == nil {
+		t.Fatal("expected error on empty template")
+	}
+	if err := cli.UpdateContent(context.Background(), "3", "create user name=titi"); err != ErrTaskAlreadyRunning {
+		t.Fatalf("got %v, want %v", err, ErrTaskAlreadyRunning)
+	}
+	if err := cli.UpdateContent(context.Background(), "4", "create user name=titi"); err != ErrNotFound {
+		t.Fatalf("got %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestGetWithFallback(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch id := strings.TrimPrefix(r.URL.Path, "/tasks/"); id {
+		case "green":
+			json.NewEncoder(w).Encode(&model.Task{ID: id, Region: "us-west-1"})
+		case "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	tk, id, err := cli.GetWithFallback(context.Background(), "blue", "green")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "green" || tk.ID != "green" {
+		t.Fatalf("got %s and task %s, want green", id, tk.ID)
+	}
+	if _, _, err = cli.GetWithFallback(context.Background(), "blue", "red"); err != ErrNotFound {
+		t.Fatalf("got %v, want %v", err, ErrNotFound)
+	}
+	if _, _, err = cli.GetWithFallback(context.Background(), "broken", "green"); err == nil || err == ErrNotFound {
+		t.Fatalf("got %v, want server error", err)
+	}
+}
+
+func TestScheduleWindowConflicts(t *testing.T) {
+	now := time.Now().UTC()
+	policy := "arn:aws:iam::aws:policy/AdministratorAccess"
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]*model.Task{
+			{ID: "1", Content: "attach policy arn=" + policy + " user=toto", RunAt: model.FlexibleTime(now.Add(50 * time.Minute)), Region: "us-west-1"},
+			{ID: "2", Content: "attach policy arn=" + policy + " user=tata", RunAt: model.FlexibleTime(now.Add(3 * time.Hour)), Region: "us-west-1"},
+			{ID: "3", Content: "attach policy arn=arn:aws:iam::aws:policy/ReadOnlyAccess user=toto", RunAt: model.FlexibleTime(now.Add(time.Hour)), Region: "us-west-1"},
+			{ID: "4", Content: "attach policy arn=" + policy + " user=titi", RunAt: model.FlexibleTime(now.Add(time.Hour)), Region: "eu-west-1"},
+		})
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	f := Form{Region: "us-west-1", RunIn: "1h", Template: "detach policy arn=" + policy + " user=toto"}
+	conflicts, err := cli.ScheduleWindowConflicts(context.Background(), f, 15*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 || conflicts[0].ID != "1" {
+		t.Fatalf("got %v, want task 1 only", conflicts)
+	}
+}
+
+func TestRequestStats(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/tasks/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("[]"))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	cli = cli.apply([]ClientOption{WithCacheTTL(time.Minute)})
+
+	for i := 0; i < 2; i++ {
+		if _, err := cli.ListTasks(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cli.Get(context.Background(), "missing")
+
+	stats := cli.RequestStats()
+	if got, want := stats, (RequestStats{Total: 2, Successful: 1, Failed: 1, CacheHits: 1, counters: stats.counters}); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	stats.Reset()
+	if got, want := cli.RequestStats().Total, int64(0); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+}
+
+func TestParseRunAtExpression(t *testing.T) {
+	now := time.Date(2017, 6, 14, 10, 30, 0, 0, time.UTC) // a wednesday
+	tcases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"now", now},
+		{"in 2 days", now.Add(48 * time.Hour)},
+		{"In 1 minute", now.Add(time.Minute)},
+		{"tomorrow", time.Date(2017, 6, 15, 0, 0, 0, 0, time.UTC)},
+		{"tomorrow at 3pm", time.Date(2017, 6, 15, 15, 0, 0, 0, time.UTC)},
+		{"next monday at 09:30", time.Date(2017, 6, 19, 9, 30, 0, 0, time.UTC)},
+		{"next wednesday", time.Date(2017, 6, 21, 0, 0, 0, 0, time.UTC)},
+		{"2017-07-01T12:00:00Z", time.Date(2017, 7, 1, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tcase := range tcases {
+		got, err := ParseRunAtExpression(tcase.expr, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(tcase.want) {
+			t.Fatalf("%s: got %s, want %s", tcase.expr, got, tcase.want)
+		}
+	}
+	for _, expr := range []string{"later", "in two days", "next month", "tomorrow at noon"} {
+		if _, err := ParseRunAtExpression(expr, now); err == nil {
+			t.Fatalf("%s: expected error", expr)
+		}
+	}
+	if err := (Form{Region: "us-west-1", RunIn: "1h", RunAtExpression: "tomorrow"}).Validate(); err == nil {
+		t.Fatal("expected error with both run duration and expression")
+	}
+}
+
+func TestLintTemplateLocally(t *testing.T) {
+	schedulerService := httptest.NewServer(http.NotFoundHandler())
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	tpl := strings.Join([]string{
+		"# create a user",
+		"user = create user name=toto",
+		"attach policy user=$user arn=arn:aws:iam::aws:policy/ReadOnlyAccess",
+		"attach policy user=$usr arn=arn:oops",
+		"not a command",
+	}, "\n")
+	res, err := cli.LintTemplate(context.Background(), tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Valid || res.Source != "client-side" {
+		t.Fatalf("got %+v, want invalid client side result", res)
+	}
+	var lines []string
+	for _, e := range res.Errors {
+		lines = append(lines, fmt.Sprintf("%d:%s", e.Line, e.Message))
+	}
+	want := "4:reference to undefined variable 'usr',4:malformed ARN 'arn:oops',5:expected '<action> <entity> [param=value ...]'"
+	if got := strings.Join(lines, ","); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestSelfTest(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/version":
+			http.NotFound(w, r)
+		case "/regions":
+			w.Write([]byte(`["eu-west-1"]`))
+		case "/lint":
+			json.NewEncoder(w).Encode(model.LintResult{Valid: true})
+		case "/noop":
+			fmt.Fprintf(w, `{"Region": "%s", "Template": "%s"}`, r.URL.Query().Get("region"), selfTestTemplate)
+		default:
+			w.Write([]byte("[]"))
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	report, err := cli.SelfTest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failed []string
+	for _, res := range report.Results {
+		if !res.Passed {
+			failed = append(failed, res.Name)
+		}
+	}
+	if got, want := strings.Join(failed, ","), "Version"; got != want || len(report.Results) != 6 {
+		t.Fatalf("got %s failed out of %d, want %s out of 6", got, len(report.Results), want)
+	}
+}
+
+func TestMultiClientPrefersFastestEndpoint(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("[]"))
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	}))
+	defer fast.Close()
+
+	var discoveries []string
+	for _, s := range []*httptest.Server{slow, fast} {
+		addr := s.URL
+		d := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := json.Marshal(&model.ServiceInfo{ServiceAddr: addr})
+			w.Write(b)
+		}))
+		defer d.Close()
+		discoveries = append(discoveries, d.URL)
+	}
+
+	cli, err := NewMulti(discoveries...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(cli.EndpointScores()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, want := len(cli.EndpointScores()), 2; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+
+	if addr := cli.serviceURL(); addr.String() != fast.URL {
+		t.Fatalf("got %s, want %s", addr.String(), fast.URL)
+	}
+}
+
+func TestStaleEndpointScoreDecaysToNeutral(t *testing.T) {
+	slow, _ := url.Parse("http://slow:8082")
+	fast, _ := url.Parse("http://fast:8082")
+	eps := &endpoints{urls: []*url.URL{slow, fast}, measuring: 1}
+	now := time.Now()
+	eps.scores.Store(slow.String(), endpointScore{latency: time.Second, measuredAt: now.Add(-10 * scoreHalfLife), live: true})
+	eps.scores.Store(fast.String(), endpointScore{latency: 10 * time.Millisecond, measuredAt: now, live: true})
+
+	best, ok := eps.best()
+	if !ok {
+		t.Fatal("expected a live endpoint")
+	}
+	if got, want := best.String(), fast.String(); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestScheduleLimits(t *testing.T) {
+	cli, err := NewFromServiceInfo(model.ServiceInfo{ServiceAddr: "http://localhost:9096"}, WithMaxScheduleHorizon(DefaultMaxScheduleHorizon), WithMinRunIn(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = cli.Post(Form{Region: "us-west-1", RunIn: "43800h", Template: "create user name=toto"}); err != ErrScheduleTooFarFuture {
+		t.Fatalf("got %v, want %v", err, ErrScheduleTooFarFuture)
+	}
+	if err = cli.Post(Form{Region: "us-west-1", Template: "create user name=toto"}); err != ErrScheduleTooSoon {
+		t.Fatalf("got %v, want %v", err, ErrScheduleTooSoon)
+	}
+}
+
+func TestPostAllRegions(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/regions":
+			w.Write([]byte(`["eu-west-1","us-east-1","us-west-1"]`))
+		case "/tasks":
+			if region := r.FormValue("region"); region == "us-east-1" {
+				http.Error(w, "cannot init drivers", http.StatusInternalServerError)
+			} else {
+				w.Write([]byte("id-" + region))
+			}
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	if _, err := cli.PostAllRegions(Form{Region: "us-west-1", Template: "create user name=toto"}); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if err := cli.Post(Form{AllRegions: true, Template: "create user name=toto"}); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	ids, err := cli.PostAllRegions(Form{Template: "create user name=toto"})
+	multiErr, ok := err.(*MultiRegionPostError)
+	if !ok {
+		t.Fatalf("got %T, want *MultiRegionPostError", err)
+	}
+	if _, ok := multiErr.Failures["us-east-1"]; !ok {
+		t.Fatalf("expected us-east-1 failure in %s", multiErr)
+	}
+	if got, want := len(ids), 2; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if got, want := ids[1], "id-us-west-1"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestListUpcoming(t *testing.T) {
+	now := time.Now().UTC()
+	tasks := []*model.Task{
+		{ID: "later", Content: "create user name=toto", RunAt: model.FlexibleTime(now.Add(2 * time.Hour)), Region: "us-west-1"},
+		{ID: "sooner", Content: "create user name=tata", RunAt: model.FlexibleTime(now.Add(1 * time.Hour)), Region: "us-west-1"},
+		{ID: "missed", Content: "create user name=titi", RunAt: model.FlexibleTime(now.Add(-1 * time.Hour)), Region: "us-west-1"},
+	}
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tasks":
+			json.NewEncoder(w).Encode(tasks)
+		case "/failures":
+			w.Write([]byte("[]"))
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	upcoming, err := cli.ListUpcoming(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(upcoming), 2; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if got, want := upcoming[0].ID, "sooner"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := upcoming[0].Status, model.StatusPending; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	upcoming, err = cli.ListUpcoming(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(upcoming), 1; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+}
+
+func TestMultiRegionList(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"ID": "a", "Region": "us-west-1"}, {"ID": "b", "Region": "eu-west-1"}, {"ID": "c", "Region": "eu-west-1"}]`))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	results, err := cli.MultiRegionList(ctx, []string{"us-west-1", "eu-west-1", "ap-south-1"}, ListOptions{Status: model.StatusPending, MaxConcurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for region, want := range map[string]int{"us-west-1": 1, "eu-west-1": 2, "ap-south-1": 0} {
+		if got := len(results[region]); got != want {
+			t.Fatalf("%s: got %d, want %d", region, got, want)
+		}
+	}
+}
+
+func TestDependencyGraph(t *testing.T) {
+	g := NewDependencyGraph([]*model.Task{
+		{ID: "c", DependsOn: []string{"b"}},
+		{ID: "b", DependsOn: []string{"a", "executed"}},
+		{ID: "a"},
+	})
+
+	if got, want := len(g.Roots()), 1; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if got, want := g.Children("a")[0].ID, "b"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	order, err := g.TopologicalOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, tk := range order {
+		ids = append(ids, tk.ID)
+	}
+	if got, want := fmt.Sprint(ids), "[a b c]"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	g = NewDependencyGraph([]*model.Task{
+		{ID: "a", DependsOn: []string{"c"}},
+		{ID: "b", DependsOn: []string{"a"}},
+		{ID: "c", DependsOn: []string{"b"}},
+	})
+	_, err = g.TopologicalOrder()
+	cyclic, ok := err.(*CyclicDependencyError)
+	if !ok {
+		t.Fatalf("got %T, want *CyclicDependencyError", err)
+	}
+	if got, want := fmt.Sprint(cyclic.Cycle), "[a c b a]"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestFormChain(t *testing.T) {
+	var posted []url.Values
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted = append(posted, r.URL.Query())
+		fmt.Fprintf(w, "id-%d", len(posted))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	chain, err := NewFormChain(
+		Form{Region: "us-west-1", Template: "create group name=admins"},
+		Form{Template: "create user name=toto"},
+		Form{Region: "eu-west-1", Template: "attach user name=toto group=admins"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := chain.Submit(context.Background(), cli)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(ids, ","), "id-1,id-2,id-3"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := posted[1].Get("region"), "us-west-1"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := posted[2].Get("depends_on"), "id-2"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	_, err = NewFormChain(
+		Form{Region: "us-west-1", Template: "create group name=admins", DependsOn: []string{ChainPlaceholder(1)}},
+		Form{Template: "create user name=toto"},
+	)
+	if _, ok := err.(*CyclicDependencyError); !ok {
+		t.Fatalf("got %v, want cyclic dependency error", err)
+	}
+}
+
+func TestWaitForAll(t *testing.T) {
+	var polls int32
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tasks/failing":
+			json.NewEncoder(w).Encode(&model.Task{ID: "failing", Status: model.StatusFailed})
+		case "/tasks/running":
+			if atomic.AddInt32(&polls, 1) < 3 {
+				json.NewEncoder(w).Encode(&model.Task{ID: "running", Status: model.StatusPending})
+				return
+			}
+			http.NotFound(w, r)
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	results, err := cli.WaitForAll(ctx, []string{"failing", "running"}, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := results["failing"].Status, model.StatusFailed; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := results["running"].Status, model.StatusDone; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := atomic.LoadInt32(&polls), int32(3); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+}
+
+func TestServerErrorParsing(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tasks":
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error": "CONFLICT", "message": "task already exists"}`))
+		case "/failures":
+			http.Error(w, "plain failure", http.StatusInternalServerError)
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	_, err := cli.ListTasks()
+	serverErr, ok := err.(*ServerError)
+	if !ok {
+		t.Fatalf("got %T, want *ServerError", err)
+	}
+	if got, want := serverErr.HTTPStatus, http.StatusConflict; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if got, want := serverErr.Unwrap(), ErrConflict; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if _, err = cli.ListFailures(); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if _, ok := err.(*ServerError); ok {
+		t.Fatalf("got %T, want plain error", err)
+	}
+}
+
+func TestSetBaseHeaders(t *testing.T) {
+	var token atomic.Value
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token.Store(r.Header.Get("Authorization"))
+		w.Write([]byte("[]"))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	headers := http.Header{"Authorization": []string{"Bearer first"}}
+	cli.SetBaseHeaders(headers)
+	headers.Set("Authorization", "Bearer mutated")
+
+	if _, err := cli.ListTasks(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := token.Load(), "Bearer first"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	cli.SetBaseHeaders(http.Header{"Authorization": []string{"Bearer second"}})
+	if _, err := cli.ListTasks(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := token.Load(), "Bearer second"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestRenewToken(t *testing.T) {
+	var token atomic.Value
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer expired" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		token.Store(r.Header.Get("Authorization"))
+		w.Write([]byte("[]"))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	if err := cli.RenewToken(context.Background(), "fresh"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.RenewToken(context.Background(), "expired"); err != ErrTokenRejected {
+		t.Fatalf("got %v, want %v", err, ErrTokenRejected)
+	}
+	if _, err := cli.ListTasks(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := token.Load(), "Bearer fresh"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestForwardAuth(t *testing.T) {
+	var token atomic.Value
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token.Store(r.Header.Get("Authorization"))
+		w.Write([]byte("[]"))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	cli.SetBaseHeaders(http.Header{"Authorization": []string{"Bearer base"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	forwarded := cli.ForwardAuth(ctx, "incoming")
+	if _, err := forwarded.ListTasks(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := token.Load(), "Bearer incoming"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	if _, err := cli.ListTasks(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := token.Load(), "Bearer base"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	cancel()
+	if _, err := forwarded.ListTasks(); err == nil {
+		t.Fatal("expected error once forwarding context is done")
+	}
+}
+
+func TestForwardAuthSharesCache(t *testing.T) {
+	var lists int32
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.Write([]byte("new-id"))
+			return
+		}
+		atomic.AddInt32(&lists, 1)
+		w.Write([]byte(`[{"ID": "id", "Content": "create user name=toto", "Region": "us-west-1"}]`))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	WithCacheTTL(time.Minute)(cli)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	forwarded := cli.ForwardAuth(ctx, "token")
+
+	cli.ListTasks()
+	forwarded.ListTasks()
+	if got, want := atomic.LoadInt32(&lists), int32(1); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if err := forwarded.Post(Form{Region: "us-west-1", Template: "create user name=toto"}); err != nil {
+		t.Fatal(err)
+	}
+	cli.ListTasks()
+	if got, want := atomic.LoadInt32(&lists), int32(2); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	var lists int32
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.Write([]byte("new-id"))
+			return
+		}
+		atomic.AddInt32(&lists, 1)
+		w.Write([]byte(`[{"ID": "id", "Content": "create user name=toto", "Region": "us-west-1"}]`))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	WithCacheTTL(time.Minute)(cli)
+
+	for i := 0; i < 2; i++ {
+		tasks, err := cli.ListTasks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tasks[0].Status = "mutated"
+	}
+	tasks, _ := cli.ListTasks()
+	if got, want := atomic.LoadInt32(&lists), int32(1); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if got, want := tasks[0].Status, ""; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	cli.ListFailures()
+	if err := cli.Post(Form{Region: "us-west-1", Template: "create user name=toto"}); err != nil {
+		t.Fatal(err)
+	}
+	cli.ListTasks()
+	cli.ListFailures()
+	if got, want := atomic.LoadInt32(&lists), int32(4); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+
+	cli.ClearCache()
+	cli.ListTasks()
+	if got, want := atomic.LoadInt32(&lists), int32(5); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+}
+
+func TestObserveRequests(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`["us-west-1", "eu-west-1"]`))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	WithObservedBodySize(5)(cli)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first, second := cli.ObserveRequests(ctx), cli.ObserveRequests(ctx)
+
+	if _, err := cli.ListRegions(); err != nil {
+		t.Fatal(err)
+	}
+	for _, observations := range []<-chan RequestObservation{first, second} {
+		obs := <-observations
+		if got, want := obs.URL, schedulerService.URL+"/regions"; got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+		if got, want := string(obs.Body), `["us-`; got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	}
+
+	cancel()
+	if _, ok := <-first; ok {
+		t.Fatal("expected observations channel to be closed")
+	}
+}
+
+type recordingExecutor chan *model.Task
+
+func (e recordingExecutor) Execute(ctx context.Context, tk *model.Task) error {
+	e <- tk
+	return nil
+}
+
+func TestListenAndExecute(t *testing.T) {
+	cli, err := NewFromServiceInfo(model.ServiceInfo{ServiceAddr: "http://localhost:9096"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	executed := make(recordingExecutor, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- cli.ListenAndExecute(ctx, executed) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for cli.Ping() != nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	first, err := cli.PostAt(context.Background(), time.Now().Add(time.Hour), nil, "us-west-1", "create group name=admins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cli.Post(Form{Region: "us-west-1", Template: "create user name=toto"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case tk := <-executed:
+		if got, want := tk.Content, "create user name=toto"; got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("task not executed")
+	}
+
+	tasks, err := cli.ListTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != first {
+		t.Fatalf("got %v, want only task %s pending", tasks, first)
+	}
+
+	cancel()
+	<-done
+	if err = cli.Ping(); err == nil {
+		t.Fatal("expected requests to go back to the scheduler")
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	now := time.Now().UTC()
+	var posted []url.Values
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/tasks" && r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode([]*model.Task{
+				{ID: "child", Content: "create user name=toto", RunAt: model.FlexibleTime(now.Add(2 * time.Hour)), Region: "us-west-1", DependsOn: []string{"parent"}},
+				{ID: "parent", Content: "create group name=admins", RunAt: model.FlexibleTime(now.Add(1 * time.Hour)), RevertAt: model.FlexibleTime(now.Add(3 * time.Hour)), Region: "us-west-1"},
+			})
+		case r.URL.Path == "/tasks" && r.Method == http.MethodPost:
+			posted = append(posted, r.URL.Query())
+			w.Write([]byte(fmt.Sprintf("new-%d", len(posted))))
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	snap, err := cli.Snapshot(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &Snapshot{}
+	if err = json.Unmarshal(b, decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = cli.Restore(context.Background(), decoded, RestoreOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(posted), 2; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if got, want := posted[0].Get("revert"), ""; got == want {
+		t.Fatalf("expected revert on parent task")
+	}
+	if got, want := posted[1].Get("depends_on"), "new-1"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestRateLimitRetry(t *testing.T) {
+	var calls int32
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("id"))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	err := cli.Post(Form{Region: "us-west-1", Template: "create user name=toto"})
+	if _, ok := err.(*RateLimitError); !ok {
+		t.Fatalf("got %T, want *RateLimitError", err)
+	}
+	if cli.LastRateLimit() == nil {
+		t.Fatal("expected rate limit info")
+	}
+
+	WithRetry(2)(cli)
+	if err = cli.Post(Form{Region: "us-west-1", Template: "create user name=toto"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := atomic.LoadInt32(&calls), int32(2); got != want {
+		t.Fatalf("got %d calls, want %d", got, want)
+	}
+}
+
+func TestRetryOnlyIdempotentRequests(t *testing.T) {
+	var posts, gets int32
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			atomic.AddInt32(&posts, 1)
+		} else {
+			atomic.AddInt32(&gets, 1)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	cli = cli.apply([]ClientOption{WithRetry(2), WithBackoffConfig(ExponentialBackoffConfig{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, Multiplier: 1})})
+
+	if err := cli.Post(Form{Region: "us-west-1", Template: "create user name=toto"}); err == nil {
+		t.Fatal("expected error")
+	}
+	if got, want := atomic.LoadInt32(&posts), int32(1); got != want {
+		t.Fatalf("got %d posts, want %d", got, want)
+	}
+	if _, err := cli.ListTasks(); err == nil {
+		t.Fatal("expected error")
+	}
+	if got, want := atomic.LoadInt32(&gets), int32(3); got != want {
+		t.Fatalf("got %d gets, want %d", got, want)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	var calls int32
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	cli = cli.apply([]ClientOption{WithRetry(10), WithRetryBudget(250 * time.Millisecond)})
+
+	err := cli.Delete(context.Background(), "1")
+	urlErr, ok := err.(*url.Error)
+	if !ok {
+		t.Fatalf("got %T, want *url.Error", err)
+	}
+	budgetErr, ok := urlErr.Err.(*RetryBudgetExhaustedError)
+	if !ok {
+		t.Fatalf("got %T, want *RetryBudgetExhaustedError", urlErr.Err)
+	}
+	if got, want := budgetErr.Attempts, 2; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if got, want := atomic.LoadInt32(&calls), int32(2); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if budgetErr.Err == nil || !strings.Contains(budgetErr.Err.Error(), "503") {
+		t.Fatalf("got %v, want last attempt status", budgetErr.Err)
+	}
+}
+
+func TestBackoffConfig(t *testing.T) {
+	var calls int32
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer schedulerService.Close()
+
+	cfg := ExponentialBackoffConfig{InitialInterval: 10 * time.Millisecond, MaxInterval: 20 * time.Millisecond, Multiplier: 2, MaxElapsedTime: 45 * time.Millisecond}
+	cli := newTestClient(t, schedulerService.URL)
+	cli = cli.apply([]ClientOption{WithRetry(10), WithBackoffConfig(cfg)})
+
+	if err := cli.Delete(context.Background(), "1"); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("got %v, want last attempt status", err)
+	}
+	// waits of 10ms, 20ms then 20ms would exceed the 45ms elapsed time
+	if got, want := atomic.LoadInt32(&calls), int32(3); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+
+	for _, invalid := range []ExponentialBackoffConfig{
+		{InitialInterval: time.Second, MaxInterval: time.Second, Multiplier: 0.5},
+		{MaxInterval: time.Second, Multiplier: 2},
+		{InitialInterval: time.Second, MaxInterval: time.Millisecond, Multiplier: 2},
+	} {
+		if invalid.Validate() == nil {
+			t.Fatalf("%+v: want validation error", invalid)
+		}
+		if cli.apply([]ClientOption{WithBackoffConfig(invalid)}).transport.backoff.InitialInterval != cfg.InitialInterval {
+			t.Fatalf("%+v: want config ignored", invalid)
+		}
+	}
+	if err := DefaultBackoffConfig.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	jittered := ExponentialBackoffConfig{InitialInterval: 100 * time.Millisecond, MaxInterval: time.Second, Multiplier: 1.5, RandomizationFactor: 0.2}
+	for i := 0; i < 20; i++ {
+		if wait := jittered.interval(1); wait < 120*time.Millisecond || wait > 180*time.Millisecond {
+			t.Fatalf("got %s, want within 20%% of 150ms", wait)
+		}
+	}
+}
+
+func TestRetryAfterParsing(t *testing.T) {
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	if got, want := retryAfter("120", now), 2*time.Minute; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := retryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now), 30*time.Second; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := retryAfter("soon", now), time.Duration(0); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"Content": "create user name=toto", "Region": "us-west-1"}]`))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	if _, err := cli.ListTasks(); err != nil {
+		t.Fatal(err)
+	}
+
+	WithMaxResponseSize(10)(cli)
+	_, err := cli.ListTasks()
+	if _, ok := err.(*ErrResponseTooLarge); !ok {
+		t.Fatalf("got %T (%v), want *ErrResponseTooLarge", err, err)
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	supported := true
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !supported {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"ResourceCount": 2, "Warnings": ["no pricing for region"]}`))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	f := Form{Region: "us-west-1", Template: "create user name=toto"}
+
+	estimate, err := cli.EstimateCost(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := estimate.ResourceCount, 2; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+
+	supported = false
+	if _, err = cli.EstimateCost(context.Background(), f); err != ErrEstimationNotSupported {
+		t.Fatalf("got %v, want %v", err, ErrEstimationNotSupported)
+	}
+}
+
+func TestGetOutputTruncation(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tasks/id/output" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("created user toto"))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	WithMaxOutputSize(7)(cli)
+
+	out, err := cli.GetOutput(context.Background(), "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.Output, "created"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if !out.Truncated || out.TruncatedAt != 7 {
+		t.Fatalf("got %+v, want truncated at 7", out)
+	}
+
+	WithOutputEncoding(OutputBase64)(cli)
+	if out, _ = cli.GetOutput(context.Background(), "id"); out.Output != "Y3JlYXRlZA==" {
+		t.Fatalf("got %s, want base64 output", out.Output)
+	}
+
+	if _, err = cli.GetOutput(context.Background(), "unknown"); err != ErrNotFound {
+		t.Fatalf("got %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestPurgeExpiredFallback(t *testing.T) {
+	now := time.Now().UTC()
+	var deleted []string
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/tasks" && r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode([]*model.Task{
+				{ID: "stale", Content: "create user name=toto", RunAt: model.FlexibleTime(now.Add(-3 * time.Hour)), Region: "us-west-1"},
+				{ID: "missed", Content: "create user name=tata", RunAt: model.FlexibleTime(now.Add(-30 * time.Minute)), Region: "us-west-1"},
+			})
+		case r.URL.Path == "/tasks/expired":
+			http.NotFound(w, r)
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/tasks/"))
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	count, err := cli.PurgeExpired(context.Background(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := count, 1; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if got, want := strings.Join(deleted, ","), "stale"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestDeleteOlderThan(t *testing.T) {
+	now := time.Now().UTC()
+	var supported bool
+	var deleted []string
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/tasks" && r.Method == http.MethodDelete:
+			if !supported {
+				http.Error(w, "invalid method", http.StatusMethodNotAllowed)
+				return
+			}
+			if got, want := r.URL.RawQuery, "older_than=24h0m0s&status=done&status=failed"; got != want {
+				t.Fatalf("got %s, want %s", got, want)
+			}
+			w.Write([]byte("3"))
+		case r.URL.Path == "/failures":
+			json.NewEncoder(w).Encode([]*model.Task{
+				{ID: "old", Content: "create user name=toto", RunAt: model.FlexibleTime(now.Add(-48 * time.Hour)), Region: "us-west-1"},
+				{ID: "recent", Content: "create user name=tata", RunAt: model.FlexibleTime(now.Add(-time.Hour)), Region: "us-west-1"},
+			})
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/tasks/"))
+		default:
+			t.Fatalf("unexpected request %s %s", r.Method, r.URL)
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+	if _, err := cli.DeleteOlderThan(context.Background(), 24*time.Hour, []string{model.StatusPending}); err == nil {
+		t.Fatal("expected invalid status error")
+	}
+	if _, err := cli.DeleteOlderThan(context.Background(), 24*time.Hour, nil); err == nil {
+		t.Fatal("expected missing status error")
+	}
+
+	count, err := cli.DeleteOlderThan(context.Background(), 24*time.Hour, []string{model.StatusDone, model.StatusFailed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := count, 1; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if got, want := strings.Join(deleted, ","), "old"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	supported = true
+	if count, err = cli.DeleteOlderThan(context.Background(), 24*time.Hour, []string{model.StatusDone, model.StatusFailed}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := count, 3; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+}
+
+func TestRescheduleRelative(t *testing.T) {
+	runAt := time.Now().UTC().Add(time.Hour)
+	var puts int32
+	var run, revert string
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			atomic.AddInt32(&puts, 1)
+			run, revert = r.FormValue("run"), r.FormValue("revert")
+			w.Write([]byte("2"))
+			return
+		}
+		json.NewEncoder(w).Encode(&model.Task{ID: "1", Content: "create user name=toto", Region: "us-west-1", RunAt: model.FlexibleTime(runAt), RevertAt: model.FlexibleTime(runAt.Add(time.Hour))})
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	if err := cli.RescheduleRelative(context.Background(), "1", 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(&puts); got != 0 {
+		t.Fatalf("got %d reschedules, want no-op", got)
+	}
+
+	if err := cli.RescheduleRelative(context.Background(), "1", -30*time.Minute, 30*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if run != "30m0s" || revert != "2h30m0s" {
+		t.Fatalf("got run in %s and revert in %s", run, revert)
+	}
+
+	if err := cli.RescheduleRelative(context.Background(), "1", -2*time.Hour, 0); err == nil || !strings.Contains(err.Error(), "past") {
+		t.Fatalf("got %v, want past run time error", err)
+	}
+	if got, want := atomic.LoadInt32(&puts), int32(1); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+}
+
+func TestRescheduleWithLock(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/tasks/1/lock" && r.Method == http.MethodPost:
+			w.Write([]byte("token"))
+		case r.URL.Query().Get("lock_token") != "token":
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error": "LOCK_EXPIRED", "message": "lock of task '1' expired"}`))
+		default:
+			w.Write([]byte("2"))
+		}
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	token, err := cli.Lock(context.Background(), "1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := Form{Region: "us-west-1", RunIn: "2m", Template: "create user name=toto"}
+	id, err := cli.RescheduleWithLock(context.Background(), "1", token, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := id, "2"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if _, err = cli.RescheduleWithLock(context.Background(), "1", "stale", f); err != ErrLockExpired {
+		t.Fatalf("got %v, want %v", err, ErrLockExpired)
+	}
+	if err = cli.Unlock(context.Background(), "1", "stale"); err != ErrLockExpired {
+		t.Fatalf("got %v, want %v", err, ErrLockExpired)
+	}
+}
+
+func TestNoopComparesSecretNames(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Region": "us-west-1", "RunIn": "2m", "Template": "create user name=toto password={password}", "SecretKeys": ["password"]}`))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	f := Form{Region: "us-west-1", RunIn: "2m", Template: "create user name=toto password={password}", SecretEnv: map[string]string{"password": "s3cr3t"}}
+	echoed, err := cli.Noop(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if echoed.SecretEnv != nil {
+		t.Fatalf("got echoed secrets %v", echoed.SecretEnv)
+	}
+
+	f.SecretEnv = map[string]string{"pass": "s3cr3t"}
+	_, err = cli.Noop(context.Background(), f)
+	mismatch, ok := err.(*SerialiserMismatch)
+	if !ok {
+		t.Fatalf("got %T, want *SerialiserMismatch", err)
+	}
+	if got, want := fmt.Sprint(mismatch.Fields), "[SecretEnv]"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestNoopDetectsMismatch(t *testing.T) {
+	schedulerService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Region": "us-west-1", "RunIn": "2m", "Template": "create user name=tot"}`))
+	}))
+	defer schedulerService.Close()
+
+	cli := newTestClient(t, schedulerService.URL)
+
+	_, err := cli.Noop(context.Background(), Form{Region: "us-west-1", RunIn: "2m", Template: "create user name=toto"})
+	mismatch, ok := err.(*SerialiserMismatch)
+	if !ok {
+		t.Fatalf("got %T, want *SerialiserMismatch", err)
+	}
+	if got, want := fmt.Sprint(mismatch.Fields), "[Template]"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
 }
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const confirmationPollInterval = 100 * time.Millisecond
+
+type PostOption func(*postOptions)
+
+type postOptions struct {
+	confirmWait time.Duration
+}
+
+// WithConfirmation makes Post poll each created task until Get finds it,
+// for at most maxWait.
+func WithConfirmation(maxWait time.Duration) PostOption {
+	return func(o *postOptions) {
+		o.confirmWait = maxWait
+	}
+}
+
+// ErrConfirmationTimeout is returned by Post for a task created but not
+// found by the scheduler within the confirmation wait.
+type ErrConfirmationTimeout struct {
+	TaskID  string
+	MaxWait time.Duration
+}
+
+func (e *ErrConfirmationTimeout) Error() string {
+	return fmt.Sprintf("task %s not confirmed within %s", e.TaskID, e.MaxWait)
+}
+
+func (c *Client) confirm(ids []string, maxWait time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), maxWait)
+	defer cancel()
+
+	for _, id := range ids {
+		for {
+			_, err := c.Get(ctx, id)
+			if err == nil {
+				break
+			}
+			if err != ErrNotFound && ctx.Err() == nil {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return &ErrConfirmationTimeout{TaskID: id, MaxWait: maxWait}
+			case <-time.After(confirmationPollInterval):
+			}
+		}
+	}
+	return nil
+}
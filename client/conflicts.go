@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+var templateARN = regexp.MustCompile(`arn:aws[\w-]*:[\w-]+:[\w-]*:(\d{12}|aws)?:[^\s"',]+`)
+
+// ScheduleWindowConflicts returns the pending tasks of the form region that
+// run within windowDuration of the form and share ARNs with its template.
+// Tasks are considered running for windowDuration from their run time.
+func (c *Client) ScheduleWindowConflicts(ctx context.Context, f Form, windowDuration time.Duration) ([]*model.Task, error) {
+	if windowDuration < 0 {
+		return nil, errors.New("negative conflict window duration")
+	}
+	now := time.Now()
+	run, err := f.runIn(now)
+	if err != nil {
+		return nil, err
+	}
+	start := now
+	if run != "" {
+		d, err := time.ParseDuration(run)
+		if err != nil {
+			return nil, err
+		}
+		start = now.Add(d)
+	}
+	end := start.Add(windowDuration)
+
+	arns := make(map[string]bool)
+	for _, arn := range templateARN.FindAllString(f.Template, -1) {
+		arns[arn] = true
+	}
+	if len(arns) == 0 {
+		return nil, nil
+	}
+
+	opts := ListOptions{Status: model.StatusPending, Ascending: true}
+	if !f.AllRegions {
+		opts.Region = f.Region
+	}
+	tasks, err := c.ListWithOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []*model.Task
+	for _, tk := range tasks {
+		runAt := tk.RunAt.Time()
+		if runAt.After(end) || runAt.Add(windowDuration).Before(start) {
+			continue
+		}
+		for _, arn := range templateARN.FindAllString(tk.Content, -1) {
+			if arns[arn] {
+				conflicts = append(conflicts, tk)
+				break
+			}
+		}
+	}
+	return conflicts, nil
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RequestStats is a snapshot of the requests made by a client. Cache hits
+// are not counted as requests.
+type RequestStats struct {
+	Total, Successful, Failed, CacheHits int64
+
+	// LastUpstreamServiceTime is the X-Envoy-Upstream-Service-Time of the
+	// last response having one, as set by Envoy based service meshes.
+	LastUpstreamServiceTime time.Duration
+
+	counters *requestCounters
+}
+
+func (c *Client) RequestStats() RequestStats {
+	return c.transport.counters.snapshot()
+}
+
+// Reset zeroes the counters of the client the stats were taken from.
+func (s RequestStats) Reset() {
+	s.counters.reset()
+}
+
+type requestCounters struct {
+	// held for reading to count, for writing to snapshot or reset
+	mux                                  sync.RWMutex
+	total, successful, failed, cacheHits int64
+	lastUpstreamServiceTime              int64
+}
+
+func (rc *requestCounters) count(counters ...*int64) {
+	if rc == nil {
+		return
+	}
+	rc.mux.RLock()
+	defer rc.mux.RUnlock()
+	for _, c := range counters {
+		atomic.AddInt64(c, 1)
+	}
+}
+
+func (rc *requestCounters) countRequest(success bool) {
+	if rc == nil {
+		return
+	}
+	if success {
+		rc.count(&rc.total, &rc.successful)
+	} else {
+		rc.count(&rc.total, &rc.failed)
+	}
+}
+
+func (rc *requestCounters) recordUpstreamServiceTime(d time.Duration) {
+	if rc == nil {
+		return
+	}
+	rc.mux.RLock()
+	defer rc.mux.RUnlock()
+	atomic.StoreInt64(&rc.lastUpstreamServiceTime, int64(d))
+}
+
+func (rc *requestCounters) countCacheHit() {
+	if rc == nil {
+		return
+	}
+	rc.count(&rc.cacheHits)
+}
+
+func (rc *requestCounters) snapshot() RequestStats {
+	if rc == nil {
+		return RequestStats{}
+	}
+	rc.mux.Lock()
+	defer rc.mux.Unlock()
+	return RequestStats{
+		Total:                   rc.total,
+		Successful:              rc.successful,
+		Failed:                  rc.failed,
+		CacheHits:               rc.cacheHits,
+		LastUpstreamServiceTime: time.Duration(rc.lastUpstreamServiceTime),
+		counters:                rc,
+	}
+}
+
+func (rc *requestCounters) reset() {
+	if rc == nil {
+		return
+	}
+	rc.mux.Lock()
+	defer rc.mux.Unlock()
+	rc.total, rc.successful, rc.failed, rc.cacheHits, rc.lastUpstreamServiceTime = 0, 0, 0, 0, 0
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+const debugBodySize = 4 << 10
+
+// SetDebug toggles the logging of requests and responses, headers and
+// bodies truncated at 4KB included. It is safe to call while requests are
+// in flight. Authorization headers and secrets are never logged.
+func (c *Client) SetDebug(enabled bool) {
+	var debug int32
+	if enabled {
+		debug = 1
+	}
+	atomic.StoreInt32(&c.transport.debug, debug)
+}
+
+func (t *transport) debugging() bool {
+	return atomic.LoadInt32(&t.debug) == 1
+}
+
+func debugRequest(req *http.Request) {
+	var body []byte
+	if req.GetBody != nil {
+		if rc, err := req.GetBody(); err == nil {
+			if req.Header.Get("Content-Type") == model.FormContentType {
+				body, _ = ioutil.ReadAll(rc)
+				body = redactSecrets(body)
+			} else {
+				body, _ = ioutil.ReadAll(io.LimitReader(rc, debugBodySize))
+			}
+			if len(body) > debugBodySize {
+				body = body[:debugBodySize]
+			}
+			rc.Close()
+		}
+	}
+	log.Printf("[DEBUG] request %s %s\n%s%s", req.Method, req.URL, debugHeader(req.Header), body)
+}
+
+func debugResponse(req *http.Request, resp *http.Response, err error, start time.Time) *http.Response {
+	if err != nil {
+		log.Printf("[DEBUG] response to %s %s after %s: %s", req.Method, req.URL, time.Since(start), err)
+		return resp
+	}
+	resp.Body = &debugBody{ReadCloser: resp.Body, req: req, resp: resp, start: start}
+	return resp
+}
+
+// debugBody logs the response once its body is closed.
+type debugBody struct {
+	io.ReadCloser
+	req    *http.Request
+	resp   *http.Response
+	start  time.Time
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (b *debugBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if remaining := debugBodySize - b.buf.Len(); remaining > 0 && n > 0 {
+		if n < remaining {
+			remaining = n
+		}
+		b.buf.Write(p[:remaining])
+	}
+	return n, err
+}
+
+func (b *debugBody) Close() error {
+	err := b.ReadCloser.Close()
+	if !b.closed {
+		b.closed = true
+		log.Printf("[DEBUG] response %s to %s %s after %s\n%s%s", b.resp.Status, b.req.Method, b.req.URL, time.Since(b.start), debugHeader(b.resp.Header), b.buf.Bytes())
+	}
+	return err
+}
+
+// redactSecrets hides the secret values of a form body, which are plaintext
+// without secret encryption.
+func redactSecrets(body []byte) []byte {
+	var form map[string]json.RawMessage
+	if err := json.Unmarshal(body, &form); err != nil {
+		return []byte("[redacted]")
+	}
+	var secrets map[string]string
+	if err := json.Unmarshal(form["SecretEnv"], &secrets); err != nil || len(secrets) == 0 {
+		return body
+	}
+	for k := range secrets {
+		secrets[k] = "[redacted]"
+	}
+	form["SecretEnv"], _ = json.Marshal(secrets)
+	redacted, err := json.Marshal(form)
+	if err != nil {
+		return []byte("[redacted]")
+	}
+	return redacted
+}
+
+func debugHeader(h http.Header) string {
+	var keys []string
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, k := range keys {
+		v := strings.Join(h[k], ", ")
+		if http.CanonicalHeaderKey(k) == "Authorization" {
+			v = "[redacted]"
+		}
+		buf.WriteString(k + ": " + v + "\n")
+	}
+	return buf.String()
+}
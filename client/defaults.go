@@ -0,0 +1,77 @@
+package client
+
+// SetFormDefaults sets the form whose fields fill the zero fields of the
+// forms posted with Post. Vars and secrets are merged key by key, the posted
+// form values taking precedence. Default vars filling no hole of the posted
+// template are skipped.
+func (c *Client) SetFormDefaults(defaults Form) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.formDefaults = &defaults
+}
+
+func (c *Client) withFormDefaults(f Form) Form {
+	c.mux.RLock()
+	defaults := c.formDefaults
+	c.mux.RUnlock()
+	if defaults == nil {
+		return f
+	}
+
+	// region and all regions are the target of the form
+	if f.Region == "" && !f.AllRegions {
+		f.Region, f.AllRegions = defaults.Region, defaults.AllRegions
+	}
+	// run in and run at expression are the run time of the form
+	if f.RunIn == "" && f.RunAtExpression == "" {
+		f.RunIn, f.RunAtExpression = defaults.RunIn, defaults.RunAtExpression
+	}
+	if f.RevertIn == "" {
+		f.RevertIn = defaults.RevertIn
+	}
+	if f.Template == "" {
+		f.Template = defaults.Template
+	}
+	if f.DependsOn == nil {
+		f.DependsOn = append([]string(nil), defaults.DependsOn...)
+	}
+	if f.Timezone == "" {
+		f.Timezone = defaults.Timezone
+	}
+	if f.ContentType == "" {
+		f.ContentType = defaults.ContentType
+	}
+	if len(defaults.Vars) > 0 {
+		holes := make(map[string]bool)
+		for _, match := range templateHole.FindAllStringSubmatch(f.Template, -1) {
+			holes[match[1]] = true
+		}
+		merged := make(map[string]string, len(defaults.Vars)+len(f.Vars))
+		for k, v := range defaults.Vars {
+			if holes[k] {
+				merged[k] = v
+			}
+		}
+		for k, v := range f.Vars {
+			merged[k] = v
+		}
+		f.Vars = merged
+	}
+	if len(defaults.SecretEnv) > 0 {
+		merged := make(map[string]string, len(defaults.SecretEnv)+len(f.SecretEnv))
+		for k, v := range defaults.SecretEnv {
+			merged[k] = v
+		}
+		for k, v := range f.SecretEnv {
+			merged[k] = v
+		}
+		f.SecretEnv = merged
+	}
+	if f.AfterSuccess == nil {
+		f.AfterSuccess = defaults.AfterSuccess
+	}
+	if f.OnFailure == nil {
+		f.OnFailure = defaults.OnFailure
+	}
+	return f
+}
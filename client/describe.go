@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+// DescribeForm has the scheduler parse the form, without storing any task,
+// and returns the schedule and region it computed along with warnings.
+func (c *Client) DescribeForm(ctx context.Context, f Form) (*model.FormDescription, error) {
+	if err := c.validate(f); err != nil {
+		return nil, err
+	}
+	if f.AllRegions {
+		return nil, errors.New("cannot describe a form in all regions")
+	}
+
+	req, err := c.newFormRequest(ctx, http.MethodPost, "tasks/describe", f)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err = notOKStatus(req.URL.String(), resp); err != nil {
+		return nil, err
+	}
+
+	desc := &model.FormDescription{}
+	if err = json.NewDecoder(resp.Body).Decode(desc); err != nil {
+		return nil, fmt.Errorf("cannot decode form description: %s", err)
+	}
+	return desc, nil
+}
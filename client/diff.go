@@ -0,0 +1,92 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+type TaskDiff struct {
+	TaskID        string
+	ChangedFields []string
+	ContentDiff   string
+}
+
+func (c *Client) RescheduleWithDiff(ctx context.Context, taskID string, f Form) (*TaskDiff, error) {
+	current, err := c.Get(ctx, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get task '%s': %s", taskID, err)
+	}
+
+	newID, err := c.Reschedule(ctx, taskID, f)
+	if err != nil {
+		return nil, err
+	}
+
+	rescheduled, err := c.Get(ctx, newID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get rescheduled task '%s': %s", newID, err)
+	}
+
+	return diffTasks(current, rescheduled), nil
+}
+
+func diffTasks(from, to *model.Task) *TaskDiff {
+	diff := &TaskDiff{TaskID: to.ID}
+	if from.Region != to.Region {
+		diff.ChangedFields = append(diff.ChangedFields, "Region")
+	}
+	if !from.RunAt.Time().Equal(to.RunAt.Time()) {
+		diff.ChangedFields = append(diff.ChangedFields, "RunAt")
+	}
+	if !from.RevertAt.Time().Equal(to.RevertAt.Time()) {
+		diff.ChangedFields = append(diff.ChangedFields, "RevertAt")
+	}
+	if from.Content != to.Content {
+		diff.ChangedFields = append(diff.ChangedFields, "Content")
+		diff.ContentDiff = unifiedDiff(from.ID, to.ID, from.Content, to.Content)
+	}
+	return diff
+}
+
+func unifiedDiff(fromName, toName, from, to string) string {
+	a, b := strings.Split(from, "\n"), strings.Split(to, "\n")
+
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n@@ -1,%d +1,%d @@\n", fromName, toName, len(a), len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			fmt.Fprintf(&buf, " %s\n", a[i])
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			fmt.Fprintf(&buf, "+%s\n", b[j])
+			j++
+		default:
+			fmt.Fprintf(&buf, "-%s\n", a[i])
+			i++
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+var defaultFilePatterns = []string{"*.json"}
+
+// FormFromFile loads a form stored as JSON. YAML files are recognised but
+// not supported, the client only depending on the standard library.
+func FormFromFile(path string) (*Form, error) {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		return nil, fmt.Errorf("cannot load form %s: yaml forms not supported, use json", path)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &Form{}
+	if err = json.Unmarshal(b, f); err != nil {
+		return nil, fmt.Errorf("cannot load form %s: %s", path, err)
+	}
+	return f, nil
+}
+
+type SubmitDirOptions struct {
+	// DryRun loads and validates the forms without submitting them.
+	DryRun bool
+	// FilePattern matches the form file names, *.json if empty.
+	FilePattern string
+	OnSubmit    func(path string, taskID string)
+}
+
+// SubmitFromDir submits with BulkPost the forms found walking dir, in the
+// lexical order of their paths, and returns their task ids in that order.
+func (c *Client) SubmitFromDir(ctx context.Context, dir string, opts SubmitDirOptions) ([]string, error) {
+	patterns := defaultFilePatterns
+	if opts.FilePattern != "" {
+		patterns = []string{opts.FilePattern}
+	}
+
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		for _, pattern := range patterns {
+			if ok, err := filepath.Match(pattern, info.Name()); err != nil {
+				return err
+			} else if ok {
+				paths = append(paths, path)
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var forms []Form
+	for _, path := range paths {
+		f, err := FormFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err = c.validate(*f); err != nil {
+			return nil, fmt.Errorf("invalid form %s: %s", path, err)
+		}
+		forms = append(forms, *f)
+	}
+	if opts.DryRun {
+		return []string{}, nil
+	}
+
+	posted, err := c.BulkPost(ctx, forms)
+	var ids []string
+	for i, formIDs := range posted {
+		for _, id := range formIDs {
+			if opts.OnSubmit != nil {
+				opts.OnSubmit(paths[i], id)
+			}
+			ids = append(ids, id)
+		}
+	}
+	if bulkErr, ok := err.(*BulkPostError); ok {
+		return ids, fmt.Errorf("cannot submit form %s: %s", paths[bulkErr.Index], bulkErr.Err)
+	}
+	return ids, err
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+// WithDiscoveryRetry retries discovery up to maxAttempts times, waiting an
+// exponential backoff with jitter from baseBackoff between attempts.
+func WithDiscoveryRetry(maxAttempts int, baseBackoff time.Duration) ClientOption {
+	return func(c *Client) {
+		c.discoveryRetry = &discoveryRetry{maxAttempts: maxAttempts, baseBackoff: baseBackoff}
+	}
+}
+
+type DiscoveryError struct {
+	URL      string
+	Attempts []error
+}
+
+func (e *DiscoveryError) Error() string {
+	var msgs []string
+	for i, err := range e.Attempts {
+		msgs = append(msgs, fmt.Sprintf("attempt %d: %s", i+1, err))
+	}
+	return fmt.Sprintf("cannot discover scheduler at '%s' after %d attempt(s): %s", e.URL, len(e.Attempts), strings.Join(msgs, "; "))
+}
+
+type discoveryRetry struct {
+	maxAttempts int
+	baseBackoff time.Duration
+}
+
+// probeOptions applies options to a throwaway client to read the settings
+// needed before the actual client is built.
+func probeOptions(opts []ClientOption) *Client {
+	probe := &Client{httpClient: &http.Client{}, transport: &transport{}}
+	return probe.apply(opts)
+}
+
+func discoverWithRetry(ctx context.Context, httpClient *http.Client, discoveryURL string, retry *discoveryRetry) (*model.ServiceInfo, error) {
+	if retry == nil || retry.maxAttempts <= 1 {
+		return discover(ctx, httpClient, discoveryURL)
+	}
+
+	discoveryErr := &DiscoveryError{URL: discoveryURL}
+	for attempt := 0; attempt < retry.maxAttempts; attempt++ {
+		v, err := discover(ctx, httpClient, discoveryURL)
+		if err == nil {
+			return v, nil
+		}
+		discoveryErr.Attempts = append(discoveryErr.Attempts, err)
+		log.Printf("[WARN] discovery attempt %d/%d at '%s' failed: %s", attempt+1, retry.maxAttempts, discoveryURL, err)
+
+		if attempt == retry.maxAttempts-1 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			discoveryErr.Attempts = append(discoveryErr.Attempts, ctx.Err())
+			return nil, discoveryErr
+		case <-time.After(jitter(retry.baseBackoff << uint(attempt))):
+		}
+	}
+	return nil, discoveryErr
+}
+
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
@@ -0,0 +1,152 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+const defaultDLQMaxReprocessAttempts = 3
+
+// DLQ configures the dead letter queue listener, the dead letter queue
+// holding the failed tasks of the scheduler.
+type DLQ struct {
+	// MaxReprocessAttempts caps the times a failed task is handed over to the
+	// handler, 3 if zero.
+	MaxReprocessAttempts int
+}
+
+func WithDLQ(dlq DLQ) ClientOption {
+	return func(c *Client) {
+		c.dlq = dlq
+	}
+}
+
+// ListenForDeadLetterQueue hands over the failed tasks streamed by the
+// scheduler to handler until ctx is done. Tasks successfully handled are
+// removed from the queue, the others being kept for manual review. The
+// stream is reopened when broken, sending again the tasks still queued.
+func (c *Client) ListenForDeadLetterQueue(ctx context.Context, handler func(ctx context.Context, t *model.Task) error) error {
+	maxAttempts := c.dlq.MaxReprocessAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultDLQMaxReprocessAttempts
+	}
+	attempts := make(map[string]int)
+	acked := make(map[string]bool)
+
+	handle := func(tk *model.Task) {
+		if acked[tk.ID] || attempts[tk.ID] >= maxAttempts {
+			return
+		}
+		attempts[tk.ID]++
+		if err := handler(ctx, tk); err != nil {
+			return
+		}
+		if err := c.ackDeadLetter(ctx, tk.ID); err != nil && err != ErrNotFound {
+			log.Printf("[WARN] cannot remove task '%s' from dead letter queue: %s", tk.ID, err)
+			return
+		}
+		acked[tk.ID] = true
+	}
+
+	for retry := 0; ; retry++ {
+		resp, err := c.openDeadLetters(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil {
+			if resp.StatusCode != http.StatusOK {
+				defer resp.Body.Close()
+				return notOKStatus(resp.Request.URL.String(), resp)
+			}
+			retry = 0
+			readDeadLetters(resp, handle)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff(retry)):
+		}
+	}
+}
+
+func (c *Client) openDeadLetters(ctx context.Context) (*http.Response, error) {
+	addr := c.serviceURL()
+	addr.Path = "dlq/stream"
+
+	req, err := http.NewRequest(http.MethodGet, addr.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	// the stream outlives the client timeout
+	withoutTimeout := *c.httpClient
+	withoutTimeout.Timeout = 0
+	return withoutTimeout.Do(req.WithContext(ctx))
+}
+
+// readDeadLetters handles the tasks of the server-sent events stream until it
+// breaks.
+func readDeadLetters(resp *http.Response, handle func(*model.Task)) {
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, defaultMaxResponseSize)
+	var event string
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		switch {
+		case len(line) == 0:
+			if (event == "" || event == "task") && data.Len() > 0 {
+				tk := &model.Task{}
+				if err := json.Unmarshal(data.Bytes(), tk); err != nil {
+					log.Printf("[WARN] cannot decode dead letter from '%s': %s", resp.Request.URL, err)
+				} else {
+					handle(tk)
+				}
+			}
+			event = ""
+			data.Reset()
+		case bytes.HasPrefix(line, []byte("event:")):
+			event = string(bytes.TrimSpace(line[len("event:"):]))
+		case bytes.HasPrefix(line, []byte("data:")):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.Write(bytes.TrimPrefix(line[len("data:"):], []byte(" ")))
+		}
+	}
+}
+
+func (c *Client) ackDeadLetter(ctx context.Context, taskID string) error {
+	addr := c.serviceURL()
+	addr.Path = "dlq/" + taskID
+
+	req, err := http.NewRequest(http.MethodDelete, addr.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	return notOKStatus(addr.String(), resp)
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("task not found")
+	ErrConflict = errors.New("task conflict")
+
+	// ErrTaskAlreadyRunning is returned for changes of a task being executed.
+	ErrTaskAlreadyRunning = errors.New("task already running")
+
+	// ErrTokenExpired is returned by ListChanged for tokens of a restarted
+	// scheduler or too old to list their changes.
+	ErrTokenExpired = errors.New("change token expired")
+)
+
+var (
+	errorCodesMux sync.RWMutex
+	errorCodes    = map[string]error{
+		"TASK_NOT_FOUND": ErrNotFound,
+		"CONFLICT":       ErrConflict,
+		"TASK_RUNNING":   ErrTaskAlreadyRunning,
+		"TOKEN_EXPIRED":  ErrTokenExpired,
+	}
+)
+
+func RegisterErrorCode(code string, sentinel error) {
+	errorCodesMux.Lock()
+	defer errorCodesMux.Unlock()
+	errorCodes[code] = sentinel
+}
+
+type ServerError struct {
+	Code, Message string
+	HTTPStatus    int
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("scheduler error %s (status %d): %s", e.Code, e.HTTPStatus, e.Message)
+}
+
+// Unwrap returns the sentinel registered for the error code, if any.
+func (e *ServerError) Unwrap() error {
+	errorCodesMux.RLock()
+	defer errorCodesMux.RUnlock()
+	return errorCodes[e.Code]
+}
+
+// sentinel returns the sentinel registered for a server error code, or err.
+func sentinel(err error) error {
+	if serverErr, ok := err.(*ServerError); ok {
+		if s := serverErr.Unwrap(); s != nil {
+			return s
+		}
+	}
+	return err
+}
+
+func parseServerError(status int, body []byte) *ServerError {
+	var v struct {
+		Error, Message string
+	}
+	if err := json.Unmarshal(body, &v); err != nil || v.Error == "" {
+		return nil
+	}
+	return &ServerError{Code: v.Error, Message: v.Message, HTTPStatus: status}
+}
+
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited by scheduler, retry after %s", e.RetryAfter)
+}
+
+// retryAfter parses a Retry-After header given either in seconds or as an HTTP date.
+func retryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(header); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+type ErrResponseTooLarge struct {
+	Limit int64
+}
+
+func (e *ErrResponseTooLarge) Error() string {
+	return fmt.Sprintf("response body exceeds %d bytes", e.Limit)
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var ErrEstimationNotSupported = errors.New("cost estimation not supported by scheduler")
+
+type CostEstimate struct {
+	EstimatedDurationSeconds float64
+	ResourceCount            int
+	EstimatedUSDCost         float64
+	Warnings                 []string
+}
+
+// EstimateCost asks the scheduler for the cost of running the form, without
+// creating any task.
+func (c *Client) EstimateCost(ctx context.Context, f Form) (*CostEstimate, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	if f.AllRegions {
+		return nil, errors.New("cannot estimate a form in all regions")
+	}
+
+	req, err := c.newFormRequest(ctx, http.MethodPost, "estimate", f)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrEstimationNotSupported
+	}
+	if err = notOKStatus(req.URL.String(), resp); err != nil {
+		return nil, err
+	}
+
+	estimate := &CostEstimate{}
+	if err = json.NewDecoder(resp.Body).Decode(estimate); err != nil {
+		return nil, fmt.Errorf("cannot decode cost estimate: %s", err)
+	}
+	return estimate, nil
+}
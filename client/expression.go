@@ -0,0 +1,95 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var expressionUnits = map[string]time.Duration{
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+// ParseRunAtExpression parses "now", "in N minutes|hours|days|weeks",
+// "tomorrow", "next <weekday>" and RFC 3339 times. Days start at midnight
+// in the location of now, unless followed by a time of day
+// as in "tomorrow at 3pm" or "next monday at 09:30".
+func ParseRunAtExpression(expr string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, strings.TrimSpace(expr)); err == nil {
+		return t, nil
+	}
+
+	fields := strings.Fields(strings.ToLower(expr))
+	invalid := fmt.Errorf("invalid run at expression '%s'", expr)
+	switch {
+	case len(fields) == 1 && fields[0] == "now":
+		return now, nil
+	case len(fields) == 3 && fields[0] == "in":
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 0 {
+			return time.Time{}, invalid
+		}
+		unit, ok := expressionUnits[strings.TrimSuffix(fields[2], "s")]
+		if !ok {
+			return time.Time{}, invalid
+		}
+		return now.Add(time.Duration(n) * unit), nil
+	}
+
+	var day time.Time
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch {
+	case len(fields) >= 1 && fields[0] == "tomorrow":
+		day, fields = midnight.AddDate(0, 0, 1), fields[1:]
+	case len(fields) >= 2 && fields[0] == "next":
+		weekday, ok := weekdays[fields[1]]
+		if !ok {
+			return time.Time{}, invalid
+		}
+		days := (int(weekday)-int(now.Weekday())+6)%7 + 1
+		day, fields = midnight.AddDate(0, 0, days), fields[2:]
+	default:
+		return time.Time{}, invalid
+	}
+
+	switch {
+	case len(fields) == 0:
+		return day, nil
+	case len(fields) == 2 && fields[0] == "at":
+		for _, layout := range []string{"3pm", "3:04pm", "15:04"} {
+			if clock, err := time.Parse(layout, fields[1]); err == nil {
+				return day.Add(time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute), nil
+			}
+		}
+	}
+	return time.Time{}, invalid
+}
+
+// runIn returns the run duration of the form, computed from RunAtExpression
+// if set, in the form timezone.
+func (f Form) runIn(now time.Time) (string, error) {
+	if f.RunAtExpression == "" {
+		return f.RunIn, nil
+	}
+	if f.Timezone != "" {
+		loc, err := time.LoadLocation(f.Timezone)
+		if err != nil {
+			return "", err
+		}
+		now = now.In(loc)
+	}
+	at, err := ParseRunAtExpression(f.RunAtExpression, now)
+	if err != nil {
+		return "", err
+	}
+	return at.Sub(now).String(), nil
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+// FullTask selects all the task fields, see model.TaskFields for the others.
+const FullTask = model.AllTaskFields
+
+type GetOptions struct {
+	// Fields to retrieve, FullTask if empty.
+	Fields []string
+}
+
+// GetWithOptions gets the task with only the requested fields set, whether or
+// not the scheduler supports field masks.
+func (c *Client) GetWithOptions(ctx context.Context, taskID string, opts GetOptions) (*model.Task, error) {
+	fields := opts.Fields
+	if len(fields) == 0 {
+		fields = []string{FullTask}
+	}
+	if _, err := (&model.Task{}).Mask(fields); err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	if len(fields) > 1 || fields[0] != FullTask {
+		query.Set("fields", strings.Join(fields, ","))
+	}
+	tk, err := c.getTask(ctx, taskID, query)
+	if err != nil {
+		return nil, err
+	}
+	return tk.Mask(fields)
+}
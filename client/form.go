@@ -0,0 +1,233 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+const DefaultContentType = "application/text"
+
+type Form struct {
+	Region, RunIn, RevertIn string
+	Template                string
+	AllRegions              bool
+	DependsOn               []string
+
+	// Timezone is an IANA location, such as "America/New_York", checked by
+	// the scheduler and used to evaluate RunAtExpression. It does not shift
+	// run times: RunIn and RevertIn stay durations from now.
+	Timezone string
+
+	// RunAtExpression is a human friendly alternative to RunIn, such as
+	// "tomorrow at 3pm". See ParseRunAtExpression.
+	RunAtExpression string
+
+	// ContentType is the media type of Template, DefaultContentType if empty.
+	ContentType string
+
+	// ContentLanguage is a syntax highlighting hint of Template, such as
+	// ContentLanguageAwless. If empty, it is detected with
+	// DetectContentLanguage.
+	ContentLanguage string
+
+	// Owner is recorded on the task, the client identity if empty. See
+	// WithBearerToken.
+	Owner string
+
+	// CronExpr has the scheduler run the task again at each time of the five
+	// fields cron expression in UTC, such as "0 3 * * *".
+	CronExpr string
+	// MaxRetries is how many times a failed run is retried at the next tick.
+	MaxRetries int
+
+	Tags        map[string]string
+	Description string
+
+	// Vars fill the {name} holes of Template client side, before the form is
+	// sent. See Render.
+	Vars map[string]string
+
+	// SecretEnv fills holes of the template at execution. Secrets are sent
+	// in the request body only and never listed by the scheduler.
+	SecretEnv map[string]string
+
+	// AfterSuccess and OnFailure are spawned by the scheduler once the task
+	// succeeded or failed. Their durations are relative to the task execution
+	// and their region defaults to the task region.
+	AfterSuccess, OnFailure *Form
+}
+
+func (f Form) Validate() error {
+	_, err := f.ValidateWithMode(StrictValidation)
+	return err
+}
+
+func (f Form) validationIssues() []ValidationIssue {
+	var issues []ValidationIssue
+	fail := func(field, format string, args ...interface{}) {
+		issues = append(issues, ValidationIssue{Field: field, Message: fmt.Sprintf(format, args...), Severity: SeverityError})
+	}
+	warn := func(field, format string, args ...interface{}) {
+		issues = append(issues, ValidationIssue{Field: field, Message: fmt.Sprintf(format, args...), Severity: SeverityWarning})
+	}
+
+	if f.AllRegions && f.Region != "" {
+		fail("Region", "form region and all regions are mutually exclusive")
+	}
+	if !f.AllRegions && f.Region == "" {
+		fail("Region", "missing form region")
+	}
+	if strings.TrimSpace(f.Template) == "" {
+		warn("Template", "empty form template")
+	}
+	if f.RunIn != "" {
+		if d, err := time.ParseDuration(f.RunIn); err != nil {
+			fail("RunIn", "invalid form run duration: %s", err)
+		} else if d < 0 {
+			warn("RunIn", "negative form run duration: task runs at once")
+		}
+	}
+	if f.RunAtExpression != "" {
+		if f.RunIn != "" {
+			fail("RunAtExpression", "form run duration and run at expression are mutually exclusive")
+		} else if _, err := ParseRunAtExpression(f.RunAtExpression, time.Now()); err != nil {
+			fail("RunAtExpression", "%s", err)
+		}
+	}
+	if f.RunIn == "" && f.RunAtExpression == "" {
+		issues = append(issues, ValidationIssue{Field: "RunIn", Message: "no form run duration: task runs at once", Severity: SeverityInfo})
+	}
+	if f.RevertIn != "" {
+		if _, err := time.ParseDuration(f.RevertIn); err != nil {
+			fail("RevertIn", "invalid form revert duration: %s", err)
+		}
+	}
+	if f.Timezone != "" {
+		if _, err := time.LoadLocation(f.Timezone); err != nil {
+			fail("Timezone", "invalid form timezone: %s", err)
+		}
+	}
+	if f.ContentType != "" {
+		if _, _, err := mime.ParseMediaType(f.ContentType); err != nil {
+			fail("ContentType", "invalid form content type: %s", err)
+		}
+	}
+	if f.CronExpr != "" {
+		if _, err := model.ParseCron(f.CronExpr); err != nil {
+			fail("CronExpr", "%s", err)
+		}
+	}
+	if f.MaxRetries < 0 {
+		fail("MaxRetries", "negative form max retries")
+	}
+	for k := range f.Tags {
+		if k == "" || strings.Contains(k, "=") {
+			fail("Tags", "invalid form tag key '%s'", k)
+		}
+	}
+	if _, err := f.Render(); err != nil {
+		fail("Vars", "%s", err)
+	}
+	if f.ContentLanguage == "" {
+		if language := DetectContentLanguage(f.Template); language != "" {
+			issues = append(issues, ValidationIssue{Field: "ContentLanguage", Message: "form content language detected as " + language, Severity: SeverityInfo})
+		}
+	}
+	for _, cb := range []struct {
+		field, name string
+		form        *Form
+	}{{"AfterSuccess", "after success", f.AfterSuccess}, {"OnFailure", "on failure", f.OnFailure}} {
+		if cb.form == nil {
+			continue
+		}
+		if err := cb.form.validateCallback(f.Region); err != nil {
+			fail(cb.field, "invalid %s form: %s", cb.name, err)
+		}
+	}
+	return issues
+}
+
+func (f Form) validateCallback(parentRegion string) error {
+	if f.AfterSuccess != nil || f.OnFailure != nil {
+		return errors.New("callback forms cannot have callbacks")
+	}
+	if f.AllRegions || len(f.DependsOn) > 0 || f.Timezone != "" || f.ContentType != "" || f.ContentLanguage != "" || f.Owner != "" || f.CronExpr != "" || f.MaxRetries != 0 || len(f.Tags) > 0 || f.Description != "" || len(f.Vars) > 0 || len(f.SecretEnv) > 0 || f.RunAtExpression != "" {
+		return errors.New("callback forms only support region, durations and template")
+	}
+	if f.Region == "" {
+		f.Region = parentRegion
+	}
+	if f.Region == "" {
+		// inherits each region of an all regions parent
+		f.AllRegions = true
+	}
+	return f.Validate()
+}
+
+func (f Form) contentType() string {
+	if f.ContentType == "" {
+		return DefaultContentType
+	}
+	return f.ContentType
+}
+
+func (f Form) GetRegion() string      { return f.Region }
+func (f Form) GetRunIn() string       { return f.RunIn }
+func (f Form) GetRevertIn() string    { return f.RevertIn }
+func (f Form) GetTemplate() string    { return f.Template }
+func (f Form) GetDependsOn() []string { return f.DependsOn }
+func (f Form) GetContentType() string { return f.ContentType }
+
+// FormFromTask returns the form scheduling again the task, its run and revert
+// durations being relative to now. Secrets and callbacks are not listed by the
+// scheduler and left out.
+func FormFromTask(tk *model.Task) Form {
+	f := Form{
+		Region:          tk.GetRegion(),
+		Template:        tk.GetContent(),
+		ContentType:     tk.ContentType,
+		ContentLanguage: tk.ContentLanguage,
+		Owner:           tk.Owner,
+		CronExpr:        tk.CronExpr,
+		MaxRetries:      tk.MaxRetries,
+		Tags:            copyTags(tk.Tags),
+		Description:     tk.Description,
+		DependsOn:       append([]string(nil), tk.DependsOn...),
+		RunIn:           time.Until(tk.GetRunAt()).Truncate(time.Second).String(),
+	}
+	if !tk.RevertAt.IsZero() {
+		f.RevertIn = time.Until(tk.GetRevertAt()).Truncate(time.Second).String()
+	}
+	return f
+}
+
+func (f *Form) callback() *model.Callback {
+	if f == nil {
+		return nil
+	}
+	return &model.Callback{Region: f.Region, RunIn: f.RunIn, RevertIn: f.RevertIn, Template: f.Template}
+}
+
+type MultiRegionPostError struct {
+	Failures map[string]error
+}
+
+func (e *MultiRegionPostError) Error() string {
+	var regions []string
+	for region := range e.Failures {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	var msgs []string
+	for _, region := range regions {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", region, e.Failures[region]))
+	}
+	return fmt.Sprintf("cannot post to %d region(s): %s", len(regions), strings.Join(msgs, ", "))
+}
@@ -0,0 +1,241 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+var ErrCyclicDependency = errors.New("cyclic task dependency")
+
+type CyclicDependencyError struct {
+	Cycle []string
+}
+
+func (e *CyclicDependencyError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrCyclicDependency, strings.Join(e.Cycle, " -> "))
+}
+
+func (e *CyclicDependencyError) Unwrap() error {
+	return ErrCyclicDependency
+}
+
+type DependencyGraph struct {
+	ids      []string
+	tasks    map[string]*model.Task
+	children map[string][]string
+}
+
+func (c *Client) TaskGraph(ctx context.Context) (*DependencyGraph, error) {
+	tasks, err := c.ListWithOptions(ctx, ListOptions{Ascending: true})
+	if err != nil {
+		return nil, err
+	}
+	return NewDependencyGraph(tasks), nil
+}
+
+// NewDependencyGraph links tasks to their dependencies. Dependencies on tasks
+// absent from the list (i.e. already executed) are ignored.
+func NewDependencyGraph(tasks []*model.Task) *DependencyGraph {
+	g := &DependencyGraph{
+		tasks:    make(map[string]*model.Task),
+		children: make(map[string][]string),
+	}
+	for _, tk := range tasks {
+		if _, ok := g.tasks[tk.ID]; ok {
+			continue
+		}
+		g.ids = append(g.ids, tk.ID)
+		g.tasks[tk.ID] = tk
+	}
+	for _, id := range g.ids {
+		for _, dep := range g.tasks[id].DependsOn {
+			if _, ok := g.tasks[dep]; ok {
+				g.children[dep] = append(g.children[dep], id)
+			}
+		}
+	}
+	return g
+}
+
+const dependencyChainConcurrency = 10
+
+// TaskDependencyChain returns the transitive dependencies of the task,
+// dependencies first. Each level of the graph is fetched concurrently.
+// Dependencies no longer known by the scheduler are left out.
+func (c *Client) TaskDependencyChain(ctx context.Context, taskID string) ([]*model.Task, error) {
+	root, err := c.Get(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	fetched := map[string]*model.Task{root.ID: root}
+	level := root.DependsOn
+	for len(level) > 0 {
+		var ids []string
+		for _, id := range level {
+			if _, ok := fetched[id]; !ok {
+				fetched[id] = nil
+				ids = append(ids, id)
+			}
+		}
+
+		tasks, err := c.getConcurrently(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		level = nil
+		for i, tk := range tasks {
+			if tk == nil {
+				delete(fetched, ids[i])
+				continue
+			}
+			fetched[ids[i]] = tk
+			level = append(level, tk.DependsOn...)
+		}
+	}
+
+	var tasks []*model.Task
+	for _, tk := range fetched {
+		tasks = append(tasks, tk)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	order, err := NewDependencyGraph(tasks).TopologicalOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	var chain []*model.Task
+	for _, tk := range order {
+		if tk.ID != root.ID {
+			chain = append(chain, tk)
+		}
+	}
+	return chain, nil
+}
+
+// getConcurrently gets the tasks, nil for the ones not found.
+func (c *Client) getConcurrently(ctx context.Context, ids []string) ([]*model.Task, error) {
+	tasks := make([]*model.Task, len(ids))
+	errs := make([]error, len(ids))
+	sem := make(chan struct{}, dependencyChainConcurrency)
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			tk, err := c.Get(ctx, id)
+			if err != nil && err != ErrNotFound {
+				errs[i] = fmt.Errorf("cannot get dependency '%s': %s", id, err)
+				return
+			}
+			tasks[i] = tk
+		}(i, id)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tasks, nil
+}
+
+func (g *DependencyGraph) Roots() []*model.Task {
+	var roots []*model.Task
+	for _, id := range g.ids {
+		if len(g.dependencies(id)) == 0 {
+			roots = append(roots, g.tasks[id])
+		}
+	}
+	return roots
+}
+
+func (g *DependencyGraph) Children(taskID string) []*model.Task {
+	var children []*model.Task
+	for _, id := range g.children[taskID] {
+		children = append(children, g.tasks[id])
+	}
+	return children
+}
+
+func (g *DependencyGraph) TopologicalOrder() ([]*model.Task, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var stack []string
+	var order []*model.Task
+
+	var visit func(id string) error
+	visit = func(id string) error {
+		switch state[id] {
+		case visited:
+			return nil
+		case visiting:
+			for i, s := range stack {
+				if s == id {
+					cycle := append(append([]string{}, stack[i:]...), id)
+					return &CyclicDependencyError{Cycle: cycle}
+				}
+			}
+		}
+
+		state[id] = visiting
+		stack = append(stack, id)
+		for _, dep := range g.dependencies(id) {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[id] = visited
+		order = append(order, g.tasks[id])
+		return nil
+	}
+
+	for _, id := range g.ids {
+		if err := visit(id); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+func (g *DependencyGraph) ToDOT() string {
+	var buf bytes.Buffer
+	buf.WriteString("digraph tasks {\n")
+	for _, id := range g.ids {
+		tk := g.tasks[id]
+		fmt.Fprintf(&buf, "  %q [label=%q];\n", id, fmt.Sprintf("%s\n%s", tk.Region, tk.RunAt.Time().UTC().Format(model.StampLayout)))
+	}
+	for _, id := range g.ids {
+		for _, child := range g.children[id] {
+			fmt.Fprintf(&buf, "  %q -> %q;\n", id, child)
+		}
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+func (g *DependencyGraph) dependencies(id string) []string {
+	var deps []string
+	for _, dep := range g.tasks[id].DependsOn {
+		if _, ok := g.tasks[dep]; ok {
+			deps = append(deps, dep)
+		}
+	}
+	return deps
+}
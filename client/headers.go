@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+var ErrTokenRejected = errors.New("scheduler rejected auth token")
+
+func WithBaseHeaders(headers http.Header) ClientOption {
+	return func(c *Client) {
+		c.SetBaseHeaders(headers)
+	}
+}
+
+// SetBaseHeaders replaces the headers sent with every request. Headers already
+// set on a request take precedence over base headers.
+func (c *Client) SetBaseHeaders(headers http.Header) {
+	copied := copyHeader(headers)
+
+	c.transport.mux.Lock()
+	defer c.transport.mux.Unlock()
+	c.transport.headers = copied
+}
+
+// RenewToken replaces the bearer token sent with every request, then pings the
+// scheduler with it. The previous token is restored if the scheduler rejects
+// the new one.
+func (c *Client) RenewToken(ctx context.Context, newToken string) error {
+	c.transport.mux.Lock()
+	previous := c.transport.token
+	c.transport.token = newToken
+	c.transport.mux.Unlock()
+
+	err := c.ping(ctx)
+	if err == ErrTokenRejected {
+		c.transport.mux.Lock()
+		if c.transport.token == newToken {
+			c.transport.token = previous
+		}
+		c.transport.mux.Unlock()
+	}
+	return err
+}
+
+// ForwardAuth returns a copy of the client sending token as Bearer authorization
+// until ctx is done. The copy shares the connections of the original client,
+// which is left untouched.
+func (c *Client) ForwardAuth(ctx context.Context, token string) *Client {
+	return c.withHeader(ctx, "Authorization", "Bearer "+token)
+}
+
+// NewChildClient returns a copy of the client creating its tasks in the given
+// group until ctx is done. Tasks of a group are listed with ListByGroup and
+// deleted at once with CancelGroup.
+func (c *Client) NewChildClient(ctx context.Context, groupID string) (*Client, error) {
+	if strings.TrimSpace(groupID) == "" {
+		return nil, errors.New("empty task group")
+	}
+	if strings.ContainsAny(groupID, "\r\n") {
+		return nil, fmt.Errorf("invalid task group '%s'", groupID)
+	}
+	return c.withHeader(ctx, model.TaskGroupHeader, groupID), nil
+}
+
+// ListByGroup lists the pending and failed tasks of the group.
+func (c *Client) ListByGroup(ctx context.Context, groupID string) ([]*model.Task, error) {
+	if groupID == "" {
+		return nil, errors.New("empty task group")
+	}
+	return c.ListWithOptions(ctx, ListOptions{Group: groupID})
+}
+
+// CancelGroup deletes the pending tasks of the group. Tasks that could not be
+// deleted are reported by ID in a *MultiError.
+func (c *Client) CancelGroup(ctx context.Context, groupID string) error {
+	if groupID == "" {
+		return errors.New("empty task group")
+	}
+	tasks, err := c.ListWithOptions(ctx, ListOptions{Status: model.StatusPending, Group: groupID})
+	if err != nil {
+		return err
+	}
+	failures := make(map[string]error)
+	for _, tk := range tasks {
+		if err := c.Delete(ctx, tk.ID); err != nil && err != ErrNotFound {
+			failures[tk.ID] = err
+		}
+	}
+	if len(failures) > 0 {
+		return &MultiError{Errors: failures}
+	}
+	return nil
+}
+
+// withHeader returns a copy of the client setting the header on every request
+// until ctx is done. The copy shares the connections and the response cache of
+// the original client. The fields are copied one by one as the mutex cannot be.
+func (c *Client) withHeader(ctx context.Context, key, value string) *Client {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	return &Client{
+		ServiceURL:   c.ServiceURL,
+		serviceInfo:  c.serviceInfo,
+		discoveryURL: c.discoveryURL,
+		httpClient: &http.Client{
+			Timeout:   c.httpClient.Timeout,
+			Transport: &headerTransport{ctx: ctx, next: c.httpClient.Transport, key: key, value: value},
+		},
+		endpoints:        c.endpoints,
+		transport:        c.transport,
+		waitConcurrency:  c.waitConcurrency,
+		reachableTimeout: c.reachableTimeout,
+		cache:            c.cache,
+		discoveryRetry:   c.discoveryRetry,
+		socketPath:       c.socketPath,
+		unixDialer:       c.unixDialer,
+		secretsKey:       c.secretsKey,
+		listTimeout:      c.listTimeout,
+		maxOutputSize:    c.maxOutputSize,
+		outputEncoding:   c.outputEncoding,
+		dlq:              c.dlq,
+		formDefaults:     c.formDefaults,
+
+		maxScheduleHorizon: c.maxScheduleHorizon,
+		minRunIn:           c.minRunIn,
+	}
+}
+
+type headerTransport struct {
+	ctx        context.Context
+	next       http.RoundTripper
+	key, value string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(t.withHeader(req))
+}
+
+func (t *headerTransport) withHeader(req *http.Request) *http.Request {
+	r := new(http.Request)
+	*r = *req
+	r.Header = copyHeader(req.Header)
+	r.Header.Set(t.key, t.value)
+	return r
+}
+
+func (t *headerTransport) prepare(req *http.Request) *http.Request {
+	return prepare(t.next, t.withHeader(req))
+}
+
+func copyHeader(h http.Header) http.Header {
+	copied := make(http.Header, len(h))
+	for k, v := range h {
+		copied[k] = append([]string(nil), v...)
+	}
+	return copied
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+// GetStatus returns the status of a task without its content: pending,
+// running or failed while stored, then the status of its last run. Schedulers
+// without the status endpoint are handled with Get, knowing only stored tasks.
+func (c *Client) GetStatus(ctx context.Context, taskID string) (string, error) {
+	addr := c.serviceURL()
+	addr.Path = "tasks/" + taskID + "/status"
+
+	req, err := http.NewRequest(http.MethodGet, addr.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// schedulers without the endpoint look for a task with a /status suffix
+	if resp.StatusCode == http.StatusNotFound {
+		tk, err := c.Get(ctx, taskID)
+		if err != nil {
+			return "", err
+		}
+		return tk.Status, nil
+	}
+	if err = notOKStatus(addr.String(), resp); err != nil {
+		return "", err
+	}
+
+	var status struct {
+		Status string `json:"status"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return "", fmt.Errorf("cannot decode task status: %s", err)
+	}
+	return status.Status, nil
+}
+
+func (c *Client) GetHistory(ctx context.Context, taskID string, limit int) ([]*model.HistoryEntry, error) {
+	entries := make([]*model.HistoryEntry, 0)
+
+	addr := c.serviceURL()
+	addr.Path = "tasks/" + taskID + "/history"
+	if limit > 0 {
+		query := addr.Query()
+		query.Set("limit", strconv.Itoa(limit))
+		addr.RawQuery = query.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, addr.String(), nil)
+	if err != nil {
+		return entries, err
+	}
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return entries, err
+	}
+	defer resp.Body.Close()
+
+	if err = notOKStatus(addr.String(), resp); err != nil {
+		return entries, err
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return entries, err
+	}
+	if entries == nil {
+		entries = make([]*model.HistoryEntry, 0)
+	}
+
+	return entries, nil
+}
+
+// GetReverted tells whether the revert task of a task ran successfully,
+// returning the audit entry of its run. Schedulers only keep the history of
+// reverts since their start.
+func (c *Client) GetReverted(ctx context.Context, taskID string) (bool, *model.AuditEntry, error) {
+	entries, err := c.GetHistory(ctx, taskID, 0)
+	if err != nil {
+		return false, nil, err
+	}
+	for _, entry := range entries {
+		if entry.Status == model.StatusReverted {
+			return true, (*model.AuditEntry)(entry), nil
+		}
+	}
+	return false, nil, nil
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"errors"
+	"time"
+)
+
+const DefaultMaxScheduleHorizon = 365 * 24 * time.Hour
+
+var (
+	ErrScheduleTooFarFuture = errors.New("form run time is beyond the maximum schedule horizon")
+	ErrScheduleTooSoon      = errors.New("form run time is sooner than the minimum run delay")
+)
+
+// WithMaxScheduleHorizon rejects forms running later than d from now.
+func WithMaxScheduleHorizon(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.maxScheduleHorizon = d
+	}
+}
+
+// WithMinRunIn rejects forms running sooner than d from now.
+func WithMinRunIn(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.minRunIn = d
+	}
+}
+
+// validate is Form.Validate completed with the schedule limits of the client.
+func (c *Client) validate(f Form) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	return c.checkSchedule(f)
+}
+
+func (c *Client) checkSchedule(f Form) error {
+	var runIn time.Duration
+	run, err := f.runIn(time.Now())
+	if err != nil {
+		return err
+	}
+	if run != "" {
+		if runIn, err = time.ParseDuration(run); err != nil {
+			return err
+		}
+	}
+	if c.maxScheduleHorizon > 0 && runIn > c.maxScheduleHorizon {
+		return ErrScheduleTooFarFuture
+	}
+	if c.minRunIn > 0 && runIn < c.minRunIn {
+		return ErrScheduleTooSoon
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+// PostIdempotent posts the form unless a pending task of the same region has
+// the same template, whitespace aside. It returns the id of the existing or
+// posted task and whether it already existed.
+func (c *Client) PostIdempotent(ctx context.Context, f Form) (string, bool, error) {
+	f = c.withFormDefaults(f)
+	if f.AllRegions {
+		return "", false, errors.New("cannot post idempotently a form in all regions")
+	}
+
+	// the scheduler hashes templates as is, matching is done client side
+	tasks, err := c.ListWithOptions(ctx, ListOptions{Status: model.StatusPending, Region: f.Region})
+	if err != nil {
+		return "", false, err
+	}
+	template, err := f.Render()
+	if err != nil {
+		return "", false, err
+	}
+	hash := model.ContentHash(normalizeTemplate(template))
+	for _, tk := range tasks {
+		if model.ContentHash(normalizeTemplate(tk.Content)) == hash {
+			return tk.ID, true, nil
+		}
+	}
+
+	id, err := c.postContext(ctx, f)
+	return id, false, err
+}
+
+// normalizeTemplate trims the template lines, collapses their spaces and
+// drops empty lines.
+func normalizeTemplate(template string) string {
+	var lines []string
+	for _, line := range strings.Split(template, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			lines = append(lines, strings.Join(fields, " "))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Inspect returns the request Post would send for the form, without sending
+// it. Its headers include those added by the client transports. The options
+// acting after the request is sent, they do not change it.
+func (c *Client) Inspect(f Form, opts ...PostOption) (*http.Request, error) {
+	f = c.withFormDefaults(f)
+	if err := c.validate(f); err != nil {
+		return nil, err
+	}
+	if f.AllRegions {
+		return nil, errors.New("cannot inspect a form in all regions, posted once per region")
+	}
+
+	req, err := c.newFormRequest(context.Background(), http.MethodPost, "tasks", f)
+	if err != nil {
+		return nil, err
+	}
+	return prepare(c.httpClient.Transport, req), nil
+}
+
+// InspectList returns the first request ListWithOptions would send, without
+// sending it. Listing all statuses, the failures are then listed alike.
+func (c *Client) InspectList(opts ListOptions) (*http.Request, error) {
+	paths, err := opts.paths()
+	if err != nil {
+		return nil, err
+	}
+
+	addr := c.serviceURL()
+	addr.Path = paths[0]
+	addr.RawQuery = opts.query().Encode()
+	req, err := http.NewRequest(http.MethodGet, addr.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return prepare(c.httpClient.Transport, req), nil
+}
+
+// preparer is implemented by transports adding headers to the requests.
+type preparer interface {
+	prepare(*http.Request) *http.Request
+}
+
+func prepare(rt http.RoundTripper, req *http.Request) *http.Request {
+	if p, ok := rt.(preparer); ok {
+		return p.prepare(req)
+	}
+	return req
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// WithUnixSocketKeepalive drops idle unix socket connections past interval,
+// TCP keep-alive probes having no effect on unix sockets. An idempotent
+// request failing on a broken connection, typically after a scheduler
+// restart, is sent again on a new one.
+func WithUnixSocketKeepalive(interval time.Duration) ClientOption {
+	return func(c *Client) {
+		if c.unixDialer == nil {
+			return
+		}
+		if t, ok := c.transport.next.(*http.Transport); ok {
+			t.IdleConnTimeout = interval
+		}
+		c.transport.mux.Lock()
+		defer c.transport.mux.Unlock()
+		c.transport.redialBroken = true
+	}
+}
+
+const defaultUnixDialTimeout = 1 * time.Second
+
+// WithUnixDialTimeout caps the time spent connecting to the scheduler unix
+// socket, whatever the request timeout. It defaults to 1 second.
+func WithUnixDialTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		if c.unixDialer == nil {
+			if c.serviceInfo != nil {
+				log.Printf("[WARN] unix dial timeout ignored: scheduler not in unix socket mode")
+			}
+			return
+		}
+		c.unixDialer.Timeout = d
+	}
+}
+
+// ResetTransport drops the idle connections to the scheduler so that the
+// next request dials a new one.
+func (c *Client) ResetTransport() {
+	c.transport.mux.RLock()
+	next := c.transport.next
+	c.transport.mux.RUnlock()
+	closeIdleConnections(next)
+}
+
+func closeIdleConnections(rt http.RoundTripper) {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	if t, ok := rt.(interface{ CloseIdleConnections() }); ok {
+		t.CloseIdleConnections()
+	}
+}
+
+func isBrokenConn(err error) bool {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	switch e := err.(type) {
+	case *url.Error:
+		return isBrokenConn(e.Err)
+	case *net.OpError:
+		return isBrokenConn(e.Err)
+	case *os.SyscallError:
+		return isBrokenConn(e.Err)
+	case syscall.Errno:
+		return e == syscall.EPIPE || e == syscall.ECONNRESET
+	}
+	return false
+}
+
+// WithSocketWatcher has the client check, at most once per interval and
+// before sending a request, whether the scheduler recreated its unix socket.
+// Connections to the previous socket are dropped when it did.
+func WithSocketWatcher(interval time.Duration) ClientOption {
+	return func(c *Client) {
+		if c.serviceInfo == nil || !c.serviceInfo.UnixSockMode {
+			return
+		}
+		w := &socketWatcher{path: c.serviceInfo.ServiceAddr, interval: interval, checkedAt: time.Now()}
+		w.info, _ = os.Stat(w.path)
+
+		c.transport.mux.Lock()
+		defer c.transport.mux.Unlock()
+		c.transport.socketWatcher = w
+	}
+}
+
+type socketWatcher struct {
+	path     string
+	interval time.Duration
+
+	mux       sync.Mutex
+	checkedAt time.Time
+	info      os.FileInfo
+}
+
+func (w *socketWatcher) check(next http.RoundTripper) {
+	if w == nil {
+		return
+	}
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	if time.Since(w.checkedAt) < w.interval {
+		return
+	}
+	w.checkedAt = time.Now()
+
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return
+	}
+	if w.info != nil && !os.SameFile(w.info, info) {
+		log.Printf("[INFO] scheduler socket '%s' was recreated, reconnecting", w.path)
+		closeIdleConnections(next)
+	}
+	w.info = info
+}
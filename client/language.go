@@ -0,0 +1,49 @@
+package client
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	ContentLanguageAwless = "awless"
+	ContentLanguageBash   = "bash"
+	ContentLanguageHCL    = "hcl"
+)
+
+var (
+	hclBlock      = regexp.MustCompile(`^(resource|data|provider|variable|output|module|locals|terraform)(\s+"[^"]*")*\s*\{`)
+	bashStatement = regexp.MustCompile(`^((echo|export|set|source|function|while)\s|if \[|for \w+ in\s|fi$|done$)`)
+	awlessCommand = regexp.MustCompile(`^(\w+\s*=\s*)?(create|delete|start|stop|update|attach|detach|check|copy|import|restart|authenticate)\s+\w+`)
+	shebang       = regexp.MustCompile(`^#!.*\b(ba|z|k)?sh\b`)
+)
+
+// DetectContentLanguage guesses the language of a template from its first
+// recognized line. It returns an empty string when no line is recognized.
+func DetectContentLanguage(template string) string {
+	for i, line := range strings.Split(template, "\n") {
+		line = strings.TrimSpace(line)
+		if i == 0 && shebang.MatchString(line) {
+			return ContentLanguageBash
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		switch {
+		case hclBlock.MatchString(line):
+			return ContentLanguageHCL
+		case awlessCommand.MatchString(line):
+			return ContentLanguageAwless
+		case bashStatement.MatchString(line):
+			return ContentLanguageBash
+		}
+	}
+	return ""
+}
+
+func (f Form) contentLanguage() string {
+	if f.ContentLanguage == "" {
+		return DetectContentLanguage(f.Template)
+	}
+	return f.ContentLanguage
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+// LintTemplate has the scheduler parse and compile template. Schedulers not
+// supporting it are replaced by a basic client side linter.
+func (c *Client) LintTemplate(ctx context.Context, template string) (*model.LintResult, error) {
+	addr := c.serviceURL()
+	addr.Path = "lint"
+
+	req, err := http.NewRequest(http.MethodPost, addr.String(), strings.NewReader(template))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", DefaultContentType)
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return lintLocally(template), nil
+	}
+	if err = notOKStatus(addr.String(), resp); err != nil {
+		return nil, err
+	}
+
+	res := &model.LintResult{}
+	if err = json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return nil, fmt.Errorf("cannot decode lint result: %s", err)
+	}
+	return res, nil
+}
+
+var (
+	lintDeclaration = regexp.MustCompile(`^([a-zA-Z_][\w-]*)\s*=\s*(.*)$`)
+	lintCommand     = regexp.MustCompile(`^[a-z]+\s+[a-z]+(\s+[a-zA-Z][\w.-]*=\S+)*$`)
+	lintReference   = regexp.MustCompile(`\$([a-zA-Z_][\w-]*)`)
+	lintARN         = regexp.MustCompile(`^arn:aws[\w-]*:[\w-]+:[\w-]*:(\d{12}|aws)?:.+$`)
+	lintParam       = regexp.MustCompile(`[a-zA-Z][\w.-]*=(\S+)`)
+)
+
+func lintLocally(template string) *model.LintResult {
+	res := &model.LintResult{Errors: []model.LintError{}, Warnings: []model.LintWarning{}, Source: "client-side"}
+	declared := make(map[string]bool)
+
+	for i, line := range strings.Split(template, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		column := strings.Index(line, trimmed) + 1
+		lintErr := func(msg string, args ...interface{}) {
+			res.Errors = append(res.Errors, model.LintError{Line: i + 1, Column: column, Message: fmt.Sprintf(msg, args...)})
+		}
+
+		cmd := trimmed
+		var name string
+		if m := lintDeclaration.FindStringSubmatch(trimmed); m != nil {
+			name, cmd = m[1], m[2]
+		}
+		// declarations assign either a command or a single value
+		switch isCommand := lintCommand.MatchString(cmd); {
+		case !isCommand && name == "":
+			lintErr("expected '<action> <entity> [param=value ...]'")
+			continue
+		case !isCommand && strings.ContainsAny(cmd, " \t"):
+			lintErr("invalid command assigned to '%s'", name)
+			continue
+		}
+
+		for _, m := range lintReference.FindAllStringSubmatch(cmd, -1) {
+			if !declared[m[1]] {
+				lintErr("reference to undefined variable '%s'", m[1])
+			}
+		}
+		for _, m := range lintParam.FindAllStringSubmatch(cmd, -1) {
+			if strings.HasPrefix(m[1], "arn:") && !lintARN.MatchString(m[1]) {
+				lintErr("malformed ARN '%s'", m[1])
+			}
+		}
+		if name != "" {
+			declared[name] = true
+		}
+	}
+
+	res.Valid = len(res.Errors) == 0
+	return res
+}
@@ -0,0 +1,339 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+type ListOptions struct {
+	Status        string
+	Region        string
+	After, Before time.Time
+	Limit         int
+	Ascending     bool
+	ContentType   string
+	ContentHash   string
+	Group         string
+	Owner         string
+
+	// MaxConcurrency caps the regions listed at once by MultiRegionList.
+	MaxConcurrency int
+}
+
+// ListWithOptions filters and sorts client side the tasks listed by the
+// scheduler. Without Ascending, tasks keep the server order (latest RunAt first).
+func (c *Client) ListWithOptions(ctx context.Context, opts ListOptions) ([]*model.Task, error) {
+	return c.listWithOptions(opts, func(path string, query url.Values) ([]*model.Task, error) {
+		return c.listTasks(ctx, path, query)
+	})
+}
+
+func (c *Client) listWithOptions(opts ListOptions, list func(string, url.Values) ([]*model.Task, error)) ([]*model.Task, error) {
+	paths, err := opts.paths()
+	if err != nil {
+		return nil, err
+	}
+	query := opts.query()
+
+	var tasks []*model.Task
+	for _, path := range paths {
+		listed, err := list(path, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, tk := range listed {
+			if tk.Status == "" {
+				tk.Status = statusOfPath(path)
+			}
+			if opts.matches(tk) {
+				tasks = append(tasks, tk)
+			}
+		}
+	}
+
+	if opts.Ascending {
+		sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].RunAt.Time().Before(tasks[j].RunAt.Time()) })
+	} else if len(paths) > 1 {
+		sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].RunAt.Time().After(tasks[j].RunAt.Time()) })
+	}
+
+	if opts.Limit > 0 && len(tasks) > opts.Limit {
+		tasks = tasks[:opts.Limit]
+	}
+
+	return tasks, nil
+}
+
+// ListWithTimeout is ListWithOptions bounded by timeout, or by the client
+// timeout when zero.
+func (c *Client) ListWithTimeout(timeout time.Duration, opts ListOptions) ([]*model.Task, error) {
+	if timeout == 0 {
+		timeout = c.httpClient.Timeout
+	}
+	if timeout <= 0 {
+		return c.ListWithOptions(context.Background(), opts)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return c.ListWithOptions(ctx, opts)
+}
+
+// ListWithDeadline is ListWithOptions with the deadline bounding only the
+// requests to the scheduler: each listing is decoded, filtered and sorted once
+// received, whatever the time left.
+func (c *Client) ListWithDeadline(deadline time.Time, opts ListOptions) ([]*model.Task, error) {
+	return c.listWithOptions(opts, func(path string, query url.Values) ([]*model.Task, error) {
+		addr := c.serviceURL()
+		addr.Path = path
+		addr.RawQuery = query.Encode()
+
+		if v, ok := c.cache.load(addr.String()); ok {
+			return copyTasks(v.([]*model.Task)), nil
+		}
+
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		body, err := c.fetchTasks(ctx, addr)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		return c.decodeTasks(addr, body)
+	})
+}
+
+// ListByContentType lists the tasks whose template has the given media type,
+// media type parameters being ignored.
+func (c *Client) ListByContentType(ctx context.Context, contentType string) ([]*model.Task, error) {
+	return c.ListWithOptions(ctx, ListOptions{ContentType: contentType})
+}
+
+// GetByContentHash returns the pending or failed task whose template has the
+// given hash. Tasks having no creation time, the one with the latest run time
+// is returned when several share the hash.
+func (c *Client) GetByContentHash(ctx context.Context, hash string) (*model.Task, error) {
+	tasks, err := c.ListWithOptions(ctx, ListOptions{ContentHash: hash})
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, ErrNotFound
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if !tasks[i].RunAt.Time().Equal(tasks[j].RunAt.Time()) {
+			return tasks[i].RunAt.Time().After(tasks[j].RunAt.Time())
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+	return tasks[0], nil
+}
+
+func (c *Client) ListUpcoming(ctx context.Context, limit int) ([]*model.Task, error) {
+	return c.ListWithOptions(ctx, ListOptions{
+		Status:    model.StatusPending,
+		After:     time.Now(),
+		Limit:     limit,
+		Ascending: true,
+	})
+}
+
+func (c *Client) ListOverdue(ctx context.Context) ([]*model.Task, error) {
+	return c.ListWithOptions(ctx, ListOptions{
+		Status:    model.StatusPending,
+		Before:    time.Now(),
+		Ascending: true,
+	})
+}
+
+const defaultMultiRegionConcurrency = 5
+
+type MultiError struct {
+	Errors map[string]error
+}
+
+func (e *MultiError) Error() string {
+	var keys []string
+	for k := range e.Errors {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var msgs []string
+	for _, k := range keys {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", k, e.Errors[k]))
+	}
+	return fmt.Sprintf("%d error(s): %s", len(keys), strings.Join(msgs, ", "))
+}
+
+// MultiRegionList lists tasks of each region concurrently. With a deadline
+// on ctx, each region gets an equal share of the remaining time. Regions
+// that failed are reported in a *MultiError along with the partial results.
+func (c *Client) MultiRegionList(ctx context.Context, regions []string, opts ListOptions) (map[string][]*model.Task, error) {
+	concurrency := opts.MaxConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultMultiRegionConcurrency
+	}
+	var regionTimeout time.Duration
+	if deadline, ok := ctx.Deadline(); ok && len(regions) > 0 {
+		regionTimeout = time.Until(deadline) / time.Duration(len(regions))
+	}
+
+	var mux sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string][]*model.Task)
+	failures := make(map[string]error)
+	sem := make(chan struct{}, concurrency)
+
+	for _, region := range regions {
+		wg.Add(1)
+		go func(region string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			regionCtx := ctx
+			if regionTimeout > 0 {
+				var cancel context.CancelFunc
+				regionCtx, cancel = context.WithTimeout(ctx, regionTimeout)
+				defer cancel()
+			}
+
+			regionOpts := opts
+			regionOpts.Region = region
+			tasks, err := c.ListWithOptions(regionCtx, regionOpts)
+
+			mux.Lock()
+			defer mux.Unlock()
+			if err != nil {
+				failures[region] = err
+				return
+			}
+			results[region] = tasks
+		}(region)
+	}
+	wg.Wait()
+
+	if len(failures) > 0 {
+		return results, &MultiError{Errors: failures}
+	}
+	return results, nil
+}
+
+func (opts ListOptions) paths() ([]string, error) {
+	switch opts.Status {
+	case "":
+		return []string{"tasks", "failures"}, nil
+	case model.StatusPending, model.StatusRunning:
+		return []string{"tasks"}, nil
+	case model.StatusFailed:
+		return []string{"failures"}, nil
+	case model.StatusDone:
+		return []string{"done"}, nil
+	default:
+		return nil, fmt.Errorf("unknown task status '%s'", opts.Status)
+	}
+}
+
+// query holds the filters applied by the scheduler.
+func (opts ListOptions) query() url.Values {
+	query := url.Values{}
+	if opts.ContentType != "" {
+		query.Set("content_type", opts.ContentType)
+	}
+	if opts.ContentHash != "" {
+		query.Set("content_hash", opts.ContentHash)
+	}
+	if opts.Group != "" {
+		query.Set("group", opts.Group)
+	}
+	if opts.Owner != "" {
+		query.Set("owner", opts.Owner)
+	}
+	return query
+}
+
+func (opts ListOptions) matches(tk *model.Task) bool {
+	// pending tasks being executed are listed as running
+	if opts.Status != "" && tk.Status != opts.Status {
+		return false
+	}
+	if opts.Region != "" && tk.Region != opts.Region {
+		return false
+	}
+	if !opts.After.IsZero() && !tk.RunAt.Time().After(opts.After) {
+		return false
+	}
+	if !opts.Before.IsZero() && !tk.RunAt.Time().Before(opts.Before) {
+		return false
+	}
+	// schedulers not supporting the content_type param list all tasks
+	if opts.ContentType != "" && mediaType(opts.ContentType) != mediaType(tk.ContentType) {
+		return false
+	}
+	if opts.ContentHash != "" && contentHash(tk) != opts.ContentHash {
+		return false
+	}
+	if opts.Group != "" && tk.Group != opts.Group {
+		return false
+	}
+	if opts.Owner != "" && tk.Owner != opts.Owner {
+		return false
+	}
+	return true
+}
+
+func mediaType(contentType string) string {
+	if contentType == "" {
+		contentType = DefaultContentType
+	}
+	if t, _, err := mime.ParseMediaType(contentType); err == nil {
+		return t
+	}
+	return contentType
+}
+
+func statusOfPath(path string) string {
+	switch path {
+	case "failures":
+		return model.StatusFailed
+	case "done":
+		return model.StatusDone
+	}
+	return model.StatusPending
+}
+
+// ListByStatus lists the tasks of the given status. Done tasks are the ones
+// executed successfully since the scheduler start, with only their ID, run
+// and revert times and region.
+func (c *Client) ListByStatus(ctx context.Context, status string) ([]*model.Task, error) {
+	switch status {
+	case model.StatusPending, model.StatusRunning, model.StatusDone, model.StatusFailed:
+	default:
+		return nil, fmt.Errorf("unknown task status '%s'", status)
+	}
+	return c.ListWithOptions(ctx, ListOptions{Status: status})
+}
+
+func (c *Client) ListPending(ctx context.Context) ([]*model.Task, error) {
+	return c.ListByStatus(ctx, model.StatusPending)
+}
+
+func (c *Client) ListRunning(ctx context.Context) ([]*model.Task, error) {
+	return c.ListByStatus(ctx, model.StatusRunning)
+}
+
+func (c *Client) ListDone(ctx context.Context) ([]*model.Task, error) {
+	return c.ListByStatus(ctx, model.StatusDone)
+}
+
+func (c *Client) ListFailed(ctx context.Context) ([]*model.Task, error) {
+	return c.ListByStatus(ctx, model.StatusFailed)
+}
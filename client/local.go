@@ -0,0 +1,304 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+const (
+	stillExecutable              = -1 * time.Hour
+	localMinDurationBeforeRevert = 1 * time.Minute
+)
+
+// TaskExecutor runs the tasks fired by ListenAndExecute.
+type TaskExecutor interface {
+	Execute(ctx context.Context, t *model.Task) error
+}
+
+// TaskReverter is implemented by executors able to revert tasks. As on the
+// scheduler, reverts of successful tasks are fired at their RevertAt time.
+type TaskReverter interface {
+	Revert(ctx context.Context, t *model.Task) error
+}
+
+// ListenAndExecute serves the client requests from an in-memory scheduler,
+// firing tasks with executor, until ctx is done. It is meant to test
+// templates without a running scheduler.
+func (c *Client) ListenAndExecute(ctx context.Context, executor TaskExecutor) error {
+	s := &localScheduler{
+		executor: executor,
+		tasks:    make(map[string]*localTask),
+		failures: make(map[string]*model.Task),
+		wake:     make(chan struct{}, 1),
+	}
+
+	c.transport.mux.Lock()
+	previous := c.transport.next
+	c.transport.next = s
+	c.transport.mux.Unlock()
+	c.cache.invalidate("")
+
+	defer func() {
+		c.transport.mux.Lock()
+		c.transport.next = previous
+		c.transport.mux.Unlock()
+		c.cache.invalidate("")
+	}()
+
+	return s.run(ctx)
+}
+
+type localTask struct {
+	tk     *model.Task
+	revert bool
+}
+
+type localScheduler struct {
+	executor TaskExecutor
+
+	mux      sync.Mutex
+	tasks    map[string]*localTask
+	failures map[string]*model.Task
+	wake     chan struct{}
+}
+
+func (s *localScheduler) run(ctx context.Context) error {
+	for {
+		if s.fireExecutables(ctx) {
+			continue
+		}
+
+		timer := time.NewTimer(s.untilNext())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *localScheduler) untilNext() time.Duration {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	next := time.Hour
+	now := time.Now().UTC()
+	for _, lt := range s.tasks {
+		if wait := lt.tk.RunAt.Time().Sub(now); wait > 0 && wait < next {
+			next = wait
+		}
+	}
+	return next
+}
+
+func (s *localScheduler) fireExecutables(ctx context.Context) bool {
+	s.mux.Lock()
+	var executables []*localTask
+	now := time.Now().UTC()
+	for _, lt := range s.tasks {
+		if lt.tk.RunAt.Time().After(now.Add(stillExecutable)) && !lt.tk.RunAt.Time().After(now) && s.dependenciesDone(lt.tk) {
+			executables = append(executables, lt)
+		}
+	}
+	s.mux.Unlock()
+
+	sort.Slice(executables, func(i, j int) bool { return executables[i].tk.RunAt.Time().Before(executables[j].tk.RunAt.Time()) })
+
+	for _, lt := range executables {
+		var err error
+		if lt.revert {
+			err = s.executor.(TaskReverter).Revert(ctx, copyTask(lt.tk))
+		} else {
+			err = s.executor.Execute(ctx, copyTask(lt.tk))
+		}
+
+		s.mux.Lock()
+		delete(s.tasks, lt.tk.ID)
+		if err != nil {
+			failed := copyTask(lt.tk)
+			failed.Status = model.StatusFailed
+			s.failures[failed.ID] = failed
+		} else if _, ok := s.executor.(TaskReverter); ok && !lt.revert && !lt.tk.RevertAt.IsZero() {
+			s.add(&model.Task{Content: lt.tk.Content, RunAt: lt.tk.RevertAt, Region: lt.tk.Region}, true)
+		}
+		s.mux.Unlock()
+	}
+	return len(executables) > 0
+}
+
+func (s *localScheduler) dependenciesDone(tk *model.Task) bool {
+	for _, id := range tk.DependsOn {
+		if _, ok := s.tasks[id]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *localScheduler) add(tk *model.Task, revert bool) string {
+	tk.ID = tk.AsFilename()
+	tk.ContentHash = model.ContentHash(tk.Content)
+	tk.Status = model.StatusPending
+	s.tasks[tk.ID] = &localTask{tk: tk, revert: revert}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return tk.ID
+}
+
+func (s *localScheduler) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	resp := rec.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+func (s *localScheduler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	switch {
+	case path == "":
+		w.WriteHeader(http.StatusOK)
+	case path == "regions":
+		writeLocalJSON(w, []string{})
+	case path == "version":
+		writeLocalJSON(w, model.VersionInfo{Version: "local", APIVersion: APIVersion})
+	case path == "tasks" && r.Method == http.MethodGet:
+		writeLocalJSON(w, s.list(false))
+	case path == "failures" && r.Method == http.MethodGet:
+		writeLocalJSON(w, s.list(true))
+	case path == "tasks" && r.Method == http.MethodPost:
+		tk, err := readLocalTask(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mux.Lock()
+		id := s.add(tk, false)
+		s.mux.Unlock()
+		w.Write([]byte(id))
+	case strings.HasPrefix(path, "tasks/") && (r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodDelete):
+		id := strings.TrimPrefix(path, "tasks/")
+		s.mux.Lock()
+		lt, ok := s.tasks[id]
+		if ok && r.Method == http.MethodDelete {
+			delete(s.tasks, id)
+		}
+		s.mux.Unlock()
+		if !ok {
+			http.Error(w, "task not found", http.StatusNotFound)
+			return
+		}
+		if r.Method != http.MethodDelete {
+			writeLocalJSON(w, lt.tk)
+		}
+	default:
+		http.Error(w, "not supported by local scheduler", http.StatusNotImplemented)
+	}
+}
+
+func (s *localScheduler) list(failures bool) []*model.Task {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	tasks := []*model.Task{}
+	if failures {
+		for _, tk := range s.failures {
+			tasks = append(tasks, copyTask(tk))
+		}
+	} else {
+		for _, lt := range s.tasks {
+			tasks = append(tasks, copyTask(lt.tk))
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].RunAt.Time().Before(tasks[j].RunAt.Time()) })
+	return tasks
+}
+
+func readLocalTask(r *http.Request) (*model.Task, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	region := r.FormValue("region")
+	if region == "" {
+		return nil, errors.New("missing region")
+	}
+
+	now := time.Now().UTC()
+	tk := &model.Task{Region: region, RunAt: model.FlexibleTime(now), DependsOn: r.Form["depends_on"], ContentLanguage: r.FormValue("content_language"), Owner: r.FormValue("owner")}
+	tk.CronExpr, tk.Description = r.FormValue("cron"), r.FormValue("description")
+	if retries := r.FormValue("max_retries"); retries != "" {
+		maxRetries, err := strconv.Atoi(retries)
+		if err != nil || maxRetries < 0 {
+			return nil, errors.New("invalid count for 'max_retries' param")
+		}
+		tk.MaxRetries = maxRetries
+	}
+	for _, tag := range r.Form["tag"] {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid tag '%s'", tag)
+		}
+		if tk.Tags == nil {
+			tk.Tags = make(map[string]string)
+		}
+		tk.Tags[kv[0]] = kv[1]
+	}
+	if run := r.FormValue("run"); run != "" {
+		d, err := time.ParseDuration(run)
+		if err != nil {
+			return nil, errors.New("invalid duration for 'run' param")
+		}
+		tk.RunAt = model.FlexibleTime(now.Add(d))
+	}
+	if revert := r.FormValue("revert"); revert != "" {
+		d, err := time.ParseDuration(revert)
+		if err != nil {
+			return nil, errors.New("invalid duration for 'revert' param")
+		}
+		tk.RevertAt = model.FlexibleTime(now.Add(d))
+		if tk.RevertAt.Time().Sub(tk.RunAt.Time()) < localMinDurationBeforeRevert {
+			return nil, errors.New("revert time is too close to run time")
+		}
+	}
+
+	content, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	tk.Content = string(content)
+	if r.Header.Get("Content-Type") == model.FormContentType {
+		var form struct{ Template string }
+		if err = json.Unmarshal(content, &form); err != nil {
+			return nil, err
+		}
+		tk.Content = form.Template
+	}
+	return tk, nil
+}
+
+func writeLocalJSON(w http.ResponseWriter, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTaskLocked  = errors.New("task locked")
+	ErrLockExpired = errors.New("task lock expired")
+)
+
+func init() {
+	RegisterErrorCode("TASK_LOCKED", ErrTaskLocked)
+	RegisterErrorCode("LOCK_EXPIRED", ErrLockExpired)
+}
+
+// Lock prevents other clients from rescheduling, updating or deleting a task
+// for ttl. The returned token is needed to modify the task or unlock it.
+func (c *Client) Lock(ctx context.Context, taskID string, ttl time.Duration) (string, error) {
+	addr := c.serviceURL()
+	addr.Path = "tasks/" + taskID + "/lock"
+	query := addr.Query()
+	query.Set("ttl", ttl.String())
+	addr.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, addr.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", ErrNotFound
+	}
+	if err = notOKStatus(addr.String(), resp); err != nil {
+		return "", sentinel(err)
+	}
+
+	token, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("cannot read lock token from '%s': %s", addr.String(), err)
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+func (c *Client) Unlock(ctx context.Context, taskID string, lockToken string) error {
+	addr := c.serviceURL()
+	addr.Path = "tasks/" + taskID + "/lock"
+	query := addr.Query()
+	query.Set("lock_token", lockToken)
+	addr.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodDelete, addr.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return sentinel(notOKStatus(addr.String(), resp))
+}
+
+// RescheduleWithLock is Reschedule on a task locked with lockToken. The lock
+// follows the task to its new id.
+func (c *Client) RescheduleWithLock(ctx context.Context, taskID, lockToken string, f Form) (string, error) {
+	if err := c.validate(f); err != nil {
+		return "", err
+	}
+	if f.AllRegions {
+		return "", errors.New("cannot reschedule a task in all regions")
+	}
+
+	req, err := c.newFormRequest(ctx, http.MethodPut, "tasks/"+taskID, f)
+	if err != nil {
+		return "", err
+	}
+	query := req.URL.Query()
+	query.Set("lock_token", lockToken)
+	req.URL.RawQuery = query.Encode()
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if err = notOKStatus(req.URL.String(), resp); err != nil {
+		return "", sentinel(err)
+	}
+	c.cache.invalidateTasks()
+
+	id, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("cannot read task id from '%s': %s", req.URL.String(), err)
+	}
+	return strings.TrimSpace(string(id)), nil
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	scoreHalfLife = 1 * time.Minute
+	scoreRefresh  = 30 * time.Second
+)
+
+// NewMulti routes requests to the live HTTP endpoint with the lowest ping latency
+// among all schedulers found through the given discovery URLs.
+func NewMulti(discoveryURLs ...string) (*Client, error) {
+	httpClient := &http.Client{Timeout: 3 * time.Second}
+
+	var c *Client
+	var errs []string
+	eps := &endpoints{client: httpClient}
+	seen := make(map[string]bool)
+
+	for _, discoveryURL := range discoveryURLs {
+		v, err := discover(context.Background(), httpClient, discoveryURL)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if c == nil {
+			if c, err = newFromServiceInfo(httpClient, v); err != nil {
+				return nil, err
+			}
+			c.discoveryURL = discoveryURL
+		}
+		if v.UnixSockMode || seen[v.ServiceAddr] {
+			continue
+		}
+		addr, err := url.Parse(v.ServiceAddr)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("cannot parse scheduler service addr %s: %s", v.ServiceAddr, err))
+			continue
+		}
+		seen[v.ServiceAddr] = true
+		eps.urls = append(eps.urls, addr)
+	}
+
+	if c == nil {
+		return nil, fmt.Errorf("cannot discover scheduler from any of %d urls: %s", len(discoveryURLs), strings.Join(errs, "; "))
+	}
+
+	if !c.serviceInfo.UnixSockMode && len(eps.urls) > 1 {
+		c.endpoints = eps
+		go eps.measure()
+	}
+
+	return c, nil
+}
+
+// EndpointScores returns the current decayed latency score of each live endpoint.
+func (c *Client) EndpointScores() map[string]time.Duration {
+	scores := make(map[string]time.Duration)
+	if c.endpoints == nil {
+		return scores
+	}
+
+	now := time.Now()
+	neutral := c.endpoints.neutral()
+	c.endpoints.scores.Range(func(k, v interface{}) bool {
+		if s := v.(endpointScore); s.live {
+			scores[k.(string)] = s.decayed(now, neutral)
+		}
+		return true
+	})
+	return scores
+}
+
+type endpoints struct {
+	client    *http.Client
+	urls      []*url.URL
+	scores    sync.Map
+	measuring int32
+}
+
+type endpointScore struct {
+	latency    time.Duration
+	measuredAt time.Time
+	live       bool
+}
+
+// decayed moves the measured latency toward neutral by elapsed half-lives, so
+// that an old measurement ranks an endpoint neither first nor last.
+func (s endpointScore) decayed(now time.Time, neutral time.Duration) time.Duration {
+	halvings := uint(now.Sub(s.measuredAt) / scoreHalfLife)
+	if halvings > 62 {
+		return neutral
+	}
+	return neutral + (s.latency-neutral)/(1<<halvings)
+}
+
+// neutral is the mean latency of the live endpoints.
+func (e *endpoints) neutral() time.Duration {
+	var sum time.Duration
+	var count int
+	e.scores.Range(func(_, v interface{}) bool {
+		if s := v.(endpointScore); s.live {
+			sum += s.latency
+			count++
+		}
+		return true
+	})
+	if count == 0 {
+		return 0
+	}
+	return sum / time.Duration(count)
+}
+
+func (e *endpoints) best() (url.URL, bool) {
+	var found bool
+	var best url.URL
+	var bestScore time.Duration
+	var stale bool
+
+	now := time.Now()
+	neutral := e.neutral()
+	for _, u := range e.urls {
+		v, ok := e.scores.Load(u.String())
+		if !ok {
+			continue
+		}
+		s := v.(endpointScore)
+		if now.Sub(s.measuredAt) > scoreRefresh {
+			stale = true
+		}
+		if !s.live {
+			continue
+		}
+		if score := s.decayed(now, neutral); !found || score < bestScore {
+			found, best, bestScore = true, *u, score
+		}
+	}
+
+	if stale {
+		go e.measure()
+	}
+
+	return best, found
+}
+
+func (e *endpoints) measure() {
+	if !atomic.CompareAndSwapInt32(&e.measuring, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&e.measuring, 0)
+
+	var wg sync.WaitGroup
+	for _, u := range e.urls {
+		wg.Add(1)
+		go func(u *url.URL) {
+			defer wg.Done()
+			start := time.Now()
+			resp, err := e.client.Get(u.String())
+			if err == nil {
+				err = notOKStatus(u.String(), resp)
+				resp.Body.Close()
+			}
+			e.scores.Store(u.String(), endpointScore{
+				latency:    time.Since(start),
+				measuredAt: time.Now(),
+				live:       err == nil,
+			})
+		}(u)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+type SerialiserMismatch struct {
+	Fields []string
+}
+
+func (e *SerialiserMismatch) Error() string {
+	return fmt.Sprintf("form echoed by scheduler differs on: %s", strings.Join(e.Fields, ", "))
+}
+
+// Noop has the scheduler decode and validate the form without storing any
+// task, then checks the echoed form is identical to the submitted one. The
+// scheduler only echoes the names of the secrets, so the echoed form has no
+// SecretEnv and secrets are compared by name.
+func (c *Client) Noop(ctx context.Context, f Form) (*Form, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	if f.AllRegions {
+		return nil, errors.New("cannot noop a form in all regions")
+	}
+
+	// the scheduler only sees the run duration
+	run, err := f.runIn(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	f.RunIn, f.RunAtExpression = run, ""
+	f.Owner = c.owner(f)
+	// the scheduler only sees the rendered template
+	if f.Template, err = f.Render(); err != nil {
+		return nil, err
+	}
+	f.Vars = nil
+
+	req, err := c.newFormRequest(ctx, http.MethodPost, "noop", f)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err = notOKStatus(req.URL.String(), resp); err != nil {
+		return nil, err
+	}
+
+	var res struct {
+		Form
+		SecretKeys []string
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("cannot decode echoed form: %s", err)
+	}
+	echoed := &res.Form
+
+	f.ContentType, echoed.ContentType = f.contentType(), echoed.contentType()
+	f.ContentLanguage, echoed.ContentLanguage = f.contentLanguage(), echoed.contentLanguage()
+	secretKeys := make([]string, 0, len(f.SecretEnv))
+	for k := range f.SecretEnv {
+		secretKeys = append(secretKeys, k)
+	}
+	sort.Strings(secretKeys)
+	f.SecretEnv, echoed.SecretEnv = nil, nil
+
+	fields := mismatchingFields(f, *echoed)
+	if len(secretKeys) > 0 || len(res.SecretKeys) > 0 {
+		if !reflect.DeepEqual(secretKeys, res.SecretKeys) {
+			fields = append(fields, "SecretEnv")
+		}
+	}
+	if len(fields) > 0 {
+		return echoed, &SerialiserMismatch{Fields: fields}
+	}
+	return echoed, nil
+}
+
+func mismatchingFields(sent, echoed Form) []string {
+	var fields []string
+	sentValue, echoedValue := reflect.ValueOf(sent), reflect.ValueOf(echoed)
+	for i := 0; i < sentValue.NumField(); i++ {
+		a, b := sentValue.Field(i), echoedValue.Field(i)
+		if (a.Kind() == reflect.Slice || a.Kind() == reflect.Map) && a.Len() == 0 && b.Len() == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			fields = append(fields, sentValue.Type().Field(i).Name)
+		}
+	}
+	return fields
+}
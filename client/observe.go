@@ -0,0 +1,147 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultObservedBodySize = 1024
+	observerBufferSize      = 100
+)
+
+type RequestObservation struct {
+	Method, URL string
+	StatusCode  int
+	Latency     time.Duration
+	Body        []byte
+	Err         error
+}
+
+// WithObservedBodySize sets how many bytes of response bodies are captured
+// for request observers.
+func WithObservedBodySize(size int) ClientOption {
+	return func(c *Client) {
+		c.transport.mux.Lock()
+		defer c.transport.mux.Unlock()
+		c.transport.observedBody = size
+	}
+}
+
+// ObserveRequests delivers every request made by the client until ctx is done,
+// the channel being then closed. Observations are dropped rather than
+// delaying requests when the channel is not consumed fast enough.
+func (c *Client) ObserveRequests(ctx context.Context) <-chan RequestObservation {
+	o := &observer{
+		queue: make(chan RequestObservation, observerBufferSize),
+		out:   make(chan RequestObservation),
+	}
+
+	c.transport.mux.Lock()
+	if c.transport.observers == nil {
+		c.transport.observers = make(map[*observer]struct{})
+	}
+	c.transport.observers[o] = struct{}{}
+	c.transport.mux.Unlock()
+
+	go func() {
+		defer close(o.out)
+		defer func() {
+			c.transport.mux.Lock()
+			delete(c.transport.observers, o)
+			c.transport.mux.Unlock()
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case obs := <-o.queue:
+				select {
+				case o.out <- obs:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return o.out
+}
+
+type observer struct {
+	queue, out chan RequestObservation
+}
+
+func (t *transport) observe(req *http.Request, resp *http.Response, err error, start time.Time) (*http.Response, error) {
+	t.mux.RLock()
+	observers := make([]*observer, 0, len(t.observers))
+	for o := range t.observers {
+		observers = append(observers, o)
+	}
+	bodySize := t.observedBody
+	t.mux.RUnlock()
+
+	if len(observers) == 0 {
+		return resp, err
+	}
+	if bodySize <= 0 {
+		bodySize = defaultObservedBodySize
+	}
+
+	obs := RequestObservation{Method: req.Method, URL: req.URL.String(), Err: err}
+	if err != nil {
+		obs.Latency = time.Since(start)
+		notify(observers, obs)
+		return resp, err
+	}
+
+	obs.StatusCode = resp.StatusCode
+	resp.Body = &observedBody{ReadCloser: resp.Body, obs: obs, start: start, size: bodySize, observers: observers}
+	return resp, nil
+}
+
+func notify(observers []*observer, obs RequestObservation) {
+	for _, o := range observers {
+		select {
+		case o.queue <- obs:
+		default:
+		}
+	}
+}
+
+// observedBody captures the beginning of a response body and notifies
+// observers once the body is closed.
+type observedBody struct {
+	io.ReadCloser
+	obs       RequestObservation
+	start     time.Time
+	size      int
+	buf       bytes.Buffer
+	observers []*observer
+	closed    bool
+}
+
+func (b *observedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if remaining := b.size - b.buf.Len(); remaining > 0 && n > 0 {
+		if n < remaining {
+			remaining = n
+		}
+		b.buf.Write(p[:remaining])
+	}
+	return n, err
+}
+
+func (b *observedBody) Close() error {
+	err := b.ReadCloser.Close()
+	if !b.closed {
+		b.closed = true
+		b.obs.Latency = time.Since(b.start)
+		b.obs.Body = b.buf.Bytes()
+		notify(b.observers, b.obs)
+	}
+	return err
+}
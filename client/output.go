@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+const defaultMaxOutputSize = 1 << 20
+
+type OutputEncoding int
+
+const (
+	OutputUTF8 OutputEncoding = iota
+	OutputBase64
+)
+
+type TaskOutput struct {
+	TaskID      string
+	Output      string
+	Encoding    OutputEncoding
+	Truncated   bool
+	TruncatedAt int64
+}
+
+// WithMaxOutputSize limits the bytes of output read by GetOutput.
+func WithMaxOutputSize(size int64) ClientOption {
+	return func(c *Client) {
+		c.maxOutputSize = size
+	}
+}
+
+// WithOutputEncoding has GetOutput return outputs as UTF-8 text (default) or
+// as base64 encoded bytes for binary outputs.
+func WithOutputEncoding(enc OutputEncoding) ClientOption {
+	return func(c *Client) {
+		c.outputEncoding = enc
+	}
+}
+
+// GetOutput returns the output of the last execution of a task.
+func (c *Client) GetOutput(ctx context.Context, taskID string) (*TaskOutput, error) {
+	addr := c.serviceURL()
+	addr.Path = "tasks/" + taskID + "/output"
+
+	req, err := http.NewRequest(http.MethodGet, addr.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if err = notOKStatus(addr.String(), resp); err != nil {
+		return nil, err
+	}
+
+	max := c.maxOutputSize
+	if max <= 0 {
+		max = defaultMaxOutputSize
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, max+1))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read output of task %s: %s", taskID, err)
+	}
+
+	out := &TaskOutput{TaskID: taskID, Encoding: c.outputEncoding}
+	if int64(len(b)) > max {
+		b = b[:max]
+		out.Truncated, out.TruncatedAt = true, max
+	}
+	if out.Encoding == OutputBase64 {
+		out.Output = base64.StdEncoding.EncodeToString(b)
+	} else {
+		out.Output = string(b)
+	}
+	return out, nil
+}
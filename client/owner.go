@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+// WithBearerToken sends token as Bearer authorization with every request. If
+// token is a JWT, its sub claim is the client identity recorded as owner of
+// the posted tasks.
+func WithBearerToken(token string) ClientOption {
+	return func(c *Client) {
+		c.transport.mux.Lock()
+		defer c.transport.mux.Unlock()
+		c.transport.token = token
+	}
+}
+
+// ListMine lists the pending and failed tasks owned by the client identity.
+func (c *Client) ListMine(ctx context.Context) ([]*model.Task, error) {
+	identity := c.identity()
+	if identity == "" {
+		return nil, errors.New("no client identity: bearer token is not a JWT with a sub claim")
+	}
+	return c.ListWithOptions(ctx, ListOptions{Owner: identity})
+}
+
+func (c *Client) owner(f Form) string {
+	if f.Owner == "" {
+		return c.identity()
+	}
+	return f.Owner
+}
+
+// identity is the sub claim of the JWT sent as Bearer authorization, including
+// tokens forwarded with ForwardAuth. The token is not verified: the scheduler
+// records the owner declared by the client.
+func (c *Client) identity() string {
+	req := prepare(c.httpClient.Transport, &http.Request{Header: http.Header{}})
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return ""
+	}
+	parts := strings.Split(strings.TrimPrefix(auth, "Bearer "), ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		Sub string `json:"sub"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	return claims.Sub
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+var errPairUnsupported = errors.New("task pairs not supported by scheduler")
+
+// PostWithRollback schedules a task along with the task reverting it, the
+// revert task depending on the run task and being cancelled with it.
+// Schedulers not supporting task pairs get two posts, the revert form
+// depending on the run task. Then the run task is cancelled if the revert
+// form fails, but cancelling the run task later leaves the revert task.
+func (c *Client) PostWithRollback(ctx context.Context, runForm Form, revertForm Form) (runID, revertID string, err error) {
+	runForm, revertForm = c.withFormDefaults(runForm), c.withFormDefaults(revertForm)
+	for _, f := range []Form{runForm, revertForm} {
+		if err = c.validate(f); err != nil {
+			return "", "", err
+		}
+		if f.AllRegions {
+			return "", "", errors.New("cannot post a task pair in all regions")
+		}
+	}
+
+	ids, err := c.postPair(ctx, runForm, revertForm)
+	if err != errPairUnsupported {
+		return ids.RunID, ids.RevertID, err
+	}
+
+	if runID, err = c.submit(ctx, http.MethodPost, "tasks", runForm); err != nil {
+		return "", "", err
+	}
+	revertForm.DependsOn = append(append([]string(nil), revertForm.DependsOn...), runID)
+	if revertID, err = c.submit(ctx, http.MethodPost, "tasks", revertForm); err != nil {
+		if cancelErr := c.Delete(ctx, runID); cancelErr != nil {
+			log.Printf("[WARN] cannot cancel task %s of task pair: %s", runID, cancelErr)
+		}
+		return "", "", err
+	}
+	return runID, revertID, nil
+}
+
+func (c *Client) postPair(ctx context.Context, runForm, revertForm Form) (model.TaskPairIDs, error) {
+	var ids model.TaskPairIDs
+	run, err := c.pairPart(ctx, runForm)
+	if err != nil {
+		return ids, err
+	}
+	revert, err := c.pairPart(ctx, revertForm)
+	if err != nil {
+		return ids, err
+	}
+	body, err := json.Marshal(model.TaskPair{Run: run, Revert: revert})
+	if err != nil {
+		return ids, err
+	}
+
+	addr := c.serviceURL()
+	addr.Path = "tasks/pair"
+	req, err := http.NewRequest(http.MethodPost, addr.String(), bytes.NewReader(body))
+	if err != nil {
+		return ids, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return ids, err
+	}
+	defer resp.Body.Close()
+
+	// older schedulers route the path to a task which cannot be posted
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		return ids, errPairUnsupported
+	}
+	if err = notOKStatus(addr.String(), resp); err != nil {
+		return ids, err
+	}
+	c.cache.invalidateTasks()
+
+	if err = json.NewDecoder(resp.Body).Decode(&ids); err != nil {
+		return ids, fmt.Errorf("cannot decode task pair ids from '%s': %s", addr.String(), err)
+	}
+	return ids, nil
+}
+
+// pairPart is the POST /tasks request of the form.
+func (c *Client) pairPart(ctx context.Context, f Form) (model.TaskPairPart, error) {
+	req, err := c.newFormRequest(ctx, http.MethodPost, "tasks", f)
+	if err != nil {
+		return model.TaskPairPart{}, err
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return model.TaskPairPart{}, err
+	}
+	return model.TaskPairPart{Query: req.URL.RawQuery, ContentType: req.Header.Get("Content-Type"), Body: string(body)}, nil
+}
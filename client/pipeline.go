@@ -0,0 +1,132 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// FailurePolicy tells what becomes of the following steps of a pipeline when
+// a step fails. Only abort and continue are supported: previous steps cannot
+// be reverted on failure, their revert templates being only known once they
+// ran. Steps with a RevertIn are reverted at their revert time.
+type FailurePolicy int
+
+const (
+	// FailureAbort never runs the following steps
+	FailureAbort FailurePolicy = iota
+	// FailureContinue runs the following steps anyway
+	FailureContinue
+)
+
+// Pipeline is a sequence of templates run in a region. Tasks of a named
+// pipeline are in the task group of its name.
+type Pipeline struct {
+	Name, Region string
+	Steps        []PipelineStep
+}
+
+// PipelineStep runs at its offset from the previous step, or from now for the
+// first step. RevertIn is relative to the step run time, without revert if zero.
+type PipelineStep struct {
+	Name                  string
+	Template              string
+	RunOffsetFromPrevious time.Duration
+	RevertIn              time.Duration
+	OnFailure             FailurePolicy
+}
+
+type PipelineResult struct {
+	Name  string
+	Steps []PipelineStepResult
+}
+
+type PipelineStepResult struct {
+	Name, TaskID string
+}
+
+// PostMultiStep posts the steps of the pipeline, each one depending on the
+// previous step unless it continues on failure. Posted steps are cancelled if
+// a step cannot be posted.
+func (c *Client) PostMultiStep(ctx context.Context, pipeline Pipeline) (*PipelineResult, error) {
+	forms, err := pipeline.forms()
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range forms {
+		if err = c.validate(c.withFormDefaults(f)); err != nil {
+			return nil, fmt.Errorf("pipeline step %s: %s", pipeline.stepName(i), err)
+		}
+	}
+
+	poster := c
+	if pipeline.Name != "" {
+		if poster, err = c.NewChildClient(ctx, pipeline.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	res := &PipelineResult{Name: pipeline.Name}
+	var previous string
+	for i, f := range forms {
+		// previous is empty after a step continuing on failure
+		if previous != "" {
+			f.DependsOn = append(f.DependsOn, previous)
+		}
+		id, err := poster.submit(ctx, http.MethodPost, "tasks", c.withFormDefaults(f))
+		if err != nil {
+			c.cancelSteps(ctx, res)
+			return nil, fmt.Errorf("pipeline step %s: %s", pipeline.stepName(i), err)
+		}
+		res.Steps = append(res.Steps, PipelineStepResult{Name: pipeline.stepName(i), TaskID: id})
+
+		previous = id
+		if pipeline.Steps[i].OnFailure == FailureContinue {
+			previous = ""
+		}
+	}
+	return res, nil
+}
+
+func (p Pipeline) forms() ([]Form, error) {
+	if len(p.Steps) == 0 {
+		return nil, errors.New("empty pipeline")
+	}
+
+	var forms []Form
+	var runIn time.Duration
+	for i, step := range p.Steps {
+		if step.RunOffsetFromPrevious < 0 || step.RevertIn < 0 {
+			return nil, fmt.Errorf("pipeline step %s: negative duration", p.stepName(i))
+		}
+		if step.OnFailure != FailureAbort && step.OnFailure != FailureContinue {
+			return nil, fmt.Errorf("pipeline step %s: unsupported failure policy %d", p.stepName(i), step.OnFailure)
+		}
+
+		runIn += step.RunOffsetFromPrevious
+		f := Form{Region: p.Region, Template: step.Template, RunIn: runIn.String()}
+		if step.RevertIn > 0 {
+			f.RevertIn = (runIn + step.RevertIn).String()
+		}
+		forms = append(forms, f)
+	}
+	return forms, nil
+}
+
+func (p Pipeline) stepName(i int) string {
+	if name := p.Steps[i].Name; name != "" {
+		return name
+	}
+	return fmt.Sprintf("%d", i)
+}
+
+func (c *Client) cancelSteps(ctx context.Context, res *PipelineResult) {
+	for _, step := range res.Steps {
+		if err := c.Delete(ctx, step.TaskID); err != nil && err != ErrNotFound {
+			log.Printf("[WARN] cannot cancel pipeline step %s: %s", step.Name, err)
+		}
+	}
+}
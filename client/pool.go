@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ClientPool shares clients, e.g. with distinct schedulers or tokens, among
+// goroutines.
+type ClientPool struct {
+	factory func() (*Client, error)
+	idle    chan *Client
+
+	mux sync.Mutex
+	// acquired tells whether the clients of the pool are acquired
+	acquired map[*Client]bool
+}
+
+// NewClientPool creates at once the size clients of the pool with factory.
+func NewClientPool(size int, factory func() (*Client, error)) (*ClientPool, error) {
+	if size < 1 {
+		return nil, errors.New("client pool size must be positive")
+	}
+	p := &ClientPool{
+		factory:  factory,
+		idle:     make(chan *Client, size),
+		acquired: make(map[*Client]bool),
+	}
+	for i := 0; i < size; i++ {
+		c, err := factory()
+		if err != nil {
+			return nil, fmt.Errorf("cannot create pool client: %s", err)
+		}
+		p.acquired[c] = false
+		p.idle <- c
+	}
+	return p, nil
+}
+
+// Acquire returns an idle client, waiting for one until ctx is done.
+func (p *ClientPool) Acquire(ctx context.Context) (*Client, error) {
+	select {
+	case c := <-p.idle:
+		p.mux.Lock()
+		p.acquired[c] = true
+		p.mux.Unlock()
+		return c, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// poolHealthCheckTimeout bounds the reachability check of released clients.
+const poolHealthCheckTimeout = 2 * time.Second
+
+// Release gives back an acquired client to the pool. Clients whose scheduler
+// is no longer reachable are replaced by a new one from the factory, in the
+// background, the factory being retried with backoff until it succeeds.
+// Clients not acquired from the pool are ignored.
+func (p *ClientPool) Release(c *Client) {
+	p.mux.Lock()
+	ok := p.acquired[c]
+	if ok {
+		p.acquired[c] = false
+	}
+	p.mux.Unlock()
+	if !ok {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), poolHealthCheckTimeout)
+		reachable := c.Reachable(ctx)
+		cancel()
+		if !reachable {
+			p.mux.Lock()
+			delete(p.acquired, c)
+			p.mux.Unlock()
+			c = p.replace()
+		}
+		p.idle <- c
+	}()
+}
+
+func (p *ClientPool) replace() *Client {
+	for attempt := 0; ; attempt++ {
+		c, err := p.factory()
+		if err == nil {
+			p.mux.Lock()
+			p.acquired[c] = false
+			p.mux.Unlock()
+			return c
+		}
+		wait := backoff(attempt)
+		log.Printf("[WARN] cannot replace unhealthy pool client, retrying in %s: %s", wait, err)
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+const (
+	// APIVersion is the scheduler API version this client speaks.
+	APIVersion = 1
+
+	// MinSupportedAPIVersion and MaxSupportedAPIVersion bound the scheduler
+	// API versions this client works with.
+	MinSupportedAPIVersion = 1
+	MaxSupportedAPIVersion = APIVersion
+)
+
+func (c *Client) Version(ctx context.Context) (*model.VersionInfo, error) {
+	addr := c.serviceURL()
+	addr.Path = "version"
+
+	req, err := http.NewRequest(http.MethodGet, addr.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err = notOKStatus(addr.String(), resp); err != nil {
+		return nil, err
+	}
+
+	v := &model.VersionInfo{}
+	if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return nil, fmt.Errorf("cannot decode version from '%s': %s", addr.String(), err)
+	}
+	return v, nil
+}
+
+// IsCompatible reports whether the scheduler API version is supported by the
+// client. Warnings list what the client does not use of a newer scheduler.
+func (c *Client) IsCompatible(ctx context.Context) (bool, []string, error) {
+	v, err := c.Version(ctx)
+	if err != nil {
+		return false, nil, err
+	}
+	ok, warnings := compatibility(v.APIVersion)
+	return ok, warnings, nil
+}
+
+func compatibility(apiVersion int) (bool, []string) {
+	switch {
+	case apiVersion < MinSupportedAPIVersion:
+		return false, nil
+	case apiVersion > MaxSupportedAPIVersion:
+		return true, []string{fmt.Sprintf("scheduler API version %d is newer than client API version %d: features of newer API versions are not used", apiVersion, MaxSupportedAPIVersion)}
+	}
+	return true, nil
+}
+
+// logCompatibility logs the compatibility issues with the API version
+// reported at discovery, older schedulers reporting none.
+func logCompatibility(info *model.ServiceInfo) {
+	if info.APIVersion == 0 {
+		return
+	}
+	ok, warnings := compatibility(info.APIVersion)
+	if !ok {
+		log.Printf("[WARN] scheduler API version %d not supported by client (supports %d to %d)", info.APIVersion, MinSupportedAPIVersion, MaxSupportedAPIVersion)
+	}
+	for _, w := range warnings {
+		log.Printf("[WARN] %s", w)
+	}
+}
+
+type PreflightResult struct {
+	OK                                                   bool
+	Reachable, TokenAccepted, VersionOK, RegionSupported bool
+	Issues                                               []string
+}
+
+// PreflightCheck checks in sequence that the scheduler is reachable, accepts
+// the client token, speaks the client API version and supports region.
+// Failed checks are reported in the result; an error is returned only when
+// ctx is done.
+func (c *Client) PreflightCheck(ctx context.Context, region string) (*PreflightResult, error) {
+	res := &PreflightResult{}
+
+	switch err := c.ping(ctx); err {
+	case nil:
+		res.Reachable, res.TokenAccepted = true, true
+	case ErrTokenRejected:
+		res.Reachable = true
+		res.Issues = append(res.Issues, "auth token rejected by scheduler")
+	default:
+		if ctx.Err() != nil {
+			return res, ctx.Err()
+		}
+		res.Issues = append(res.Issues, fmt.Sprintf("scheduler unreachable: %s", err))
+		return res, nil
+	}
+
+	if ok, _, err := c.IsCompatible(ctx); err != nil {
+		res.Issues = append(res.Issues, fmt.Sprintf("cannot get scheduler version: %s", err))
+	} else if !ok {
+		res.Issues = append(res.Issues, fmt.Sprintf("scheduler API version not supported, client supports %d to %d", MinSupportedAPIVersion, MaxSupportedAPIVersion))
+	} else {
+		res.VersionOK = true
+	}
+
+	regions, err := c.listRegions(ctx)
+	if err != nil {
+		res.Issues = append(res.Issues, fmt.Sprintf("cannot list scheduler regions: %s", err))
+	}
+	for _, r := range regions {
+		if r == region {
+			res.RegionSupported = true
+		}
+	}
+	if err == nil && !res.RegionSupported {
+		res.Issues = append(res.Issues, fmt.Sprintf("region '%s' not supported by scheduler", region))
+	}
+
+	if ctx.Err() != nil {
+		return res, ctx.Err()
+	}
+	res.OK = len(res.Issues) == 0
+	return res, nil
+}
@@ -0,0 +1,154 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+// PurgeExpired deletes tasks that reached a terminal state more than
+// retainFor ago and returns how many were deleted.
+//
+// Schedulers without the purge endpoint are handled client side: all pending
+// tasks are listed, then expired ones are deleted one request at a time.
+// This is much slower on large stores, and failed tasks are left untouched
+// as they cannot be deleted through the API.
+func (c *Client) PurgeExpired(ctx context.Context, retainFor time.Duration) (int, error) {
+	addr := c.serviceURL()
+	addr.Path = "tasks/expired"
+	query := addr.Query()
+	query.Set("retain_for", retainFor.String())
+	addr.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodDelete, addr.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if code := resp.StatusCode; code == http.StatusNotFound || code == http.StatusMethodNotAllowed {
+		return c.purgeExpiredPending(ctx, retainFor)
+	}
+	if err = notOKStatus(addr.String(), resp); err != nil {
+		return 0, err
+	}
+	c.cache.invalidateTasks()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read purged count from '%s': %s", addr.String(), err)
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+func (c *Client) purgeExpiredPending(ctx context.Context, retainFor time.Duration) (int, error) {
+	expired, err := c.ListWithOptions(ctx, ListOptions{
+		Status: model.StatusPending,
+		Before: time.Now().Add(stillExecutable - retainFor),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return c.deleteTasks(ctx, expired)
+}
+
+// DeleteOlderThan deletes the tasks of the given terminal statuses that
+// reached them more than age ago and returns how many were deleted. Pending
+// tasks are never deleted.
+//
+// Schedulers without the endpoint are handled client side: failed tasks that
+// ran more than age ago are deleted one request at a time, those the API
+// cannot delete being left untouched. Done tasks are not listed, executed
+// tasks being removed by the scheduler.
+func (c *Client) DeleteOlderThan(ctx context.Context, age time.Duration, statuses []string) (int, error) {
+	if age < 0 {
+		return 0, fmt.Errorf("negative age %s", age)
+	}
+	if len(statuses) == 0 {
+		return 0, errors.New("no terminal status to delete")
+	}
+	var failed bool
+	for _, status := range statuses {
+		switch status {
+		case model.StatusDone:
+		case model.StatusFailed:
+			failed = true
+		default:
+			return 0, fmt.Errorf("invalid terminal status '%s'", status)
+		}
+	}
+
+	addr := c.serviceURL()
+	addr.Path = "tasks"
+	query := addr.Query()
+	query.Set("older_than", age.String())
+	for _, status := range statuses {
+		query.Add("status", status)
+	}
+	addr.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodDelete, addr.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if code := resp.StatusCode; code == http.StatusNotFound || code == http.StatusMethodNotAllowed {
+		if !failed {
+			return 0, nil
+		}
+		return c.deleteFailedBefore(ctx, time.Now().Add(-age))
+	}
+	if err = notOKStatus(addr.String(), resp); err != nil {
+		return 0, err
+	}
+	c.cache.invalidateTasks()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read deleted count from '%s': %s", addr.String(), err)
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+func (c *Client) deleteFailedBefore(ctx context.Context, before time.Time) (int, error) {
+	failed, err := c.ListWithOptions(ctx, ListOptions{Status: model.StatusFailed, Before: before})
+	if err != nil {
+		return 0, err
+	}
+	return c.deleteTasks(ctx, failed)
+}
+
+// deleteTasks deletes the tasks one request at a time, returning how many
+// were deleted. Tasks already gone are skipped.
+func (c *Client) deleteTasks(ctx context.Context, tasks []*model.Task) (int, error) {
+	var count int
+	for _, tk := range tasks {
+		err := c.Delete(ctx, tk.ID)
+		if err != nil && err != ErrNotFound {
+			return count, err
+		}
+		if err == nil {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const defaultQueueBufferSize = 100
+
+var (
+	ErrQueueFull   = errors.New("task queue is full")
+	ErrQueueClosed = errors.New("task queue is closed")
+)
+
+type QueueOptions struct {
+	BufferSize int
+	MaxRPS     float64
+	OnError    func(Form, error)
+}
+
+type TaskQueue struct {
+	client *Client
+	opts   QueueOptions
+	ctx    context.Context
+	forms  chan Form
+
+	mux     sync.Mutex
+	pending int
+	empty   chan struct{}
+	// closed once the queue context is done and the buffer discarded
+	closed bool
+}
+
+func (c *Client) NewQueue(ctx context.Context, opts QueueOptions) *TaskQueue {
+	if opts.BufferSize < 1 {
+		opts.BufferSize = defaultQueueBufferSize
+	}
+	q := &TaskQueue{
+		client: c,
+		opts:   opts,
+		ctx:    ctx,
+		forms:  make(chan Form, opts.BufferSize),
+		empty:  make(chan struct{}),
+	}
+	go q.drain()
+	return q
+}
+
+// Enqueue never blocks: a form that cannot be buffered is reported to OnError.
+func (q *TaskQueue) Enqueue(f Form) {
+	q.mux.Lock()
+	if q.closed || q.ctx.Err() != nil {
+		q.mux.Unlock()
+		q.fail(f, ErrQueueClosed)
+		return
+	}
+	// buffered with q.mux held for discard not to miss it
+	select {
+	case q.forms <- f:
+		q.pending++
+		q.mux.Unlock()
+	default:
+		q.mux.Unlock()
+		q.fail(f, ErrQueueFull)
+	}
+}
+
+func (q *TaskQueue) Flush(ctx context.Context) error {
+	q.mux.Lock()
+	if q.pending == 0 {
+		q.mux.Unlock()
+		return nil
+	}
+	empty := q.empty
+	q.mux.Unlock()
+
+	select {
+	case <-empty:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *TaskQueue) drain() {
+	var limit <-chan time.Time
+	if q.opts.MaxRPS > 0 {
+		tick := time.NewTicker(time.Duration(float64(time.Second) / q.opts.MaxRPS))
+		defer tick.Stop()
+		limit = tick.C
+	}
+
+	for {
+		select {
+		case <-q.ctx.Done():
+			q.discard()
+			return
+		case f := <-q.forms:
+			if limit != nil {
+				select {
+				case <-limit:
+				case <-q.ctx.Done():
+					q.fail(f, q.ctx.Err())
+					q.add(-1)
+					q.discard()
+					return
+				}
+			}
+			if _, err := q.client.postForm(q.ctx, f); err != nil {
+				q.fail(f, err)
+			}
+			q.add(-1)
+		}
+	}
+}
+
+func (q *TaskQueue) discard() {
+	q.mux.Lock()
+	q.closed = true
+	q.mux.Unlock()
+
+	for {
+		select {
+		case f := <-q.forms:
+			q.fail(f, q.ctx.Err())
+			q.add(-1)
+		default:
+			return
+		}
+	}
+}
+
+func (q *TaskQueue) add(delta int) {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	q.pending += delta
+	if q.pending == 0 {
+		close(q.empty)
+		q.empty = make(chan struct{})
+	}
+}
+
+func (q *TaskQueue) fail(f Form, err error) {
+	if q.opts.OnError != nil {
+		q.opts.OnError(f, err)
+	}
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+const defaultReachableTimeout = 500 * time.Millisecond
+
+func WithReachableTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.reachableTimeout = d
+	}
+}
+
+// Reachable only checks the scheduler accepts TCP connections (or, in unix
+// sock mode, that its socket file exists), without any HTTP request.
+func (c *Client) Reachable(ctx context.Context) bool {
+	info := c.ServiceInfo()
+	if info.UnixSockMode {
+		stat, err := os.Stat(info.ServiceAddr)
+		return err == nil && stat.Mode()&os.ModeSocket != 0
+	}
+
+	timeout := c.reachableTimeout
+	if timeout <= 0 {
+		timeout = defaultReachableTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	addr := c.serviceURL()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", hostport(addr.Scheme, addr.Host))
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// ConnectivityError is returned by TestConnectivity when the scheduler does not
+// accept connections.
+type ConnectivityError struct {
+	Addr    string
+	Latency time.Duration
+	Err     error
+}
+
+func (e *ConnectivityError) Error() string {
+	return fmt.Sprintf("cannot connect to scheduler at %s after %s: %s", e.Addr, e.Latency, e.Err)
+}
+
+func (e *ConnectivityError) Unwrap() error {
+	return e.Err
+}
+
+// TestConnectivity is Reachable reporting why the scheduler is not: it opens
+// then closes a TCP connection (or, in unix sock mode, a connection to the
+// socket) without any HTTP request nor credentials.
+func (c *Client) TestConnectivity(ctx context.Context) error {
+	info := c.ServiceInfo()
+	network, addr := "unix", info.ServiceAddr
+	if !info.UnixSockMode {
+		u := c.serviceURL()
+		network, addr = "tcp", hostport(u.Scheme, u.Host)
+	}
+
+	timeout := c.reachableTimeout
+	if timeout <= 0 {
+		timeout = defaultReachableTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	if network == "unix" {
+		if _, err := os.Stat(addr); err != nil {
+			return &ConnectivityError{Addr: addr, Latency: time.Since(start), Err: err}
+		}
+	}
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+	if err != nil {
+		return &ConnectivityError{Addr: addr, Latency: time.Since(start), Err: err}
+	}
+	conn.Close()
+	return nil
+}
+
+func hostport(scheme, host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	if scheme == "https" {
+		return net.JoinHostPort(host, "443")
+	}
+	return net.JoinHostPort(host, "80")
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+const defaultReadinessPollInterval = time.Second
+
+// ReadinessCriteria are the conditions of a scheduler ready to accept tasks.
+// Zero criteria are not checked.
+type ReadinessCriteria struct {
+	MaxQueueDepth int
+	// MinWorkerAvailability is the minimum fraction of idle workers.
+	MinWorkerAvailability float64
+	PollInterval          time.Duration
+}
+
+// WaitUntilReady polls the discovery endpoint until the scheduler meets the
+// criteria. On expiry of ctx, the error names the last unmet criterion.
+func (c *Client) WaitUntilReady(ctx context.Context, criteria ReadinessCriteria) error {
+	interval := criteria.PollInterval
+	if interval <= 0 {
+		interval = defaultReadinessPollInterval
+	}
+
+	var notReady error
+	for {
+		info, err := c.refreshServiceInfo(ctx)
+		if err == nil {
+			if err = criteria.unmet(info); err == nil {
+				return nil
+			}
+		}
+		// a refresh interrupted by ctx tells nothing of the scheduler
+		if ctx.Err() == nil || notReady == nil {
+			notReady = err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("scheduler not ready: %s (%s)", notReady, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (criteria ReadinessCriteria) unmet(info model.ServiceInfo) error {
+	if criteria.MaxQueueDepth > 0 && info.QueueDepth > criteria.MaxQueueDepth {
+		return fmt.Errorf("queue depth %d exceeds max queue depth %d", info.QueueDepth, criteria.MaxQueueDepth)
+	}
+	if criteria.MinWorkerAvailability > 0 {
+		if info.MaxWorkers == 0 {
+			return errors.New("min worker availability unknown, no workers reported")
+		}
+		if idle := float64(info.MaxWorkers-info.WorkerCount) / float64(info.MaxWorkers); idle < criteria.MinWorkerAvailability {
+			return fmt.Errorf("worker availability %.2f below min worker availability %.2f", idle, criteria.MinWorkerAvailability)
+		}
+	}
+	return nil
+}
+
+// refreshServiceInfo updates the service info from the discovery endpoint,
+// keeping the service addr possibly set with SetServiceAddr.
+func (c *Client) refreshServiceInfo(ctx context.Context) (model.ServiceInfo, error) {
+	if c.discoveryURL == "" {
+		return model.ServiceInfo{}, errors.New("cannot refresh service info: client built without discovery")
+	}
+	// discovery is always over TCP, even in unix sock mode
+	v, err := discover(ctx, &http.Client{Timeout: c.httpClient.Timeout}, c.discoveryURL)
+	if err != nil {
+		return model.ServiceInfo{}, err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	v.ServiceAddr = c.serviceInfo.ServiceAddr
+	c.serviceInfo = v
+	return *v, nil
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+type RecurringOptions struct {
+	MaxRetries  int
+	Tags        map[string]string
+	Description string
+}
+
+// PostRecurring posts a task running the template at each time of the cron
+// schedule, in UTC. Its first run is the next time of the schedule.
+func (c *Client) PostRecurring(ctx context.Context, schedule string, region, template string, opts RecurringOptions) (string, error) {
+	cron, err := model.ParseCron(schedule)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	// rounded up for the first run not to precede the schedule
+	runIn := (cron.Next(now).Sub(now) + time.Second - 1).Truncate(time.Second)
+	f := Form{
+		Region:      region,
+		Template:    template,
+		RunIn:       runIn.String(),
+		CronExpr:    schedule,
+		MaxRetries:  opts.MaxRetries,
+		Tags:        opts.Tags,
+		Description: opts.Description,
+	}
+	return c.postContext(ctx, f)
+}
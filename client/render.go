@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var templateHole = regexp.MustCompile(`\{([a-zA-Z_][\w.-]*)\}`)
+
+// Render returns the template with its holes filled by the form vars. Holes
+// without vars are left for the secrets filled at execution, while vars
+// filling no hole are an error.
+func (f Form) Render() (string, error) {
+	if len(f.Vars) == 0 {
+		return f.Template, nil
+	}
+
+	used := make(map[string]bool)
+	rendered := templateHole.ReplaceAllStringFunc(f.Template, func(hole string) string {
+		name := hole[1 : len(hole)-1]
+		if value, ok := f.Vars[name]; ok {
+			used[name] = true
+			return value
+		}
+		return hole
+	})
+
+	var unused []string
+	for name := range f.Vars {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return "", fmt.Errorf("form vars fill no template hole: %s", strings.Join(unused, ", "))
+	}
+	return rendered, nil
+}
+
+// Preview is Render.
+func (f Form) Preview() (string, error) {
+	return f.Render()
+}
+
+// PostPreview returns the template the form would be posted with, form
+// defaults applied, without sending any request.
+func (c *Client) PostPreview(ctx context.Context, f Form) (string, error) {
+	return c.withFormDefaults(f).Render()
+}
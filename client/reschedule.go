@@ -0,0 +1,35 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RescheduleRelative shifts the run and revert times of a task, earlier for
+// negative offsets. The revert shift is ignored for tasks without revert.
+// Zero offsets are a no-op. As for Reschedule, the task gets a new id: see
+// GetByContentHash.
+func (c *Client) RescheduleRelative(ctx context.Context, taskID string, runShift, revertShift time.Duration) error {
+	if runShift == 0 && revertShift == 0 {
+		return nil
+	}
+
+	tk, err := c.Get(ctx, taskID)
+	if err != nil {
+		return err
+	}
+
+	runAt := tk.GetRunAt().Add(runShift)
+	if !runAt.After(time.Now()) {
+		return fmt.Errorf("cannot reschedule task '%s' in the past at %s", taskID, runAt.Format(time.RFC3339))
+	}
+
+	f := FormFromTask(tk)
+	f.RunIn = time.Until(runAt).Round(time.Second).String()
+	if !tk.RevertAt.IsZero() {
+		f.RevertIn = time.Until(tk.GetRevertAt().Add(revertShift)).Round(time.Second).String()
+	}
+	_, err = c.Reschedule(ctx, taskID, f)
+	return err
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ScheduleAt posts the template to run in region at runAt.
+func (c *Client) ScheduleAt(ctx context.Context, runAt time.Time, region, template string) (string, error) {
+	return c.ScheduleAtWithRevert(ctx, runAt, time.Time{}, region, template)
+}
+
+// ScheduleAtWithRevert is ScheduleAt reverting the task at revertAt, if not zero.
+func (c *Client) ScheduleAtWithRevert(ctx context.Context, runAt, revertAt time.Time, region, template string) (string, error) {
+	now := time.Now()
+	if !runAt.After(now) {
+		return "", fmt.Errorf("run time %s is in the past", runAt.Format(time.RFC3339))
+	}
+	f := Form{Region: region, Template: template, RunIn: runAt.Sub(now).Round(time.Second).String()}
+	if !revertAt.IsZero() {
+		if !revertAt.After(runAt) {
+			return "", fmt.Errorf("revert time %s is not after run time %s", revertAt.Format(time.RFC3339), runAt.Format(time.RFC3339))
+		}
+		f.RevertIn = revertAt.Sub(now).Round(time.Second).String()
+	}
+	return c.postContext(ctx, f)
+}
+
+// PostAt is ScheduleAtWithRevert with an optional revert time, nil meaning no
+// revert.
+func (c *Client) PostAt(ctx context.Context, runAt time.Time, revertAt *time.Time, region, template string) (string, error) {
+	var revert time.Time
+	if revertAt != nil {
+		revert = *revertAt
+	}
+	return c.ScheduleAtWithRevert(ctx, runAt, revert, region, template)
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+// WithSecretEncryption encrypts the form secrets with the PEM encoded RSA
+// public key of the scheduler before sending them.
+func WithSecretEncryption(publicKey []byte) ClientOption {
+	return func(c *Client) {
+		c.secretsKey = publicKey
+	}
+}
+
+func (c *Client) encryptSecrets(secrets map[string]string) (map[string]string, string, error) {
+	if len(c.secretsKey) == 0 || len(secrets) == 0 {
+		return secrets, "", nil
+	}
+	key, err := parsePublicKey(c.secretsKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	encrypted := make(map[string]string)
+	for k, v := range secrets {
+		cipher, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, key, []byte(v), nil)
+		if err != nil {
+			return nil, "", fmt.Errorf("cannot encrypt secret '%s': %s", k, err)
+		}
+		encrypted[k] = base64.StdEncoding.EncodeToString(cipher)
+	}
+	return encrypted, model.SecretEncryptionRSAOAEP, nil
+}
+
+func parsePublicKey(b []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM data in secrets public key")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse secrets public key: %s", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("secrets public key is not a RSA key")
+	}
+	return rsaKey, nil
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const selfTestTemplate = "create user name=awless-scheduler-selftest"
+
+type SelfTestResult struct {
+	Name     string
+	Duration time.Duration
+	Passed   bool
+	Err      error
+}
+
+type SelfTestReport struct {
+	Results []SelfTestResult
+}
+
+func (r *SelfTestReport) Passed() bool {
+	for _, res := range r.Results {
+		if !res.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// SelfTest runs harmless requests against the scheduler, reporting which
+// ones work. Nothing is stored: the form is only noop'ed. An error is
+// returned only if the client is unusable or ctx is done.
+func (c *Client) SelfTest(ctx context.Context) (*SelfTestReport, error) {
+	if c == nil || c.httpClient == nil || c.transport == nil {
+		return nil, errors.New("client not initialized, use New")
+	}
+
+	report := &SelfTestReport{}
+	run := func(name string, test func() error) {
+		start := time.Now()
+		err := test()
+		report.Results = append(report.Results, SelfTestResult{Name: name, Duration: time.Since(start), Passed: err == nil, Err: err})
+	}
+
+	region := "us-east-1"
+	run("Ping", func() error { return c.ping(ctx) })
+	run("Version", func() error {
+		_, err := c.Version(ctx)
+		return err
+	})
+	run("ListRegions", func() error {
+		regions, err := c.listRegions(ctx)
+		if len(regions) > 0 {
+			region = regions[0]
+		}
+		return err
+	})
+	run("LintTemplate", func() error {
+		res, err := c.LintTemplate(ctx, selfTestTemplate)
+		if err == nil && !res.Valid {
+			err = errors.New("trivial template reported invalid")
+		}
+		return err
+	})
+	run("Noop", func() error {
+		_, err := c.Noop(ctx, Form{Region: region, Template: selfTestTemplate})
+		return err
+	})
+	run("List", func() error {
+		_, err := c.ListWithOptions(ctx, ListOptions{})
+		return err
+	})
+
+	return report, ctx.Err()
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+const SnapshotVersion = 1
+
+type Snapshot struct {
+	Version   int
+	CreatedAt time.Time
+	Tasks     []*model.Task
+}
+
+type RestoreOptions struct {
+	CancelExisting bool
+}
+
+func (c *Client) Snapshot(ctx context.Context) (*Snapshot, error) {
+	tasks, err := c.ListWithOptions(ctx, ListOptions{Status: model.StatusPending, Ascending: true})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list tasks for snapshot: %s", err)
+	}
+	return &Snapshot{Version: SnapshotVersion, CreatedAt: time.Now().UTC(), Tasks: tasks}, nil
+}
+
+// Restore reposts the snapshot tasks with their original run and revert
+// times. As task ids change on repost, dependencies are remapped to new ids.
+func (c *Client) Restore(ctx context.Context, snap *Snapshot, opts RestoreOptions) error {
+	if snap.Version != SnapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d, expected %d", snap.Version, SnapshotVersion)
+	}
+
+	ordered, err := NewDependencyGraph(snap.Tasks).TopologicalOrder()
+	if err != nil {
+		return fmt.Errorf("cannot restore snapshot: %s", err)
+	}
+
+	if opts.CancelExisting {
+		existing, err := c.ListWithOptions(ctx, ListOptions{Status: model.StatusPending})
+		if err != nil {
+			return fmt.Errorf("cannot list existing tasks: %s", err)
+		}
+		for _, tk := range existing {
+			if err := c.Delete(ctx, tk.ID); err != nil && err != ErrNotFound {
+				return fmt.Errorf("cannot cancel task '%s': %s", tk.ID, err)
+			}
+		}
+	}
+
+	newIDs := make(map[string]string)
+	for _, tk := range ordered {
+		f := FormFromTask(tk)
+		f.DependsOn = nil
+		for _, dep := range tk.DependsOn {
+			if id, ok := newIDs[dep]; ok {
+				dep = id
+			}
+			f.DependsOn = append(f.DependsOn, dep)
+		}
+
+		id, err := c.submit(ctx, http.MethodPost, "tasks", f)
+		if err != nil {
+			return fmt.Errorf("cannot restore task '%s': %s", tk.ID, err)
+		}
+		newIDs[tk.ID] = id
+	}
+
+	return nil
+}
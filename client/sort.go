@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"sort"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+type TaskSortField int
+
+const (
+	SortByRunAt TaskSortField = iota
+	SortByRevertAt
+	SortByRegion
+	SortByStatus
+	SortByID
+)
+
+type SortDirection int
+
+const (
+	SortAscending SortDirection = iota
+	SortDescending
+)
+
+// SortedList is ListWithOptions sorted client side by RunAt ascending, then
+// by ID, whatever the scheduler order. The limit applies to the sorted tasks.
+func (c *Client) SortedList(ctx context.Context, opts ListOptions) ([]*model.Task, error) {
+	limit := opts.Limit
+	opts.Limit = 0
+	tasks, err := c.ListWithOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	SortTasksBy(tasks, SortByRunAt, SortAscending)
+	if limit > 0 && len(tasks) > limit {
+		tasks = tasks[:limit]
+	}
+	return tasks, nil
+}
+
+// SortTasksBy sorts tasks in place by field, ties being broken by ID
+// ascending.
+func SortTasksBy(tasks []*model.Task, field TaskSortField, dir SortDirection) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if cmp := compareTasks(tasks[i], tasks[j], field); cmp != 0 {
+			if dir == SortDescending {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+}
+
+func compareTasks(a, b *model.Task, field TaskSortField) int {
+	switch field {
+	case SortByRunAt:
+		return compareTimes(a.RunAt, b.RunAt)
+	case SortByRevertAt:
+		return compareTimes(a.RevertAt, b.RevertAt)
+	case SortByRegion:
+		return compareStrings(a.Region, b.Region)
+	case SortByStatus:
+		return compareStrings(a.Status, b.Status)
+	case SortByID:
+		return compareStrings(a.ID, b.ID)
+	}
+	return 0
+}
+
+func compareTimes(a, b model.FlexibleTime) int {
+	switch {
+	case a.Time().Before(b.Time()):
+		return -1
+	case a.Time().After(b.Time()):
+		return 1
+	}
+	return 0
+}
+
+func compareStrings(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
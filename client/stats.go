@@ -0,0 +1,163 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+type Stats struct {
+	Pending, Failed, Overdue int
+	// ByRegion counts the pending and failed tasks of each region.
+	ByRegion map[string]int
+	// NextRunAt is the earliest run time of the pending tasks to come.
+	NextRunAt time.Time
+}
+
+func (c *Client) Stats(ctx context.Context) (*Stats, error) {
+	tasks, err := c.ListWithOptions(ctx, ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	stats := &Stats{ByRegion: make(map[string]int)}
+	for _, tk := range tasks {
+		stats.ByRegion[tk.Region]++
+		if tk.Status == model.StatusFailed {
+			stats.Failed++
+			continue
+		}
+		stats.Pending++
+		runAt := tk.RunAt.Time()
+		if runAt.Before(now) {
+			stats.Overdue++
+		} else if stats.NextRunAt.IsZero() || runAt.Before(stats.NextRunAt) {
+			stats.NextRunAt = runAt
+		}
+	}
+	return stats, nil
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// ExportPrometheusTextFormat writes the task stats in the Prometheus text
+// exposition format, e.g. for a node exporter textfile collector.
+func (c *Client) ExportPrometheusTextFormat(ctx context.Context, w io.Writer) error {
+	stats, err := c.Stats(ctx)
+	if err != nil {
+		return err
+	}
+
+	var regions []string
+	for region := range stats.ByRegion {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	lines := []string{
+		"# HELP awless_scheduler_tasks Number of scheduled tasks by status.",
+		"# TYPE awless_scheduler_tasks gauge",
+		fmt.Sprintf(`awless_scheduler_tasks{status="%s"} %d`, model.StatusPending, stats.Pending),
+		fmt.Sprintf(`awless_scheduler_tasks{status="%s"} %d`, model.StatusFailed, stats.Failed),
+		"# HELP awless_scheduler_overdue_tasks Number of pending tasks past their run time.",
+		"# TYPE awless_scheduler_overdue_tasks gauge",
+		fmt.Sprintf("awless_scheduler_overdue_tasks %d", stats.Overdue),
+		"# HELP awless_scheduler_region_tasks Number of pending and failed tasks by region.",
+		"# TYPE awless_scheduler_region_tasks gauge",
+	}
+	for _, region := range regions {
+		lines = append(lines, fmt.Sprintf(`awless_scheduler_region_tasks{region="%s"} %d`, promLabelEscaper.Replace(region), stats.ByRegion[region]))
+	}
+	if !stats.NextRunAt.IsZero() {
+		lines = append(lines,
+			"# HELP awless_scheduler_next_run_timestamp_seconds Run time of the next pending task.",
+			"# TYPE awless_scheduler_next_run_timestamp_seconds gauge",
+			fmt.Sprintf("awless_scheduler_next_run_timestamp_seconds %d", stats.NextRunAt.Unix()),
+		)
+	}
+
+	_, err = io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+type StatsComparison struct {
+	OnlyInSelf, OnlyInOther []*model.Task
+	// ContentMismatches pairs the tasks of self and other scheduled at the
+	// same run and revert times in the same region but with different
+	// contents, ids embedding a content checksum.
+	ContentMismatches [][2]*model.Task
+}
+
+// CompareStats lists the tasks of both schedulers concurrently and compares
+// them by id, then by schedule for the tasks of different ids, e.g. to check
+// a standby scheduler.
+func (c *Client) CompareStats(ctx context.Context, other *Client) (*StatsComparison, error) {
+	var selfTasks, otherTasks []*model.Task
+	var selfErr, otherErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		otherTasks, otherErr = other.ListWithOptions(ctx, ListOptions{})
+	}()
+	selfTasks, selfErr = c.ListWithOptions(ctx, ListOptions{})
+	<-done
+
+	if selfErr != nil {
+		return nil, selfErr
+	}
+	if otherErr != nil {
+		return nil, fmt.Errorf("cannot list other scheduler tasks: %s", otherErr)
+	}
+
+	selfIDs, otherIDs := make(map[string]bool), make(map[string]bool)
+	for _, tk := range selfTasks {
+		selfIDs[tk.ID] = true
+	}
+	bySchedule := make(map[string][]*model.Task)
+	for _, tk := range otherTasks {
+		otherIDs[tk.ID] = true
+		if !selfIDs[tk.ID] {
+			bySchedule[scheduleKey(tk)] = append(bySchedule[scheduleKey(tk)], tk)
+		}
+	}
+
+	comparison := &StatsComparison{}
+	paired := make(map[*model.Task]bool)
+	for _, tk := range selfTasks {
+		if otherIDs[tk.ID] {
+			continue
+		}
+		key := scheduleKey(tk)
+		if candidates := bySchedule[key]; len(candidates) > 0 {
+			bySchedule[key] = candidates[1:]
+			paired[candidates[0]] = true
+			comparison.ContentMismatches = append(comparison.ContentMismatches, [2]*model.Task{tk, candidates[0]})
+			continue
+		}
+		comparison.OnlyInSelf = append(comparison.OnlyInSelf, tk)
+	}
+	for _, tk := range otherTasks {
+		if !selfIDs[tk.ID] && !paired[tk] {
+			comparison.OnlyInOther = append(comparison.OnlyInOther, tk)
+		}
+	}
+	return comparison, nil
+}
+
+// scheduleKey identifies the slot of a task regardless of its content.
+func scheduleKey(tk *model.Task) string {
+	return fmt.Sprintf("%s_%s_%s", tk.RunAt.Time().UTC().Format(model.StampLayout), tk.RevertAt.Time().UTC().Format(model.StampLayout), tk.Region)
+}
+
+func contentHash(tk *model.Task) string {
+	if tk.ContentHash != "" {
+		return tk.ContentHash
+	}
+	return model.ContentHash(tk.Content)
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+// PatchTags adds then removes tags of a pending or failed task at once,
+// keeping its id. Only the keys of remove matter. Keys both added and removed
+// fail with model.ErrConflictingTagOperation.
+func (c *Client) PatchTags(ctx context.Context, taskID string, add, remove map[string]string) error {
+	patch := model.TagPatch{Add: add}
+	for k := range remove {
+		patch.Remove = append(patch.Remove, k)
+	}
+	sort.Strings(patch.Remove)
+	if err := patch.Validate(); err != nil {
+		return err
+	}
+
+	addr := c.serviceURL()
+	addr.Path = "tasks/" + taskID + "/tags"
+
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPatch, addr.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if err = notOKStatus(addr.String(), resp); err != nil {
+		return err
+	}
+	c.cache.invalidateTasks()
+	return nil
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+const (
+	TimelineRun    = "run"
+	TimelineRevert = "revert"
+)
+
+type TimelineEvent struct {
+	At        time.Time
+	EventType string
+	Task      *model.Task
+}
+
+// TaskTimeline lists the run and revert events of the tasks matching opts,
+// sorted by time. Events at the same time keep runs before reverts.
+func (c *Client) TaskTimeline(ctx context.Context, opts ListOptions) ([]TimelineEvent, error) {
+	limit := opts.Limit
+	opts.Limit = 0
+	tasks, err := c.ListWithOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []TimelineEvent
+	for _, tk := range tasks {
+		events = append(events, TimelineEvent{At: tk.RunAt.Time(), EventType: TimelineRun, Task: tk})
+		if !tk.RevertAt.IsZero() {
+			events = append(events, TimelineEvent{At: tk.RevertAt.Time(), EventType: TimelineRevert, Task: tk})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].At.Equal(events[j].At) {
+			return events[i].At.Before(events[j].At)
+		}
+		return events[i].EventType == TimelineRun && events[j].EventType == TimelineRevert
+	})
+
+	if limit > 0 && len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// OverlapsWith reports whether the tasks of both events are applied at the
+// same time, a task being applied from its run time until its revert time.
+// Tasks without revert are applied at their run time only.
+func (e TimelineEvent) OverlapsWith(other TimelineEvent) bool {
+	if e.Task == nil || other.Task == nil {
+		return false
+	}
+	start, end := appliedWindow(e.Task)
+	otherStart, otherEnd := appliedWindow(other.Task)
+	return !start.After(otherEnd) && !otherStart.After(end)
+}
+
+func appliedWindow(tk *model.Task) (time.Time, time.Time) {
+	start := tk.RunAt.Time()
+	if tk.RevertAt.IsZero() {
+		return start, start
+	}
+	return start, tk.RevertAt.Time()
+}
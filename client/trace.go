@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+// Trace asks the scheduler for the hops serving the request, e.g. through a
+// service mesh. The scheduler hop latency is the request round trip when not
+// reported.
+func (c *Client) Trace(ctx context.Context) (*model.TraceResult, error) {
+	addr := c.serviceURL()
+	addr.Path = "trace"
+
+	req, err := http.NewRequest(http.MethodGet, addr.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err = notOKStatus(addr.String(), resp); err != nil {
+		return nil, err
+	}
+
+	res := &model.TraceResult{}
+	if err = json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return nil, fmt.Errorf("cannot decode trace from '%s': %s", addr.String(), err)
+	}
+	if n := len(res.Hops); n > 0 && res.Hops[n-1].Latency == 0 {
+		res.Hops[n-1].Latency = time.Since(start)
+	}
+	return res, nil
+}
@@ -0,0 +1,307 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	retryInitialBackoff    = 100 * time.Millisecond
+	retryMaxBackoff        = 10 * time.Second
+	defaultMaxResponseSize = 10 << 20
+)
+
+func WithRetry(maxRetries int) ClientOption {
+	return func(c *Client) {
+		c.transport.mux.Lock()
+		defer c.transport.mux.Unlock()
+		c.transport.maxRetries = maxRetries
+	}
+}
+
+// WithRetryBudget caps the total time a request sleeps between retries. Once
+// the next backoff would exceed the budget, the request fails with a
+// *RetryBudgetExhaustedError, itself in a *url.Error, wrapping the error of
+// the last attempt.
+func WithRetryBudget(totalBudget time.Duration) ClientOption {
+	return func(c *Client) {
+		c.transport.mux.Lock()
+		defer c.transport.mux.Unlock()
+		c.transport.retryBudget = totalBudget
+	}
+}
+
+type RetryBudgetExhaustedError struct {
+	Budget   time.Duration
+	Attempts int
+	Err      error
+}
+
+func (e *RetryBudgetExhaustedError) Error() string {
+	return fmt.Sprintf("retry budget of %s exhausted after %d attempt(s): %s", e.Budget, e.Attempts, e.Err)
+}
+
+func (e *RetryBudgetExhaustedError) Unwrap() error {
+	return e.Err
+}
+
+func WithMaxResponseSize(bytes int64) ClientOption {
+	return func(c *Client) {
+		c.transport.mux.Lock()
+		defer c.transport.mux.Unlock()
+		c.transport.maxResponseSize = bytes
+	}
+}
+
+type RateLimitInfo struct {
+	At         time.Time
+	RetryAfter time.Duration
+}
+
+func (c *Client) LastRateLimit() *RateLimitInfo {
+	c.transport.mux.RLock()
+	defer c.transport.mux.RUnlock()
+	if c.transport.lastRateLimit == nil {
+		return nil
+	}
+	info := *c.transport.lastRateLimit
+	return &info
+}
+
+type transport struct {
+	next http.RoundTripper
+
+	mux             sync.RWMutex
+	headers         http.Header
+	maxRetries      int
+	retryBudget     time.Duration
+	backoff         *ExponentialBackoffConfig
+	maxResponseSize int64
+	lastRateLimit   *RateLimitInfo
+	token           string
+	observers       map[*observer]struct{}
+	observedBody    int
+	redialBroken    bool
+	socketWatcher   *socketWatcher
+	counters        *requestCounters
+	debug           int32
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	debug := t.debugging()
+	if debug {
+		debugRequest(req)
+	}
+	resp, err := t.retry(req)
+	if debug {
+		resp = debugResponse(req, resp, err, start)
+	}
+	t.counters.countRequest(err == nil && resp.StatusCode < http.StatusBadRequest)
+	if err == nil {
+		if ms, perr := strconv.ParseInt(resp.Header.Get("X-Envoy-Upstream-Service-Time"), 10, 64); perr == nil {
+			t.counters.recordUpstreamServiceTime(time.Duration(ms) * time.Millisecond)
+		}
+	}
+	return t.observe(req, resp, err, start)
+}
+
+func (t *transport) retry(req *http.Request) (*http.Response, error) {
+	t.mux.RLock()
+	next, headers, maxRetries, maxResponseSize, redialBroken := t.next, t.requestHeaders(), t.maxRetries, t.maxResponseSize, t.redialBroken
+	retryBudget, backoffConfig := t.retryBudget, t.backoff
+	socketWatcher := t.socketWatcher
+	t.mux.RUnlock()
+	socketWatcher.check(next)
+
+	var slept time.Duration
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		resp, err := t.roundTrip(next, req, headers, attempt)
+		if err != nil && redialBroken && isBrokenConn(err) && rewindable(req) && idempotent(req) {
+			closeIdleConnections(next)
+			redialBroken = false
+			resp, err = t.roundTrip(next, req, headers, attempt+1)
+		}
+
+		var wait time.Duration
+		if err == nil {
+			if !isRetryableStatus(resp.StatusCode) {
+				resp.Body = limitBody(resp.Body, maxResponseSize)
+				return resp, nil
+			}
+			if resp.StatusCode == http.StatusTooManyRequests {
+				wait = retryAfter(resp.Header.Get("Retry-After"), time.Now())
+				t.recordRateLimit(wait)
+			}
+		}
+
+		if attempt >= maxRetries || !retryable(req, resp, err) {
+			if resp != nil {
+				resp.Body = limitBody(resp.Body, maxResponseSize)
+			}
+			return resp, err
+		}
+		if wait == 0 {
+			wait = backoffConfig.interval(attempt)
+		}
+		if backoffConfig.elapsed(start, wait) {
+			if resp != nil {
+				resp.Body = limitBody(resp.Body, maxResponseSize)
+			}
+			return resp, err
+		}
+		if retryBudget > 0 && slept+wait > retryBudget {
+			if resp != nil {
+				resp.Body = limitBody(resp.Body, maxResponseSize)
+				err = notOKStatus(req.URL.String(), resp)
+				resp.Body.Close()
+			}
+			return nil, &RetryBudgetExhaustedError{Budget: retryBudget, Attempts: attempt + 1, Err: err}
+		}
+		slept += wait
+		if resp != nil {
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// requestHeaders are the headers added to requests, t.mux being held.
+func (t *transport) requestHeaders() http.Header {
+	if t.token == "" {
+		return t.headers
+	}
+	headers := copyHeader(t.headers)
+	headers.Set("Authorization", "Bearer "+t.token)
+	return headers
+}
+
+func (t *transport) prepare(req *http.Request) *http.Request {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	return addHeaders(req, t.requestHeaders())
+}
+
+// addHeaders copies req with the headers it does not already have.
+func addHeaders(req *http.Request, headers http.Header) *http.Request {
+	r := new(http.Request)
+	*r = *req
+	r.Header = copyHeader(req.Header)
+	for k, v := range headers {
+		if _, ok := r.Header[k]; !ok {
+			r.Header[k] = append([]string(nil), v...)
+		}
+	}
+	return r
+}
+
+func (t *transport) roundTrip(next http.RoundTripper, req *http.Request, headers http.Header, attempt int) (*http.Response, error) {
+	withHeaders := addHeaders(req, headers)
+	if attempt > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		withHeaders.Body = body
+	}
+
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return next.RoundTrip(withHeaders)
+}
+
+func (t *transport) recordRateLimit(retryAfter time.Duration) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.lastRateLimit = &RateLimitInfo{At: time.Now(), RetryAfter: retryAfter}
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	limit, remaining int64
+}
+
+func limitBody(body io.ReadCloser, limit int64) io.ReadCloser {
+	if limit <= 0 {
+		limit = defaultMaxResponseSize
+	}
+	return &limitedBody{ReadCloser: body, limit: limit, remaining: limit}
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		var extra [1]byte
+		n, err := b.ReadCloser.Read(extra[:])
+		if n > 0 {
+			return 0, &ErrResponseTooLarge{Limit: b.limit}
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func rewindable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// retryable tells whether the request may be sent again. Requests other than
+// idempotent ones are only sent again when the scheduler could not have
+// processed them: rate limited or never sent.
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if !rewindable(req) {
+		return false
+	}
+	if err != nil {
+		return idempotent(req) || notSent(err)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || idempotent(req)
+}
+
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// notSent tells whether the request failed while connecting to the scheduler.
+func notSent(err error) bool {
+	opErr, ok := err.(*net.OpError)
+	return ok && opErr.Op == "dial"
+}
+
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func backoff(attempt int) time.Duration {
+	wait := retryInitialBackoff << uint(attempt)
+	if wait <= 0 || wait > retryMaxBackoff {
+		return retryMaxBackoff
+	}
+	return wait
+}
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"errors"
+	"strings"
+)
+
+type ValidationMode int
+
+const (
+	// StrictValidation fails on the first error, as Form.Validate does.
+	StrictValidation ValidationMode = iota
+	// PermissiveValidation collects all the issues of the form.
+	PermissiveValidation
+)
+
+type ValidationSeverity string
+
+const (
+	SeverityInfo    ValidationSeverity = "info"
+	SeverityWarning ValidationSeverity = "warning"
+	SeverityError   ValidationSeverity = "error"
+)
+
+type ValidationIssue struct {
+	Field, Message string
+	Severity       ValidationSeverity
+}
+
+// ValidateWithMode returns the issues of the form, failing only on issues of
+// error severity. Strict mode returns the first error issue alone.
+func (f Form) ValidateWithMode(mode ValidationMode) ([]ValidationIssue, error) {
+	issues := f.validationIssues()
+
+	var msgs []string
+	for _, issue := range issues {
+		if issue.Severity != SeverityError {
+			continue
+		}
+		if mode == StrictValidation {
+			return []ValidationIssue{issue}, errors.New(issue.Message)
+		}
+		msgs = append(msgs, issue.Message)
+	}
+	if mode == StrictValidation {
+		return nil, nil
+	}
+	if len(msgs) > 0 {
+		return issues, errors.New(strings.Join(msgs, ", "))
+	}
+	return issues, nil
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+const defaultWaitConcurrency = 10
+
+func WithWaitConcurrency(n int) ClientOption {
+	return func(c *Client) {
+		c.waitConcurrency = n
+	}
+}
+
+// WaitForAll polls the given tasks until each one is failed or done. Executed
+// tasks being removed from the scheduler, a task no longer found is done.
+func (c *Client) WaitForAll(ctx context.Context, taskIDs []string, pollInterval time.Duration) (map[string]*model.Task, error) {
+	concurrency := c.waitConcurrency
+	if concurrency < 1 {
+		concurrency = defaultWaitConcurrency
+	}
+
+	results := make(map[string]*model.Task)
+	for {
+		var mux sync.Mutex
+		var wg sync.WaitGroup
+		var pollErr error
+		sem := make(chan struct{}, concurrency)
+
+		for _, id := range taskIDs {
+			if tk, ok := results[id]; ok && isTerminal(tk) {
+				continue
+			}
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(id string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				tk, err := c.Get(ctx, id)
+				mux.Lock()
+				defer mux.Unlock()
+				switch {
+				case err == ErrNotFound:
+					done := &model.Task{ID: id}
+					if last, ok := results[id]; ok {
+						copied := *last
+						done = &copied
+					}
+					done.Status = model.StatusDone
+					results[id] = done
+				case err != nil:
+					if pollErr == nil {
+						pollErr = err
+					}
+				default:
+					results[id] = tk
+				}
+			}(id)
+		}
+		wg.Wait()
+
+		if pollErr != nil {
+			return results, pollErr
+		}
+		if allTerminal(taskIDs, results) {
+			return results, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return results, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func allTerminal(taskIDs []string, results map[string]*model.Task) bool {
+	for _, id := range taskIDs {
+		if tk, ok := results[id]; !ok || !isTerminal(tk) {
+			return false
+		}
+	}
+	return true
+}
+
+func isTerminal(tk *model.Task) bool {
+	return tk.Status == model.StatusDone || tk.Status == model.StatusFailed
+}
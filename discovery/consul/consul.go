@@ -0,0 +1,229 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/wallix/awless-scheduler/client"
+	"github.com/wallix/awless-scheduler/model"
+)
+
+const (
+	blockingWait = 5 * time.Minute
+	retryWait    = 5 * time.Second
+)
+
+var ErrNoHealthyInstance = errors.New("no healthy scheduler instance registered in consul")
+
+// NewFromConsul builds a client pointing to a healthy instance of serviceName
+// and keeps watching consul to move the client to another instance as soon as
+// the selected one stops being healthy. The watch lasts for the life of the
+// process, see NewFromConsulWithContext to stop it.
+func NewFromConsul(consulAddr, serviceName string, opts ...client.ClientOption) (*client.Client, error) {
+	return NewFromConsulWithContext(context.Background(), consulAddr, serviceName, opts...)
+}
+
+// NewFromConsulWithContext is like NewFromConsul, the watch of consul stopping
+// once ctx is done. The client then stays on the last selected instance.
+func NewFromConsulWithContext(ctx context.Context, consulAddr, serviceName string, opts ...client.ClientOption) (*client.Client, error) {
+	w := &watcher{
+		consulAddr:  consulAddr,
+		serviceName: serviceName,
+		httpClient:  &http.Client{Timeout: blockingWait + 30*time.Second},
+	}
+
+	instances, index, err := w.healthyInstances(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := pick(instances)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := client.NewFromServiceInfo(model.ServiceInfo{ServiceAddr: addr}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	w.client, w.selected = c, addr
+	go w.watch(ctx, index)
+
+	return c, nil
+}
+
+type watcher struct {
+	consulAddr, serviceName string
+	httpClient              *http.Client
+	client                  *client.Client
+	selected                string
+}
+
+type instance struct {
+	addr   string
+	weight int
+}
+
+type healthEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+		Weights struct {
+			Passing, Warning int
+		}
+	}
+	Checks []struct {
+		Status string
+	}
+}
+
+func (w *watcher) watch(ctx context.Context, index uint64) {
+	for {
+		instances, newIndex, err := w.healthyInstances(ctx, index)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("consul: cannot watch service '%s': %s", w.serviceName, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryWait):
+			}
+			continue
+		}
+		if newIndex < index {
+			newIndex = 0
+		}
+		index = newIndex
+
+		if containsAddr(instances, w.selected) {
+			continue
+		}
+
+		addr, err := pick(instances)
+		if err != nil {
+			log.Printf("consul: service '%s': %s", w.serviceName, err)
+			continue
+		}
+		if err = w.client.SetServiceAddr(addr); err != nil {
+			log.Printf("consul: %s", err)
+			continue
+		}
+		log.Printf("consul: scheduler instance %s unhealthy, switched to %s", w.selected, addr)
+		w.selected = addr
+	}
+}
+
+func (w *watcher) healthyInstances(ctx context.Context, index uint64) ([]instance, uint64, error) {
+	u := url.URL{Scheme: "http", Host: w.consulAddr, Path: "/v1/health/service/" + w.serviceName}
+	query := u.Query()
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", blockingWait.String())
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, index, err
+	}
+	resp, err := w.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, index, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, index, fmt.Errorf("got %d status instead of 200 from '%s'", resp.StatusCode, u.String())
+	}
+
+	var entries []healthEntry
+	if err = json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, index, fmt.Errorf("cannot decode consul health entries: %s", err)
+	}
+
+	newIndex, err := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if err != nil {
+		return nil, index, fmt.Errorf("invalid consul index header: %s", err)
+	}
+
+	var instances []instance
+	for _, e := range entries {
+		weight := healthWeight(e)
+		if weight <= 0 {
+			continue
+		}
+		host := e.Service.Address
+		if host == "" {
+			host = e.Node.Address
+		}
+		instances = append(instances, instance{
+			addr:   "http://" + net.JoinHostPort(host, strconv.Itoa(e.Service.Port)),
+			weight: weight,
+		})
+	}
+
+	return instances, newIndex, nil
+}
+
+func healthWeight(e healthEntry) int {
+	passing, warning := e.Service.Weights.Passing, e.Service.Weights.Warning
+	if passing == 0 {
+		passing = 1
+	}
+	if warning == 0 {
+		warning = 1
+	}
+
+	weight := passing
+	for _, check := range e.Checks {
+		switch check.Status {
+		case "critical":
+			return 0
+		case "warning":
+			weight = warning
+		}
+	}
+	return weight
+}
+
+func pick(instances []instance) (string, error) {
+	total := 0
+	for _, i := range instances {
+		total += i.weight
+	}
+	if total == 0 {
+		return "", ErrNoHealthyInstance
+	}
+
+	n := rand.Intn(total)
+	for _, i := range instances {
+		if n < i.weight {
+			return i.addr, nil
+		}
+		n -= i.weight
+	}
+	return instances[len(instances)-1].addr, nil
+}
+
+func containsAddr(instances []instance, addr string) bool {
+	for _, i := range instances {
+		if i.addr == addr {
+			return true
+		}
+	}
+	return false
+}
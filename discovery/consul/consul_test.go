@@ -0,0 +1,90 @@
+package consul
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewFromConsul(t *testing.T) {
+	done := make(chan struct{})
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Path, "/v1/health/service/scheduler"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+		switch r.FormValue("index") {
+		case "":
+			w.Header().Set("X-Consul-Index", "10")
+			w.Write([]byte(`[
+  {"Service": {"Address": "10.0.0.1", "Port": 8083}, "Checks": [{"Status": "passing"}]},
+  {"Service": {"Address": "10.0.0.2", "Port": 8083}, "Checks": [{"Status": "critical"}]}
+]`))
+		case "10":
+			w.Header().Set("X-Consul-Index", "11")
+			w.Write([]byte(`[
+  {"Node": {"Address": "10.0.0.3"}, "Service": {"Port": 8083}, "Checks": [{"Status": "passing"}]}
+]`))
+		default:
+			<-done
+		}
+	}))
+	defer consul.Close()
+	defer close(done)
+
+	cli, err := NewFromConsul(strings.TrimPrefix(consul.URL, "http://"), "scheduler")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for cli.ServiceInfo().ServiceAddr != "http://10.0.0.3:8083" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, want := cli.ServiceInfo().ServiceAddr, "http://10.0.0.3:8083"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestNewFromConsulWithContextStopsWatching(t *testing.T) {
+	started, stopped := make(chan struct{}), make(chan struct{})
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("index") == "" {
+			w.Header().Set("X-Consul-Index", "10")
+			w.Write([]byte(`[{"Service": {"Address": "10.0.0.1", "Port": 8083}}]`))
+			return
+		}
+		close(started)
+		<-r.Context().Done()
+		close(stopped)
+	}))
+	defer consul.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := NewFromConsulWithContext(ctx, strings.TrimPrefix(consul.URL, "http://"), "scheduler"); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected blocking query to be canceled")
+	}
+}
+
+func TestPickSkipsUnhealthy(t *testing.T) {
+	if _, err := pick(nil); err != ErrNoHealthyInstance {
+		t.Fatalf("got %v, want %v", err, ErrNoHealthyInstance)
+	}
+
+	e := healthEntry{}
+	e.Checks = append(e.Checks, struct{ Status string }{"warning"})
+	e.Service.Weights.Warning = 3
+	if got, want := healthWeight(e), 3; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+// deadLetters notifies the streaming clients of failed tasks. The dead letter
+// queue is the failures of the store.
+var deadLetters = &dlq{subscribers: make(map[chan *model.Task]struct{})}
+
+const dlqSubscriberBuffer = 16
+
+type dlq struct {
+	mux         sync.Mutex
+	subscribers map[chan *model.Task]struct{}
+}
+
+func (q *dlq) subscribe() chan *model.Task {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	c := make(chan *model.Task, dlqSubscriberBuffer)
+	q.subscribers[c] = struct{}{}
+	return c
+}
+
+func (q *dlq) unsubscribe(c chan *model.Task) {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	delete(q.subscribers, c)
+}
+
+// publish never blocks: slow subscribers get the task again when reconnecting.
+func (q *dlq) publish(tk *model.Task) {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	for c := range q.subscribers {
+		select {
+		case c <- tk:
+		default:
+		}
+	}
+}
+
+func deadLetterQueue(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/dlq/")
+	switch {
+	case id == "stream" && r.Method == http.MethodGet:
+		streamDeadLetters(w, r)
+	case id != "" && r.Method == http.MethodDelete:
+		err := taskStore.RemoveFailure(id)
+		if os.IsNotExist(err) {
+			jsonError(w, "TASK_NOT_FOUND", fmt.Sprintf("failed task '%s' not found", id), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+// streamDeadLetters sends as server-sent events the failed tasks, then the
+// tasks failing until the client disconnects.
+func streamDeadLetters(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	failed := deadLetters.subscribe()
+	defer deadLetters.unsubscribe(failed)
+
+	tasks, err := taskStore.GetFailures()
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	for _, tk := range tasks {
+		if err = writeDeadLetter(w, tk); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case tk := <-failed:
+			if err = writeDeadLetter(w, tk); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeDeadLetter(w http.ResponseWriter, tk *model.Task) error {
+	b, err := json.Marshal(tk)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: task\ndata: %s\n\n", b)
+	return err
+}
@@ -2,17 +2,28 @@ package main
 
 import (
 	"fmt"
-
 	"log"
+	"sync"
+	"time"
 
 	"github.com/wallix/awless-scheduler/model"
 	"github.com/wallix/awless/template"
 )
 
+const (
+	historyOutputSnippetSize = 500
+	maxHistoryEntries        = 100
+	maxHistoryOutputSize     = 64 << 10
+	maxHistoryOutputs        = 100
+)
+
+var taskHistory = &history{entries: make(map[string][]*model.HistoryEntry), outputs: make(map[string]string), reverted: make(map[string]string)}
+
 type event struct {
-	tk  *model.Task
-	tpl *template.Template
-	err error
+	tk         *model.Task
+	tpl        *template.Template
+	err        error
+	start, end time.Time
 }
 
 func (evt *event) String() string {
@@ -24,6 +35,155 @@ func (evt *event) String() string {
 
 func collectEvents() {
 	for evt := range eventc {
+		taskHistory.record(evt)
+		taskLocks.release(evt.tk.ID)
 		log.Println(evt)
 	}
 }
+
+type history struct {
+	mux     sync.Mutex
+	entries map[string][]*model.HistoryEntry
+	outputs map[string]string
+	// ids of the outputs kept, oldest first
+	outputIDs []string
+	// revert task ids to the ids of the tasks they revert
+	reverted map[string]string
+}
+
+func (h *history) record(evt *event) {
+	entry := &model.HistoryEntry{
+		RunID:      fmt.Sprintf("%s-%d", evt.tk.ID, evt.start.UnixNano()),
+		StartedAt:  evt.start,
+		FinishedAt: evt.end,
+		Status:     model.StatusDone,
+	}
+	output := fmt.Sprint(evt.tpl)
+	if evt.err != nil {
+		entry.ExitCode = 1
+		entry.Status = model.StatusFailed
+		output = evt.err.Error()
+	}
+	snippet := output
+	if len(snippet) > historyOutputSnippetSize {
+		snippet = snippet[:historyOutputSnippetSize]
+	}
+	entry.OutputSnippet = snippet
+
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	h.addOutput(evt.tk.ID, output)
+
+	h.add(evt.tk.ID, entry)
+
+	if revertedID, ok := h.reverted[evt.tk.ID]; ok {
+		delete(h.reverted, evt.tk.ID)
+		if evt.err == nil {
+			revertEntry := *entry
+			revertEntry.Status, revertEntry.RevertTaskID = model.StatusReverted, evt.tk.ID
+			h.add(revertedID, &revertEntry)
+		}
+	}
+}
+
+// recordRevertTask links a revert task to the task it reverts, its run
+// being then recorded in the history of the reverted task.
+func (h *history) recordRevertTask(revertID, revertedID string) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	h.reverted[revertID] = revertedID
+}
+
+// forgetRevertTask unlinks a revert task removed without being run.
+func (h *history) forgetRevertTask(revertID string) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	delete(h.reverted, revertID)
+}
+
+// moveRevertTask links the new id of a replaced revert task instead.
+func (h *history) moveRevertTask(revertID, newID string) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	if revertedID, ok := h.reverted[revertID]; ok {
+		delete(h.reverted, revertID)
+		h.reverted[newID] = revertedID
+	}
+}
+
+// add must be called with h.mux held.
+func (h *history) add(id string, entry *model.HistoryEntry) {
+	entries := append([]*model.HistoryEntry{entry}, h.entries[id]...)
+	if len(entries) > maxHistoryEntries {
+		entries = entries[:maxHistoryEntries]
+	}
+	h.entries[id] = entries
+}
+
+// addOutput keeps the last maxHistoryOutputs outputs, truncated to
+// maxHistoryOutputSize. It must be called with h.mux held.
+func (h *history) addOutput(id, output string) {
+	if len(output) > maxHistoryOutputSize {
+		output = output[:maxHistoryOutputSize]
+	}
+	if _, ok := h.outputs[id]; !ok {
+		h.outputIDs = append(h.outputIDs, id)
+	}
+	h.outputs[id] = output
+	for len(h.outputIDs) > maxHistoryOutputs {
+		delete(h.outputs, h.outputIDs[0])
+		h.outputIDs = h.outputIDs[1:]
+	}
+}
+
+func (h *history) recordBackfill(id, backfilledID string, at time.Time) {
+	entry := &model.HistoryEntry{
+		RunID:         fmt.Sprintf("%s-backfill-%d", id, at.UnixNano()),
+		StartedAt:     at,
+		FinishedAt:    at,
+		Status:        model.StatusBackfilled,
+		OutputSnippet: fmt.Sprintf("manually triggered, backfilling task '%s'", backfilledID),
+	}
+
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	h.add(id, entry)
+}
+
+func (h *history) get(id string, limit int) []*model.HistoryEntry {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	entries := h.entries[id]
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return append([]*model.HistoryEntry{}, entries...)
+}
+
+// done returns the ids of the tasks whose last run succeeded.
+func (h *history) done() []string {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	var ids []string
+	for id, entries := range h.entries {
+		if len(entries) > 0 && entries[0].Status == model.StatusDone {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (h *history) output(id string) (string, bool) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	output, ok := h.outputs[id]
+	return output, ok
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestHistoryOutputsAreBounded(t *testing.T) {
+	h := &history{outputs: make(map[string]string)}
+	h.addOutput("big", strings.Repeat("x", maxHistoryOutputSize+1))
+	if out, _ := h.output("big"); len(out) != maxHistoryOutputSize {
+		t.Fatalf("got output of %d bytes, want %d", len(out), maxHistoryOutputSize)
+	}
+
+	for i := 0; i < maxHistoryOutputs; i++ {
+		h.addOutput(fmt.Sprint(i), "out")
+	}
+	h.addOutput("0", "rerun")
+	if got, want := len(h.outputs), maxHistoryOutputs; got != want {
+		t.Fatalf("got %d outputs, want %d", got, want)
+	}
+	if _, ok := h.output("big"); ok {
+		t.Fatal("oldest output was not evicted")
+	}
+	if out, _ := h.output("0"); out != "rerun" {
+		t.Fatalf("got %q, want %q", out, "rerun")
+	}
+}
+
+func TestHistoryRevertTasksAreUnlinked(t *testing.T) {
+	h := &history{reverted: make(map[string]string)}
+	h.recordRevertTask("revert", "task")
+	h.moveRevertTask("revert", "rescheduled")
+	if got, want := h.reverted["rescheduled"], "task"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	h.forgetRevertTask("rescheduled")
+	if len(h.reverted) != 0 {
+		t.Fatalf("got %v, want no revert task", h.reverted)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+var taskLocks = &locks{held: make(map[string]*taskLock)}
+
+type taskLock struct {
+	token   string
+	expires time.Time
+}
+
+type locks struct {
+	mux  sync.Mutex
+	held map[string]*taskLock
+}
+
+func (l *locks) acquire(id string, ttl time.Duration) (string, bool) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if lock, ok := l.held[id]; ok && time.Now().Before(lock.expires) {
+		return "", false
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Println(err)
+		return "", false
+	}
+	token := hex.EncodeToString(b)
+	l.held[id] = &taskLock{token: token, expires: time.Now().Add(ttl)}
+	return token, true
+}
+
+// check returns the error code preventing to modify the task with the given
+// lock token, or an empty code.
+func (l *locks) check(id, token string) string {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	lock, ok := l.held[id]
+	if ok && !time.Now().Before(lock.expires) {
+		delete(l.held, id)
+		ok = false
+	}
+	switch {
+	case ok && lock.token != token:
+		return "TASK_LOCKED"
+	case !ok && token != "":
+		return "LOCK_EXPIRED"
+	}
+	return ""
+}
+
+func (l *locks) move(from, to string) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if lock, ok := l.held[from]; ok {
+		delete(l.held, from)
+		l.held[to] = lock
+	}
+}
+
+func (l *locks) release(id string) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	delete(l.held, id)
+}
+
+func lockTask(w http.ResponseWriter, r *http.Request, id string) {
+	ttl, err := time.ParseDuration(r.FormValue("ttl"))
+	if err != nil || ttl <= 0 {
+		http.Error(w, "invalid duration for 'ttl' param", http.StatusBadRequest)
+		return
+	}
+	if _, err = taskStore.GetTask(id); os.IsNotExist(err) {
+		jsonError(w, "TASK_NOT_FOUND", fmt.Sprintf("task '%s' not found", id), http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	token, ok := taskLocks.acquire(id, ttl)
+	if !ok {
+		jsonError(w, "TASK_LOCKED", fmt.Sprintf("task '%s' is locked", id), http.StatusConflict)
+		return
+	}
+	w.Write([]byte(token))
+}
+
+func unlockTask(w http.ResponseWriter, r *http.Request, id string) {
+	token := r.FormValue("lock_token")
+	if token == "" {
+		http.Error(w, "missing 'lock_token' param", http.StatusBadRequest)
+		return
+	}
+	if !checkLock(w, r, id) {
+		return
+	}
+	taskLocks.release(id)
+}
+
+// checkLock replies with a conflict when the task is locked by another
+// token than the request 'lock_token' param.
+func checkLock(w http.ResponseWriter, r *http.Request, id string) bool {
+	switch taskLocks.check(id, r.FormValue("lock_token")) {
+	case "TASK_LOCKED":
+		jsonError(w, "TASK_LOCKED", fmt.Sprintf("task '%s' is locked", id), http.StatusConflict)
+		return false
+	case "LOCK_EXPIRED":
+		jsonError(w, "LOCK_EXPIRED", fmt.Sprintf("lock on task '%s' expired", id), http.StatusConflict)
+		return false
+	}
+	return true
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
+	"mime"
 	"net"
 	"net/http"
 	"net/url"
@@ -14,9 +16,13 @@ import (
 	"os/signal"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/wallix/awless-scheduler/model"
 	"github.com/wallix/awless/aws/driver"
 	"github.com/wallix/awless/aws/services"
@@ -30,6 +36,14 @@ var (
 	httpMode          = flag.Bool("http-mode", false, "Scheduler service on HTTP")
 	tickerFrequency   = flag.Duration("tick-frequency", 1*time.Minute, "ticker frequency to run executable tasks")
 	debug             = flag.Bool("debug", false, "print debug messages")
+	queueCapacity     = flag.Int("queue-capacity", 100, "number of executable tasks queued at which the scheduler load is 100%")
+	secretsKeyFile    = flag.String("secrets-key", "", "PEM RSA private key decrypting the task secrets encrypted by clients")
+)
+
+const (
+	templateContentType = "application/text"
+	schedulerVersion    = "0.1"
+	apiVersion          = 1
 )
 
 var (
@@ -48,10 +62,16 @@ func main() {
 	flag.Parse()
 
 	var err error
-	taskStore, err = NewFSStore(schedulerDir)
+	if *secretsKeyFile != "" {
+		if err = loadSecretsKey(*secretsKeyFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	fileStore, err := NewFSStore(schedulerDir)
 	if err != nil {
 		log.Fatal(err)
 	}
+	taskStore = &journaledStore{store: fileStore}
 	log.Printf("Scheduler home dir: %s", schedulerDir)
 
 	log.Printf("Starting event collector")
@@ -148,11 +168,17 @@ func (s *Service) startDiscoveryEnpoint() {
 	started := time.Now()
 
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		queued, running := queueDepth(), int(atomic.LoadInt32(&runningTasks))
 		v := model.ServiceInfo{
 			TickerFrequency: (*tickerFrequency).String(),
 			Uptime:          time.Since(started).String(),
 			ServiceAddr:     s.addr(),
 			UnixSockMode:    !s.httpMode,
+			APIVersion:      apiVersion,
+			QueueDepth:      queued,
+			WorkerCount:     running,
+			MaxWorkers:      maxWorkers,
+			LoadPercent:     loadPercent(queued, *queueCapacity),
 		}
 		b, err := json.MarshalIndent(v, "", " ")
 		if err != nil {
@@ -166,6 +192,15 @@ func (s *Service) startDiscoveryEnpoint() {
 	log.Fatal(http.ListenAndServe(s.discoveryHostport, nil))
 }
 
+// loadPercent is the queue depth against its capacity, the running task
+// being queued until done.
+func loadPercent(queued, capacity int) float64 {
+	if capacity <= 0 {
+		return 100
+	}
+	return math.Min(100, 100*float64(queued)/float64(capacity))
+}
+
 func routes() http.Handler {
 	mux := http.NewServeMux()
 
@@ -173,7 +208,16 @@ func routes() http.Handler {
 		w.Write([]byte("scheduler up!"))
 	})
 	mux.HandleFunc("/tasks", tasks)
+	mux.HandleFunc("/tasks/", task)
 	mux.HandleFunc("/failures", listFailures)
+	mux.HandleFunc("/done", listDone)
+	mux.HandleFunc("/regions", listRegions)
+	mux.HandleFunc("/noop", noop)
+	mux.HandleFunc("/version", version)
+	mux.HandleFunc("/trace", trace)
+	mux.HandleFunc("/lint", lint)
+	mux.HandleFunc("/dlq/", deadLetterQueue)
+	mux.HandleFunc("/changes", listChanges)
 
 	return mux
 }
@@ -185,6 +229,71 @@ func tasks(w http.ResponseWriter, r *http.Request) {
 	} else if r.Method == http.MethodGet {
 		listTasks(w, r)
 		return
+	} else if r.Method == http.MethodDelete {
+		deleteOlderThan(w, r)
+		return
+	}
+	http.Error(w, "invalid method", http.StatusMethodNotAllowed)
+	return
+}
+
+func task(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/tasks/")
+	if strings.HasSuffix(id, "/history") && r.Method == http.MethodGet {
+		getTaskHistory(w, r, strings.TrimSuffix(id, "/history"))
+		return
+	}
+	if strings.HasSuffix(id, "/tags") && r.Method == http.MethodPatch {
+		patchTaskTags(w, r, strings.TrimSuffix(id, "/tags"))
+		return
+	}
+	if strings.HasSuffix(id, "/status") && r.Method == http.MethodGet {
+		getTaskStatus(w, strings.TrimSuffix(id, "/status"))
+		return
+	}
+	if strings.HasSuffix(id, "/output") && r.Method == http.MethodGet {
+		getTaskOutput(w, strings.TrimSuffix(id, "/output"))
+		return
+	}
+	if strings.HasSuffix(id, "/lock") {
+		switch r.Method {
+		case http.MethodPost:
+			lockTask(w, r, strings.TrimSuffix(id, "/lock"))
+		case http.MethodDelete:
+			unlockTask(w, r, strings.TrimSuffix(id, "/lock"))
+		default:
+			http.Error(w, "invalid method", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	if strings.HasSuffix(id, "/backfill") && r.Method == http.MethodPut {
+		backfillTask(w, r, strings.TrimSuffix(id, "/backfill"))
+		return
+	}
+	if id == "expired" && r.Method == http.MethodDelete {
+		purgeExpired(w, r)
+		return
+	}
+	if id == "describe" && r.Method == http.MethodPost {
+		describeForm(w, r)
+		return
+	}
+	if id == "pair" && r.Method == http.MethodPost {
+		createTaskPair(w, r)
+		return
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		getTask(w, r, id)
+		return
+	} else if r.Method == http.MethodPut {
+		rescheduleTask(w, r, id)
+		return
+	} else if r.Method == http.MethodPatch {
+		updateTaskContent(w, r, id)
+		return
+	} else if r.Method == http.MethodDelete {
+		deleteTask(w, r, id)
+		return
 	}
 	http.Error(w, "invalid method", http.StatusMethodNotAllowed)
 	return
@@ -192,7 +301,14 @@ func tasks(w http.ResponseWriter, r *http.Request) {
 
 func listTasks(w http.ResponseWriter, r *http.Request) {
 	tasks, err := taskStore.GetTasks()
-	b, err := marshalTasks(tasks)
+	if running, _ := runningID.Load().(string); running != "" {
+		for _, tk := range tasks {
+			if tk.ID == running {
+				tk.Status = model.StatusRunning
+			}
+		}
+	}
+	b, err := marshalTasks(filterTasks(tasks, r))
 	if err != nil {
 		log.Println(err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -204,7 +320,122 @@ func listTasks(w http.ResponseWriter, r *http.Request) {
 
 func listFailures(w http.ResponseWriter, r *http.Request) {
 	tasks, err := taskStore.GetFailures()
-	b, err := marshalTasks(tasks)
+	b, err := marshalTasks(filterTasks(tasks, r))
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+}
+
+// listDone lists the tasks executed successfully since the scheduler start.
+// Their content is gone, only what their ID holds is listed.
+func listDone(w http.ResponseWriter, r *http.Request) {
+	tasks := []*model.Task{}
+	for _, id := range taskHistory.done() {
+		tk, err := taskFromID(id)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		tasks = append(tasks, tk)
+	}
+
+	b, err := marshalTasks(filterTasks(tasks, r))
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+}
+
+// filterTasks keeps the tasks matching the content_type, content_hash, group
+// and owner params. Stored tasks are all awless templates.
+func filterTasks(tasks []*model.Task, r *http.Request) []*model.Task {
+	if contentType := r.FormValue("content_type"); contentType != "" {
+		if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType != templateContentType {
+			return []*model.Task{}
+		}
+	}
+	hash, group, owner := r.FormValue("content_hash"), r.FormValue("group"), r.FormValue("owner")
+	if hash == "" && group == "" && owner == "" {
+		return tasks
+	}
+	filtered := []*model.Task{}
+	for _, tk := range tasks {
+		if (hash == "" || tk.ContentHash == hash) && (group == "" || tk.Group == group) && (owner == "" || tk.Owner == owner) {
+			filtered = append(filtered, tk)
+		}
+	}
+	return filtered
+}
+
+func lint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "invalid method", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "cannot read request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	res := model.LintResult{Errors: []model.LintError{}, Warnings: []model.LintWarning{}, Source: "server"}
+	if tpl, err := template.Parse(string(body)); err != nil {
+		res.Errors = append(res.Errors, model.LintError{Message: fmt.Sprintf("cannot parse template: %s", err)})
+	} else if _, _, err = template.Compile(tpl, awsdriver.DefaultTemplateEnv()); err != nil {
+		res.Errors = append(res.Errors, model.LintError{Message: fmt.Sprintf("cannot compile template: %s", err)})
+	}
+	res.Valid = len(res.Errors) == 0
+
+	b, err := json.MarshalIndent(res, "", " ")
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+}
+
+func version(w http.ResponseWriter, r *http.Request) {
+	b, err := json.MarshalIndent(model.VersionInfo{Version: schedulerVersion, APIVersion: apiVersion}, "", " ")
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+}
+
+// trace reports the scheduler hop. Pod and node names are read from the
+// POD_NAME and NODE_NAME environment variables, as set by the Kubernetes
+// downward API, the pod name defaulting to the hostname.
+func trace(w http.ResponseWriter, r *http.Request) {
+	hop := model.TraceHop{PodName: os.Getenv("POD_NAME"), NodeName: os.Getenv("NODE_NAME"), Timestamp: time.Now().UTC()}
+	if hop.PodName == "" {
+		hop.PodName, _ = os.Hostname()
+	}
+	b, err := json.MarshalIndent(model.TraceResult{Hops: []model.TraceHop{hop}}, "", " ")
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+}
+
+func listRegions(w http.ResponseWriter, r *http.Request) {
+	var regions []string
+	for id := range endpoints.AwsPartition().Regions() {
+		regions = append(regions, id)
+	}
+	sort.Strings(regions)
+
+	b, err := json.MarshalIndent(regions, "", " ")
 	if err != nil {
 		log.Println(err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -214,7 +445,7 @@ func listFailures(w http.ResponseWriter, r *http.Request) {
 }
 
 func marshalTasks(tasks []*model.Task) ([]byte, error) {
-	sort.Slice(tasks, func(i int, j int) bool { return !tasks[i].RunAt.Before(tasks[j].RunAt) })
+	sort.Slice(tasks, func(i int, j int) bool { return !tasks[i].RunAt.Time().Before(tasks[j].RunAt.Time()) })
 
 	b, err := json.MarshalIndent(tasks, "", " ")
 	if err != nil {
@@ -225,6 +456,455 @@ func marshalTasks(tasks []*model.Task) ([]byte, error) {
 }
 
 func createTask(w http.ResponseWriter, r *http.Request) {
+	tk, ok := readTask(w, r)
+	if !ok {
+		return
+	}
+
+	if err := taskStore.Create(tk); err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write([]byte(tk.AsFilename()))
+}
+
+func noop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "invalid method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tk, ok := readTask(w, r)
+	if !ok {
+		return
+	}
+
+	// secrets are not echoed, only their sorted names
+	secretKeys := make([]string, 0, len(tk.SecretEnv))
+	for k := range tk.SecretEnv {
+		secretKeys = append(secretKeys, k)
+	}
+	sort.Strings(secretKeys)
+
+	b, err := json.MarshalIndent(struct {
+		Region, RunIn, RevertIn string
+		Template                string
+		DependsOn               []string
+		Timezone                string
+		ContentType             string
+		ContentLanguage         string
+		Owner                   string
+		CronExpr                string
+		MaxRetries              int
+		Tags                    map[string]string
+		Description             string
+		SecretKeys              []string
+		AfterSuccess, OnFailure *model.Callback
+	}{
+		Region:          tk.Region,
+		RunIn:           r.FormValue("run"),
+		RevertIn:        r.FormValue("revert"),
+		Template:        tk.Content,
+		DependsOn:       tk.DependsOn,
+		Timezone:        r.FormValue("tz"),
+		ContentType:     templateContentType,
+		ContentLanguage: tk.ContentLanguage,
+		Owner:           tk.Owner,
+		CronExpr:        tk.CronExpr,
+		MaxRetries:      tk.MaxRetries,
+		Tags:            tk.Tags,
+		Description:     tk.Description,
+		SecretKeys:      secretKeys,
+		AfterSuccess:    tk.AfterSuccess,
+		OnFailure:       tk.OnFailure,
+	}, "", " ")
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+}
+
+func describeForm(w http.ResponseWriter, r *http.Request) {
+	tk, ok := readTask(w, r)
+	if !ok {
+		return
+	}
+
+	desc := model.FormDescription{
+		ParsedRunAt:       tk.RunAt.Time(),
+		ParsedRevertAt:    tk.RevertAt.Time(),
+		ParsedRegion:      tk.Region,
+		TemplateLineCount: len(strings.Split(strings.TrimSpace(tk.Content), "\n")),
+		Warnings:          []string{},
+	}
+	if _, ok := endpoints.AwsPartition().Regions()[tk.Region]; !ok {
+		desc.Warnings = append(desc.Warnings, fmt.Sprintf("region '%s' is not a known AWS region", tk.Region))
+	}
+	if tz := r.FormValue("tz"); tz != "" {
+		loc, _ := time.LoadLocation(tz)
+		_, nowOffset := time.Now().In(loc).Zone()
+		_, runOffset := tk.RunAt.Time().In(loc).Zone()
+		if nowOffset != runOffset {
+			desc.Warnings = append(desc.Warnings, fmt.Sprintf("UTC offset of %s changes before run time, durations are not shifted", tz))
+		}
+		desc.Warnings = append(desc.Warnings, fmt.Sprintf("run time is %s in %s", tk.RunAt.Time().In(loc).Format(time.RFC3339), tz))
+	}
+	if r.FormValue("run") == "" {
+		desc.Warnings = append(desc.Warnings, "no run duration, task would run at next tick")
+	}
+
+	b, err := json.MarshalIndent(desc, "", " ")
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+}
+
+func getTask(w http.ResponseWriter, r *http.Request, id string) {
+	tk, err := taskStore.GetTask(id)
+	if os.IsNotExist(err) {
+		jsonError(w, "TASK_NOT_FOUND", fmt.Sprintf("task '%s' not found", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if fields := r.FormValue("fields"); fields != "" {
+		if tk, err = tk.Mask(strings.Split(fields, ",")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	b, err := json.MarshalIndent(tk, "", " ")
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+}
+
+// getTaskStatus writes the status of a pending, running or failed task, or
+// the status of the last run of an executed task.
+func getTaskStatus(w http.ResponseWriter, id string) {
+	var status string
+	if running, _ := runningID.Load().(string); running != "" && running == id {
+		status = model.StatusRunning
+	} else if tk, err := taskStore.GetTask(id); err == nil {
+		status = tk.Status
+	} else if !os.IsNotExist(err) {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if entries := taskHistory.get(id, 1); len(entries) > 0 {
+		status = entries[0].Status
+	} else {
+		jsonError(w, "TASK_NOT_FOUND", fmt.Sprintf("task '%s' not found", id), http.StatusNotFound)
+		return
+	}
+
+	b, _ := json.Marshal(struct {
+		Status string `json:"status"`
+	}{status})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+func getTaskHistory(w http.ResponseWriter, r *http.Request, id string) {
+	var limit int
+	if l := r.FormValue("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit < 0 {
+			http.Error(w, "invalid 'limit' param", http.StatusBadRequest)
+			return
+		}
+	}
+
+	b, err := json.MarshalIndent(taskHistory.get(id, limit), "", " ")
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+}
+
+func getTaskOutput(w http.ResponseWriter, id string) {
+	output, ok := taskHistory.output(id)
+	if !ok {
+		jsonError(w, "TASK_NOT_FOUND", fmt.Sprintf("no output for task '%s'", id), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(output))
+}
+
+func rescheduleTask(w http.ResponseWriter, r *http.Request, id string) {
+	if !checkLock(w, r, id) {
+		return
+	}
+	tk, ok := readTask(w, r)
+	if !ok {
+		return
+	}
+	if previous, err := taskStore.GetTask(id); err == nil {
+		if tk.Group == "" {
+			tk.Group = previous.Group
+		}
+		if tk.ContentLanguage == "" {
+			tk.ContentLanguage = previous.ContentLanguage
+		}
+		if tk.Owner == "" {
+			tk.Owner = previous.Owner
+		}
+		if tk.CronExpr == "" && tk.MaxRetries == 0 {
+			tk.CronExpr, tk.MaxRetries = previous.CronExpr, previous.MaxRetries
+		}
+		if tk.Tags == nil {
+			tk.Tags = previous.Tags
+		}
+		if tk.Description == "" {
+			tk.Description = previous.Description
+		}
+	}
+	replaceTask(w, id, tk)
+}
+
+func updateTaskContent(w http.ResponseWriter, r *http.Request, id string) {
+	if !checkLock(w, r, id) {
+		return
+	}
+	var patch struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || patch.Content == "" {
+		http.Error(w, "invalid content patch", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	tk, err := taskStore.GetTask(id)
+	if os.IsNotExist(err) {
+		jsonError(w, "TASK_NOT_FOUND", fmt.Sprintf("task '%s' not found", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if running, _ := runningID.Load().(string); running != "" && running == id {
+		jsonError(w, "TASK_RUNNING", fmt.Sprintf("task '%s' is running", id), http.StatusConflict)
+		return
+	}
+	if tk.Status != model.StatusPending {
+		jsonError(w, "CONFLICT", fmt.Sprintf("task '%s' is %s", id, tk.Status), http.StatusConflict)
+		return
+	}
+
+	secrets, err := decryptSecrets(tk.SecretEnv, tk.SecretEncryption)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !checkTemplate(w, tk.Region, patch.Content, secrets) {
+		return
+	}
+
+	tk.ID, tk.ContentHash, tk.Status = "", "", ""
+	tk.Content = patch.Content
+	replaceTask(w, id, tk)
+}
+
+// backfillTask reschedules at once an overdue task, shifting its revert time
+// alike, and records the manual trigger in the history of the new task.
+func backfillTask(w http.ResponseWriter, r *http.Request, id string) {
+	if !checkLock(w, r, id) {
+		return
+	}
+	tk, err := taskStore.GetTask(id)
+	if os.IsNotExist(err) {
+		jsonError(w, "TASK_NOT_FOUND", fmt.Sprintf("task '%s' not found", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	now := time.Now().UTC()
+	if tk.Status != model.StatusPending || tk.RunAt.Time().After(now) {
+		jsonError(w, "CONFLICT", fmt.Sprintf("task '%s' is not overdue", id), http.StatusConflict)
+		return
+	}
+
+	shift := now.Sub(tk.RunAt.Time())
+	tk.ID, tk.Status = "", ""
+	tk.RunAt = model.FlexibleTime(now)
+	if !tk.RevertAt.IsZero() {
+		tk.RevertAt = model.FlexibleTime(tk.RevertAt.Time().Add(shift))
+	}
+	taskHistory.recordBackfill(tk.AsFilename(), id, now)
+	replaceTask(w, id, tk)
+}
+
+func replaceTask(w http.ResponseWriter, id string, tk *model.Task) {
+	// the revert task of a pair depends on the run task id
+	if previous, err := taskStore.GetTask(id); err == nil && previous.RevertID != "" {
+		jsonError(w, "CONFLICT", fmt.Sprintf("task '%s' is paired with revert task '%s'", id, previous.RevertID), http.StatusConflict)
+		return
+	}
+	newID := tk.AsFilename()
+	if newID != id {
+		if err := taskStore.Create(tk); err != nil {
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// dependents must not see the task as done once removed
+		if _, err := taskStore.ReplaceDependency(id, newID); err != nil {
+			taskStore.ReplaceDependency(newID, id)
+			taskStore.Remove(newID)
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := taskStore.Remove(id); err != nil {
+			taskStore.ReplaceDependency(newID, id)
+			taskStore.Remove(newID)
+			if os.IsNotExist(err) {
+				jsonError(w, "TASK_NOT_FOUND", fmt.Sprintf("task '%s' not found", id), http.StatusNotFound)
+				return
+			}
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		taskLocks.move(id, newID)
+		taskHistory.moveRevertTask(id, newID)
+	}
+
+	w.Write([]byte(newID))
+}
+
+func patchTaskTags(w http.ResponseWriter, r *http.Request, id string) {
+	if !checkLock(w, r, id) {
+		return
+	}
+	var patch model.TagPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		http.Error(w, "invalid tags patch", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if err := patch.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := taskStore.PatchTags(id, patch)
+	if os.IsNotExist(err) {
+		jsonError(w, "TASK_NOT_FOUND", fmt.Sprintf("task '%s' not found", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func deleteTask(w http.ResponseWriter, r *http.Request, id string) {
+	if !checkLock(w, r, id) {
+		return
+	}
+	tk, _ := taskStore.GetTask(id)
+	err := taskStore.Remove(id)
+	if os.IsNotExist(err) {
+		jsonError(w, "TASK_NOT_FOUND", fmt.Sprintf("task '%s' not found", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	taskLocks.release(id)
+	taskHistory.forgetRevertTask(id)
+	if tk != nil && tk.RevertID != "" {
+		if err = taskStore.Remove(tk.RevertID); err != nil && !os.IsNotExist(err) {
+			log.Printf("cannot cancel revert task %s of task %s: %s", tk.RevertID, id, err)
+		}
+	}
+}
+
+func purgeExpired(w http.ResponseWriter, r *http.Request) {
+	retainFor, err := time.ParseDuration(r.FormValue("retain_for"))
+	if err != nil || retainFor < 0 {
+		http.Error(w, "invalid duration for 'retain_for' param", http.StatusBadRequest)
+		return
+	}
+
+	count, removed, err := taskStore.PurgeExpired(time.Now().UTC().Add(-retainFor))
+	for _, id := range removed {
+		taskHistory.forgetRevertTask(id)
+	}
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write([]byte(strconv.Itoa(count)))
+}
+
+// deleteOlderThan removes the tasks of the terminal status params that
+// reached it more than older_than ago. Done tasks are removed once executed,
+// leaving only failed tasks to delete.
+func deleteOlderThan(w http.ResponseWriter, r *http.Request) {
+	age, err := time.ParseDuration(r.FormValue("older_than"))
+	if err != nil || age < 0 {
+		http.Error(w, "invalid duration for 'older_than' param", http.StatusBadRequest)
+		return
+	}
+	statuses := r.Form["status"]
+	if len(statuses) == 0 {
+		http.Error(w, "missing 'status' param", http.StatusBadRequest)
+		return
+	}
+	var failed bool
+	for _, status := range statuses {
+		switch status {
+		case model.StatusDone:
+		case model.StatusFailed:
+			failed = true
+		default:
+			http.Error(w, fmt.Sprintf("invalid terminal status '%s'", status), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var count int
+	if failed {
+		if count, err = taskStore.PurgeFailures(time.Now().UTC().Add(-age)); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Write([]byte(strconv.Itoa(count)))
+}
+
+func readTask(w http.ResponseWriter, r *http.Request) (*model.Task, bool) {
 	if *debug {
 		log.Println(r.URL.String())
 	}
@@ -232,58 +912,163 @@ func createTask(w http.ResponseWriter, r *http.Request) {
 	if region == "" {
 		log.Println("missing region")
 		http.Error(w, "missing region", http.StatusBadRequest)
-		return
+		return nil, false
 	}
 	runAt, err := getTimeParam(r.FormValue("run"), time.Now().UTC())
 	if err != nil {
 		log.Println(err)
 		http.Error(w, "invalid duration for 'run' param", http.StatusBadRequest)
-		return
+		return nil, false
 	}
 	revertAt, err := getTimeParam(r.FormValue("revert"), time.Time{})
 	if err != nil {
 		log.Println(err)
 		http.Error(w, "invalid duration for 'revert' param", http.StatusBadRequest)
-		return
+		return nil, false
+	}
+	if tz := r.FormValue("tz"); tz != "" {
+		if _, err = time.LoadLocation(tz); err != nil {
+			log.Println(err)
+			http.Error(w, "invalid timezone for 'tz' param", http.StatusBadRequest)
+			return nil, false
+		}
 	}
 	if !revertAt.IsZero() && revertAt.Sub(runAt).Seconds() < minDurationBeforeRevert.Seconds() {
 		err = fmt.Errorf("revert time is less that %s before run time", minDurationBeforeRevert)
 		log.Println(err)
 		http.Error(w, err.Error(), http.StatusNotAcceptable)
-		return
+		return nil, false
 	}
 
-	tplTxt, err := ioutil.ReadAll(r.Body)
+	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		log.Println(err)
 		http.Error(w, "cannot read request body", http.StatusBadRequest)
-		return
+		return nil, false
 	}
 	defer r.Body.Close()
-	tpl, err := template.Parse(string(tplTxt))
+
+	var form struct {
+		Template, ContentType   string
+		SecretEnv               map[string]string
+		SecretEncryption        string
+		AfterSuccess, OnFailure *model.Callback
+	}
+	if r.Header.Get("Content-Type") == model.FormContentType {
+		if err = json.Unmarshal(body, &form); err != nil {
+			log.Println(err)
+			http.Error(w, "invalid json form body", http.StatusBadRequest)
+			return nil, false
+		}
+	} else {
+		form.Template, form.ContentType = string(body), r.Header.Get("Content-Type")
+	}
+	if !isTemplateContentType(form.ContentType) {
+		jsonError(w, "UNSUPPORTED_CONTENT_TYPE", fmt.Sprintf("cannot schedule '%s' content, only awless templates", form.ContentType), http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+	secrets, err := decryptSecrets(form.SecretEnv, form.SecretEncryption)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if !checkTemplate(w, region, form.Template, secrets) {
+		return nil, false
+	}
+	for _, cb := range []*model.Callback{form.AfterSuccess, form.OnFailure} {
+		if cb != nil && !checkCallback(w, region, cb) {
+			return nil, false
+		}
+	}
+
+	tags, err := parseTags(r.Form["tag"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	tk := &model.Task{
+		Content:          form.Template,
+		RunAt:            model.FlexibleTime(runAt),
+		RevertAt:         model.FlexibleTime(revertAt),
+		Region:           region,
+		DependsOn:        r.Form["depends_on"],
+		Group:            r.Header.Get(model.TaskGroupHeader),
+		ContentLanguage:  r.FormValue("content_language"),
+		Owner:            r.FormValue("owner"),
+		Tags:             tags,
+		Description:      r.FormValue("description"),
+		SecretEnv:        form.SecretEnv,
+		SecretEncryption: form.SecretEncryption,
+		AfterSuccess:     form.AfterSuccess,
+		OnFailure:        form.OnFailure,
+	}
+	if !readRecurrence(w, r, tk) {
+		return nil, false
+	}
+	return tk, true
+}
+
+func isTemplateContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == templateContentType || mediaType == "text/plain")
+}
+
+func checkCallback(w http.ResponseWriter, region string, cb *model.Callback) bool {
+	for _, d := range []string{cb.RunIn, cb.RevertIn} {
+		if _, err := getTimeParam(d, time.Time{}); err != nil {
+			http.Error(w, fmt.Sprintf("invalid callback duration: %s", err), http.StatusBadRequest)
+			return false
+		}
+	}
+	if cb.Region != "" {
+		region = cb.Region
+	}
+	return checkTemplate(w, region, cb.Template, nil)
+}
+
+func jsonError(w http.ResponseWriter, code, msg string, status int) {
+	b, _ := json.Marshal(struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}{code, msg})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+func checkTemplate(w http.ResponseWriter, region, tplTxt string, secrets map[string]string) bool {
+	tpl, err := template.Parse(tplTxt)
 	if err != nil {
 		errMsg := fmt.Sprintf("cannot parse template: %s", err)
 		log.Println(errMsg)
-		log.Printf("body was '%s'", string(tplTxt))
+		log.Printf("body was '%s'", tplTxt)
 		http.Error(w, errMsg, http.StatusUnprocessableEntity)
-		return
+		return false
 	}
 
 	env := awsdriver.DefaultTemplateEnv()
+	if len(secrets) > 0 {
+		env.AddFillers(secretFillers(secrets))
+	}
 	_, _, err = template.Compile(tpl, env)
 
 	if err != nil {
 		errMsg := fmt.Sprintf("cannot compile template: %s", err)
 		log.Println(errMsg)
 		http.Error(w, errMsg, http.StatusUnprocessableEntity)
-		return
+		return false
 	}
 	d, err := driversFunc(region)
 	if err != nil {
 		errMsg := fmt.Sprintf("cannot init drivers for dryrun: %s", err)
 		log.Println(errMsg)
 		http.Error(w, errMsg, http.StatusInternalServerError)
-		return
+		return false
 	}
 
 	env.Driver = d
@@ -292,16 +1077,10 @@ func createTask(w http.ResponseWriter, r *http.Request) {
 		errMsg := fmt.Sprintf("cannot dryrun template: %s", err)
 		log.Println(errMsg)
 		http.Error(w, errMsg, http.StatusUnprocessableEntity)
-		return
+		return false
 	}
 
-	tk := &model.Task{Content: string(tplTxt), RunAt: runAt, RevertAt: revertAt, Region: region}
-
-	if err := taskStore.Create(tk); err != nil {
-		log.Println(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	return true
 }
 
 func getTimeParam(param string, defaultTime time.Time) (time.Time, error) {
@@ -1,11 +1,21 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"time"
 
 	"github.com/wallix/awless-scheduler/client"
+	"github.com/wallix/awless-scheduler/model"
 	"github.com/wallix/awless/template"
 	"github.com/wallix/awless/template/driver"
 )
@@ -51,6 +61,31 @@ func TestTasksAPI(t *testing.T) {
 		}
 	})
 
+	t.Run("noop echoes form", func(t *testing.T) {
+		defer taskStore.Cleanup()
+
+		if _, err := schedClient.Noop(context.Background(), client.Form{
+			Region:   "us-west-1",
+			RunIn:    "2m",
+			RevertIn: "2h",
+			Template: tplText,
+			OnFailure: &client.Form{
+				RunIn:    "1m",
+				Template: tplText,
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		tasks, err := schedClient.ListTasks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(tasks), 0; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+	})
+
 	t.Run("template successfully received", func(t *testing.T) {
 		defer taskStore.Cleanup()
 
@@ -90,6 +125,329 @@ func TestTasksAPI(t *testing.T) {
 		}
 	})
 
+	t.Run("rescheduling task", func(t *testing.T) {
+		defer taskStore.Cleanup()
+
+		postTemplate(t, tplText)
+
+		tasks, err := schedClient.ListTasks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(tasks), 1; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+
+		diff, err := schedClient.RescheduleWithDiff(context.Background(), tasks[0].ID, client.Form{
+			Region:   "us-west-1",
+			RunIn:    "3m",
+			RevertIn: "2h",
+			Template: tplText,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := diff.ChangedFields; len(got) == 0 || got[0] != "RunAt" {
+			t.Fatalf("got %v, want RunAt changed first", got)
+		}
+		if got, want := diff.ContentDiff, ""; got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+
+		tasks, err = schedClient.ListTasks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(tasks), 1; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+		if got, want := tasks[0].ID, diff.TaskID; got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("updating task content", func(t *testing.T) {
+		defer taskStore.Cleanup()
+
+		postTemplate(t, tplText)
+
+		tasks, err := schedClient.ListTasks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(tasks), 1; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+
+		updated := "create user name=titi"
+		if err = schedClient.UpdateContent(context.Background(), tasks[0].ID, updated); err != nil {
+			t.Fatal(err)
+		}
+
+		tk, err := schedClient.GetByContentHash(context.Background(), model.ContentHash(updated))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := tk.Content, updated; got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+		if got, want := tk.RunAt.Time(), tasks[0].RunAt.Time(); !got.Equal(want) {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+		if _, err = schedClient.Get(context.Background(), tasks[0].ID); err != client.ErrNotFound {
+			t.Fatalf("got %v, want %v", err, client.ErrNotFound)
+		}
+	})
+
+	t.Run("locking task", func(t *testing.T) {
+		defer taskStore.Cleanup()
+
+		postTemplate(t, tplText)
+
+		tasks, err := schedClient.ListTasks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(tasks), 1; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+
+		token, err := schedClient.Lock(context.Background(), tasks[0].ID, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = schedClient.Lock(context.Background(), tasks[0].ID, time.Minute); err != client.ErrTaskLocked {
+			t.Fatalf("got %v, want %v", err, client.ErrTaskLocked)
+		}
+		// delete replies with the server error of the lock
+		isLocked := func(err error) bool {
+			serverErr, ok := err.(*client.ServerError)
+			return ok && serverErr.Unwrap() == client.ErrTaskLocked
+		}
+		if err = schedClient.Delete(context.Background(), tasks[0].ID); !isLocked(err) {
+			t.Fatalf("got %v, want %v", err, client.ErrTaskLocked)
+		}
+
+		newID, err := schedClient.RescheduleWithLock(context.Background(), tasks[0].ID, token, client.Form{
+			Region:   "us-west-1",
+			RunIn:    "3m",
+			RevertIn: "2h",
+			Template: tplText,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = schedClient.Delete(context.Background(), newID); !isLocked(err) {
+			t.Fatalf("got %v, want lock moved to the new task", err)
+		}
+		if err = schedClient.Unlock(context.Background(), newID, token); err != nil {
+			t.Fatal(err)
+		}
+
+		token, err = schedClient.Lock(context.Background(), newID, 50*time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if _, err = schedClient.RescheduleWithLock(context.Background(), newID, token, client.Form{
+			Region:   "us-west-1",
+			RunIn:    "4m",
+			RevertIn: "2h",
+			Template: tplText,
+		}); err != client.ErrLockExpired {
+			t.Fatalf("got %v, want %v", err, client.ErrLockExpired)
+		}
+		if _, err = schedClient.Lock(context.Background(), newID, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		taskLocks.release(newID)
+	})
+
+	t.Run("posting task pair", func(t *testing.T) {
+		defer taskStore.Cleanup()
+
+		runForm := client.Form{Region: "us-west-1", RunIn: "2m", Template: "create user name=toto"}
+		revertForm := client.Form{Region: "us-west-1", RunIn: "2h", Template: "delete user name=toto"}
+		runID, revertID, err := schedClient.PostWithRollback(context.Background(), runForm, revertForm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		revert, err := schedClient.Get(context.Background(), revertID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := revert.DependsOn, []string{runID}; len(got) != 1 || got[0] != want[0] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+
+		if err = schedClient.Delete(context.Background(), runID); err != nil {
+			t.Fatal(err)
+		}
+		tasks, err := schedClient.ListTasks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(tasks), 0; got != want {
+			t.Fatalf("got %d, want revert task cancelled along with run task", got)
+		}
+	})
+
+	t.Run("rolling back task pair", func(t *testing.T) {
+		defer taskStore.Cleanup()
+
+		previous := taskStore
+		taskStore = &failCreateStore{store: previous, fail: func(tk *model.Task) bool { return len(tk.DependsOn) > 0 }}
+		defer func() { taskStore = previous }()
+
+		runForm := client.Form{Region: "us-west-1", RunIn: "2m", Template: "create user name=toto"}
+		revertForm := client.Form{Region: "us-west-1", RunIn: "2h", Template: "delete user name=toto"}
+		if _, _, err := schedClient.PostWithRollback(context.Background(), runForm, revertForm); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+		tasks, err := previous.GetTasks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(tasks), 0; got != want {
+			t.Fatalf("got %d, want run task removed", got)
+		}
+	})
+
+	t.Run("streaming dead letters", func(t *testing.T) {
+		defer taskStore.Cleanup()
+
+		failTask := func(content string) *model.Task {
+			tk := &model.Task{Content: content, Region: "us-west-1", RunAt: model.FlexibleTime(time.Now().UTC())}
+			if err := taskStore.Create(tk); err != nil {
+				t.Fatal(err)
+			}
+			if err := taskStore.MarkAsFailed(tk.AsFilename()); err != nil {
+				t.Fatal(err)
+			}
+			failed, err := taskStore.GetFailures()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range failed {
+				if f.ID == tk.AsFilename() {
+					return f
+				}
+			}
+			t.Fatalf("failed task %s not found", tk.AsFilename())
+			return nil
+		}
+		replayed := failTask("create user name=toto")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		handled := make(chan string, 2)
+		done := make(chan error)
+		go func() {
+			done <- schedClient.ListenForDeadLetterQueue(ctx, func(ctx context.Context, tk *model.Task) error {
+				handled <- tk.ID
+				return nil
+			})
+		}()
+
+		waitHandled := func(want string) {
+			select {
+			case got := <-handled:
+				if got != want {
+					t.Fatalf("got %s, want %s", got, want)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("task %s not streamed", want)
+			}
+		}
+		waitHandled(replayed.ID)
+
+		published := failTask("create user name=tata")
+		deadLetters.publish(published)
+		waitHandled(published.ID)
+
+		for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+			failed, err := taskStore.GetFailures()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(failed) == 0 {
+				break
+			}
+			if time.Since(start) > 2*time.Second {
+				t.Fatalf("got %d failures, want handled tasks removed", len(failed))
+			}
+		}
+
+		cancel()
+		if err := <-done; err != context.Canceled {
+			t.Fatalf("got %v, want %v", err, context.Canceled)
+		}
+	})
+
+	t.Run("secrets stored encrypted", func(t *testing.T) {
+		defer taskStore.Cleanup()
+
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		secretsKey = key
+		defer func() { secretsKey = nil }()
+		publicKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)})
+		secretsClient, err := client.New(service.discoveryURL(), client.WithSecretEncryption(publicKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = secretsClient.Post(client.Form{
+			Region:    "us-west-1",
+			RunIn:     "2m",
+			Template:  "create user name=toto password={password}",
+			SecretEnv: map[string]string{"password": "s3cr3t"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		files, err := filepath.Glob(filepath.Join(taskStore.(*fsStore).tasksDir, "*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) == 0 {
+			t.Fatal("expected task files")
+		}
+		for _, file := range files {
+			b, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(b), "s3cr3t") {
+				t.Fatalf("got plaintext secret in %s", file)
+			}
+		}
+
+		tasks, err := taskStore.GetTasks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(tasks), 1; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+		secrets, err := decryptSecrets(tasks[0].SecretEnv, tasks[0].SecretEncryption)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := secrets["password"], "s3cr3t"; got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+
+		if err = taskStore.MarkAsFailed(tasks[0].AsFilename()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = os.Stat(secretsFile(filepath.Join(taskStore.(*fsStore).failuresDir, tasks[0].AsFilename()))); !os.IsNotExist(err) {
+			t.Fatalf("got %v, want secrets removed from failed task", err)
+		}
+	})
+
 	t.Run("executing task", func(t *testing.T) {
 		defer taskStore.Cleanup()
 
@@ -174,3 +532,14 @@ func TestTasksAPI(t *testing.T) {
 		}
 	})
 }
+
+func TestLoadPercent(t *testing.T) {
+	for _, c := range []struct {
+		queued, capacity int
+		want             float64
+	}{{0, 100, 0}, {1, 100, 1}, {50, 100, 50}, {150, 100, 100}, {1, 0, 100}} {
+		if got := loadPercent(c.queued, c.capacity); got != c.want {
+			t.Fatalf("%d/%d: got %v, want %v", c.queued, c.capacity, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a five fields cron expression: minute, hour, day of month,
+// month and day of week (0 being sunday). Fields are *, values, ranges and
+// lists of them, each with an optional step, such as "*/15 9-17 * * 1-5".
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// days match either field when both are restricted
+	domStar, dowStar bool
+}
+
+// cronHorizon bounds the search of the next occurrence of impossible
+// schedules, such as february 30th.
+const cronHorizon = 5 * 366 * 24 * time.Hour
+
+func ParseCron(expr string) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression '%s': expected 5 fields, got %d", expr, len(fields))
+	}
+	s := &CronSchedule{domStar: fields[2] == "*", dowStar: fields[4] == "*"}
+	for i, f := range []struct {
+		bits     *uint64
+		min, max int
+		name     string
+	}{
+		{&s.minute, 0, 59, "minute"},
+		{&s.hour, 0, 23, "hour"},
+		{&s.dom, 1, 31, "day of month"},
+		{&s.month, 1, 12, "month"},
+		{&s.dow, 0, 7, "day of week"},
+	} {
+		bits, err := parseCronField(fields[i], f.min, f.max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron %s '%s': %s", f.name, fields[i], err)
+		}
+		*f.bits = bits
+	}
+	// 7 is sunday as well
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step '%s'", part[i+1:])
+			}
+			part = part[:i]
+		}
+		low, high := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if low, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value '%s'", bounds[0])
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid value '%s'", bounds[1])
+				}
+			}
+			if low < min || high > max || low > high {
+				return 0, fmt.Errorf("range %d-%d out of %d-%d", low, high, min, max)
+			}
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Next returns the first time of the schedule strictly after the given time,
+// in its location. It is zero when the schedule has no time in the next
+// years.
+func (s *CronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	until := after.Add(cronHorizon)
+	for !t.After(until) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s *CronSchedule) matchesDay(t time.Time) bool {
+	dom, dow := s.dom&(1<<uint(t.Day())) != 0, s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
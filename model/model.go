@@ -2,15 +2,54 @@ package model
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"hash/adler32"
+	"strings"
 	"time"
 )
 
 const (
-	AwlessFileExt = "aws"
-	StampLayout   = "2006-01-02-15h04m05s"
+	AwlessFileExt  = "aws"
+	MetaFileExt    = "meta"
+	SecretsFileExt = "secrets"
+	StampLayout    = "2006-01-02-15h04m05s"
+
+	// FormContentType is the media type of form bodies carrying a template
+	// along with its callbacks.
+	FormContentType = "application/vnd.awless-scheduler.form+json"
+
+	// SecretEncryptionRSAOAEP marks form secrets encrypted with RSA-OAEP
+	// SHA-256 and base64 encoded.
+	SecretEncryptionRSAOAEP = "rsa-oaep-sha256"
+
+	// TaskGroupHeader holds the group of the tasks created by a request.
+	TaskGroupHeader = "X-Task-Group"
+)
+
+const (
+	StatusPending = "pending"
+	StatusFailed  = "failed"
+	StatusDone    = "done"
+
+	// StatusRunning is the status of the pending tasks being executed.
+	StatusRunning = "running"
+
+	// StatusBackfilled marks the history entries of overdue tasks triggered
+	// manually.
+	StatusBackfilled = "backfilled"
+
+	// StatusReverted marks the history entries of tasks whose revert task
+	// ran successfully.
+	StatusReverted = "reverted"
+)
+
+var (
+	ErrHashMismatch            = errors.New("task content does not match its hash")
+	ErrConflictingTagOperation = errors.New("tag both added and removed")
 )
 
 type ServiceInfo struct {
@@ -18,34 +57,331 @@ type ServiceInfo struct {
 	ServiceAddr     string
 	TickerFrequency string
 	UnixSockMode    bool
+	APIVersion      int
+
+	QueueDepth, WorkerCount, MaxWorkers int
+	LoadPercent                         float64
+}
+
+// FormDescription is the scheduler interpretation of a form.
+type FormDescription struct {
+	ParsedRunAt, ParsedRevertAt time.Time
+	ParsedRegion                string
+	TemplateLineCount           int
+	Warnings                    []string
+}
+
+type LintResult struct {
+	Valid    bool
+	Errors   []LintError
+	Warnings []LintWarning
+	// Source is "server" or "client-side"
+	Source string
+}
+
+// LintError positions are 1-based, zero when unknown.
+type LintError struct {
+	Line, Column int
+	Message      string
+}
+
+type LintWarning LintError
+
+type VersionInfo struct {
+	Version    string
+	APIVersion int
+}
+
+// TraceResult lists the hops serving a trace request, the scheduler last.
+type TraceResult struct {
+	Hops []TraceHop
+}
+
+type TraceHop struct {
+	PodName, NodeName, Region string
+	Latency                   time.Duration
+	Timestamp                 time.Time
+}
+
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+// TaskChange is a change of the pending tasks. Deleted tasks only carry
+// their ID.
+type TaskChange struct {
+	ChangeType string
+	Task       *Task
+	At         time.Time
+}
+
+// TaskChanges lists the changes since a token along with the token of the
+// next poll.
+type TaskChanges struct {
+	Changes []*TaskChange
+	Token   string
+}
+
+// OverloadThreshold is the load percent above which a scheduler is overloaded.
+var OverloadThreshold = 80.0
+
+func (si ServiceInfo) IsOverloaded() bool {
+	return si.LoadPercent > OverloadThreshold
 }
 
 type Task struct {
-	Content  string
-	RunAt    time.Time
-	RevertAt time.Time
-	Region   string
+	ID          string
+	Content     string
+	ContentHash string
+	RunAt       FlexibleTime
+	RevertAt    FlexibleTime
+	Region      string
+	Status      string
+	DependsOn   []string
+	ContentType string
+	Group       string
+
+	// ContentLanguage is a syntax highlighting hint, such as "awless".
+	ContentLanguage string
+
+	// Owner is the identity of the submitter, as declared by the client.
+	Owner string
+
+	// CronExpr schedules the task again at its next time once run. See
+	// ParseCron.
+	CronExpr string
+	// MaxRetries is how many times a failed run is retried at the next tick.
+	MaxRetries int
+
+	Tags        map[string]string
+	Description string
+
+	// SecretEnv fills template holes at execution, once decrypted as per
+	// SecretEncryption. Both are never marshalled.
+	SecretEnv        map[string]string
+	SecretEncryption string
+
+	AfterSuccess, OnFailure     *Callback
+	AfterSuccessID, OnFailureID string
+
+	// RevertID is the task cancelled along with this one, as posted in pair.
+	RevertID string
+}
+
+// TaskPair is posted to schedule a task along with the task reverting it.
+// Parts are the query, content type and body of their POST /tasks request.
+type TaskPair struct {
+	Run, Revert TaskPairPart
+}
+
+type TaskPairPart struct {
+	Query, ContentType, Body string
+}
+
+type TaskPairIDs struct {
+	RunID, RevertID string
+}
+
+// TagPatch adds then removes tags of a task.
+type TagPatch struct {
+	Add    map[string]string `json:"add,omitempty"`
+	Remove []string          `json:"remove,omitempty"`
+}
+
+func (p TagPatch) Validate() error {
+	for k := range p.Add {
+		if k == "" || strings.Contains(k, "=") {
+			return fmt.Errorf("invalid tag key '%s'", k)
+		}
+	}
+	for _, k := range p.Remove {
+		if _, ok := p.Add[k]; ok {
+			return ErrConflictingTagOperation
+		}
+	}
+	return nil
+}
+
+// Callback is a task spawned by the scheduler once its parent task succeeded
+// or failed. Durations are relative to the parent task execution and region
+// defaults to the parent task region.
+type Callback struct {
+	Region, RunIn, RevertIn string
+	Template                string
+}
+
+type HistoryEntry struct {
+	RunID                 string
+	StartedAt, FinishedAt time.Time
+	ExitCode              int
+	Status                string
+	OutputSnippet         string
+	RevertTaskID          string
 }
 
+// AuditEntry is the history entry of a task whose revert task ran.
+type AuditEntry HistoryEntry
+
 func (tk *Task) AsFilename() string {
 	checksum := adler32.Checksum([]byte(tk.Content))
-	return fmt.Sprintf("%d_%s_%s_%s.%s", checksum, tk.RunAt.UTC().Format(StampLayout), tk.RevertAt.UTC().Format(StampLayout), tk.Region, AwlessFileExt)
+	return fmt.Sprintf("%d_%s_%s_%s.%s", checksum, tk.RunAt.Time().UTC().Format(StampLayout), tk.RevertAt.Time().UTC().Format(StampLayout), tk.Region, AwlessFileExt)
+}
+
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func (tk *Task) VerifyContentHash() error {
+	if ContentHash(tk.Content) != tk.ContentHash {
+		return ErrHashMismatch
+	}
+	return nil
+}
+
+func (tk *Task) RunAtInZone(tz string) (time.Time, error) {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return tk.RunAt.Time().In(loc), nil
+}
+
+func (tk *Task) OverdueDuration() time.Duration {
+	return time.Since(tk.RunAt.Time())
+}
+
+// AllTaskFields selects every field of a task in a field mask.
+const AllTaskFields = "*"
+
+// TaskFields are the names of the task fields in field masks.
+var TaskFields = []string{"id", "content", "content_hash", "run_at", "revert_at", "region", "status", "depends_on", "content_type", "content_language", "group", "owner", "cron_expr", "max_retries", "tags", "description", "after_success_id", "on_failure_id", "revert_id"}
+
+// Mask returns a copy of the task keeping only the given fields. Secrets and
+// callbacks are never kept.
+func (tk *Task) Mask(fields []string) (*Task, error) {
+	masked := &Task{}
+	for _, field := range fields {
+		switch field {
+		case AllTaskFields:
+			m, _ := tk.Mask(TaskFields)
+			return m, nil
+		case "id":
+			masked.ID = tk.ID
+		case "content":
+			masked.Content = tk.Content
+		case "content_hash":
+			masked.ContentHash = tk.ContentHash
+		case "run_at":
+			masked.RunAt = tk.RunAt
+		case "revert_at":
+			masked.RevertAt = tk.RevertAt
+		case "region":
+			masked.Region = tk.Region
+		case "status":
+			masked.Status = tk.Status
+		case "depends_on":
+			masked.DependsOn = append([]string(nil), tk.DependsOn...)
+		case "content_type":
+			masked.ContentType = tk.ContentType
+		case "content_language":
+			masked.ContentLanguage = tk.ContentLanguage
+		case "group":
+			masked.Group = tk.Group
+		case "owner":
+			masked.Owner = tk.Owner
+		case "cron_expr":
+			masked.CronExpr = tk.CronExpr
+		case "max_retries":
+			masked.MaxRetries = tk.MaxRetries
+		case "tags":
+			if tk.Tags != nil {
+				masked.Tags = make(map[string]string, len(tk.Tags))
+				for k, v := range tk.Tags {
+					masked.Tags[k] = v
+				}
+			}
+		case "description":
+			masked.Description = tk.Description
+		case "after_success_id":
+			masked.AfterSuccessID = tk.AfterSuccessID
+		case "on_failure_id":
+			masked.OnFailureID = tk.OnFailureID
+		case "revert_id":
+			masked.RevertID = tk.RevertID
+		default:
+			return nil, fmt.Errorf("unknown task field '%s'", field)
+		}
+	}
+	return masked, nil
+}
+
+// Getters are nil-safe, returning zero values for a nil task.
+
+func (tk *Task) GetID() string {
+	if tk == nil {
+		return ""
+	}
+	return tk.ID
+}
+
+func (tk *Task) GetContent() string {
+	if tk == nil {
+		return ""
+	}
+	return tk.Content
+}
+
+func (tk *Task) GetRunAt() time.Time {
+	if tk == nil {
+		return time.Time{}
+	}
+	return tk.RunAt.Time()
+}
+
+func (tk *Task) GetRevertAt() time.Time {
+	if tk == nil {
+		return time.Time{}
+	}
+	return tk.RevertAt.Time()
+}
+
+func (tk *Task) GetRegion() string {
+	if tk == nil {
+		return ""
+	}
+	return tk.Region
+}
+
+func (tk *Task) GetStatus() string {
+	if tk == nil {
+		return ""
+	}
+	return tk.Status
 }
 
 func (tk *Task) MarshalJSON() ([]byte, error) {
 	buffer := bytes.NewBufferString("{")
+	if tk.ID != "" {
+		buffer.WriteString(fmt.Sprintf("\"ID\":\"%s\",", tk.ID))
+	}
 	jsonValue, err := json.Marshal(tk.Content)
 	if err != nil {
 		return nil, err
 	}
 	buffer.WriteString(fmt.Sprintf("\"Content\":%s,", jsonValue))
+	if tk.ContentHash != "" {
+		buffer.WriteString(fmt.Sprintf("\"ContentHash\":\"%s\",", tk.ContentHash))
+	}
 	if !tk.RunAt.IsZero() {
 		jsonValue, err = json.Marshal(tk.RunAt)
 		if err != nil {
 			return nil, err
 		}
 		buffer.WriteString(fmt.Sprintf("\"RunAt\":%s,", jsonValue))
-		buffer.WriteString(fmt.Sprintf("\"RunIn\":\"%s\",", time.Until(tk.RunAt)))
+		buffer.WriteString(fmt.Sprintf("\"RunIn\":\"%s\",", time.Until(tk.RunAt.Time())))
 	}
 	if !tk.RevertAt.IsZero() {
 		jsonValue, err = json.Marshal(tk.RevertAt)
@@ -53,7 +389,78 @@ func (tk *Task) MarshalJSON() ([]byte, error) {
 			return nil, err
 		}
 		buffer.WriteString(fmt.Sprintf("\"RevertAt\":%s,", jsonValue))
-		buffer.WriteString(fmt.Sprintf("\"RevertIn\":\"%s\",", time.Until(tk.RevertAt)))
+		buffer.WriteString(fmt.Sprintf("\"RevertIn\":\"%s\",", time.Until(tk.RevertAt.Time())))
+	}
+	if tk.Status != "" {
+		buffer.WriteString(fmt.Sprintf("\"Status\":\"%s\",", tk.Status))
+	}
+	if len(tk.DependsOn) > 0 {
+		jsonValue, err = json.Marshal(tk.DependsOn)
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(fmt.Sprintf("\"DependsOn\":%s,", jsonValue))
+	}
+	if tk.Group != "" {
+		jsonValue, err = json.Marshal(tk.Group)
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(fmt.Sprintf("\"Group\":%s,", jsonValue))
+	}
+	if tk.Owner != "" {
+		jsonValue, err = json.Marshal(tk.Owner)
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(fmt.Sprintf("\"Owner\":%s,", jsonValue))
+	}
+	if tk.CronExpr != "" {
+		jsonValue, err = json.Marshal(tk.CronExpr)
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(fmt.Sprintf("\"CronExpr\":%s,", jsonValue))
+	}
+	if tk.MaxRetries != 0 {
+		buffer.WriteString(fmt.Sprintf("\"MaxRetries\":%d,", tk.MaxRetries))
+	}
+	if len(tk.Tags) > 0 {
+		jsonValue, err = json.Marshal(tk.Tags)
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(fmt.Sprintf("\"Tags\":%s,", jsonValue))
+	}
+	if tk.Description != "" {
+		jsonValue, err = json.Marshal(tk.Description)
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(fmt.Sprintf("\"Description\":%s,", jsonValue))
+	}
+	if tk.ContentType != "" {
+		jsonValue, err = json.Marshal(tk.ContentType)
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(fmt.Sprintf("\"ContentType\":%s,", jsonValue))
+	}
+	if tk.ContentLanguage != "" {
+		jsonValue, err = json.Marshal(tk.ContentLanguage)
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(fmt.Sprintf("\"ContentLanguage\":%s,", jsonValue))
+	}
+	if tk.AfterSuccessID != "" {
+		buffer.WriteString(fmt.Sprintf("\"AfterSuccessID\":\"%s\",", tk.AfterSuccessID))
+	}
+	if tk.OnFailureID != "" {
+		buffer.WriteString(fmt.Sprintf("\"OnFailureID\":\"%s\",", tk.OnFailureID))
+	}
+	if tk.RevertID != "" {
+		buffer.WriteString(fmt.Sprintf("\"RevertID\":\"%s\",", tk.RevertID))
 	}
 	buffer.WriteString(fmt.Sprintf("\"Region\":\"%s\"", tk.Region))
 
@@ -0,0 +1,145 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestVerifyContentHash(t *testing.T) {
+	tk := &Task{Content: "create user name=toto"}
+	tk.ContentHash = ContentHash(tk.Content)
+	if err := tk.VerifyContentHash(); err != nil {
+		t.Fatal(err)
+	}
+
+	tk.Content = "create user name=tata"
+	if got, want := tk.VerifyContentHash(), ErrHashMismatch; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestFlexibleTimeUnmarshal(t *testing.T) {
+	want := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, raw := range []string{`"2017-06-01T12:00:00Z"`, `1496318400`, `1496318400000`, `1496318400.0`, `1496318400000.0`, `1.4963184e12`} {
+		var ft FlexibleTime
+		if err := json.Unmarshal([]byte(raw), &ft); err != nil {
+			t.Fatalf("%s: %s", raw, err)
+		}
+		if got := ft.Time(); !got.Equal(want) {
+			t.Fatalf("%s: got %s, want %s", raw, got, want)
+		}
+	}
+
+	var ft FlexibleTime
+	if err := json.Unmarshal([]byte(`1496318400123.5`), &ft); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ft.Time(), want.Add(123500*time.Microsecond); !got.Equal(want) {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	b, err := json.Marshal(FlexibleTime(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `"2017-06-01T12:00:00Z"`; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestTaskMarshalJSONEscapesStrings(t *testing.T) {
+	value := "first\x01second\n"
+	for _, tk := range []*Task{
+		{Group: value},
+		{Owner: value},
+		{CronExpr: value},
+		{ContentType: value},
+		{ContentLanguage: value},
+	} {
+		b, err := json.Marshal(tk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Task
+		if err := json.Unmarshal(b, &decoded); err != nil {
+			t.Fatalf("%s: %s", b, err)
+		}
+		again, err := json.Marshal(&decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(again), string(b); got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	}
+}
+
+func TestServiceInfoIsOverloaded(t *testing.T) {
+	if (ServiceInfo{LoadPercent: 80}).IsOverloaded() {
+		t.Fatal("expected not overloaded at threshold")
+	}
+	if !(ServiceInfo{LoadPercent: 80.5}).IsOverloaded() {
+		t.Fatal("expected overloaded above threshold")
+	}
+}
+
+func TestTaskGettersOnNil(t *testing.T) {
+	var tk *Task
+	if tk.GetID() != "" || tk.GetRegion() != "" || !tk.GetRunAt().IsZero() {
+		t.Fatal("expected zero values for nil task")
+	}
+	tk = &Task{ID: "1", Region: "us-west-1", RunAt: FlexibleTime(time.Unix(1496318400, 0))}
+	if got, want := tk.GetRunAt(), time.Unix(1496318400, 0); !got.Equal(want) {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestRunAtInZone(t *testing.T) {
+	tk := &Task{RunAt: FlexibleTime(time.Date(2017, 7, 1, 12, 0, 0, 0, time.UTC))}
+	runAt, err := tk.RunAtInZone("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := runAt.Format(time.RFC3339), "2017-07-01T08:00:00-04:00"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if !runAt.Equal(tk.RunAt.Time()) {
+		t.Fatalf("got %s, want same instant as %s", runAt, tk.RunAt.Time())
+	}
+	if _, err = tk.RunAtInZone("Mars/Olympus_Mons"); err == nil {
+		t.Fatal("expected error on unknown timezone")
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	from := time.Date(2017, time.June, 30, 23, 58, 30, 0, time.UTC) // friday
+	tcases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2017, time.June, 30, 23, 59, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2017, time.July, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 9-17 * * 1-5", time.Date(2017, time.July, 3, 9, 30, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2017, time.July, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2017, time.July, 2, 12, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2017, time.July, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tcase := range tcases {
+		s, err := ParseCron(tcase.expr)
+		if err != nil {
+			t.Fatalf("%s: %s", tcase.expr, err)
+		}
+		if got, want := s.Next(from), tcase.want; !got.Equal(want) {
+			t.Fatalf("%s: got %s, want %s", tcase.expr, got, want)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Fatalf("%q: expected error", expr)
+		}
+	}
+}
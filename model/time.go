@@ -0,0 +1,76 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// epochMillisThreshold separates epoch seconds from epoch milliseconds: as
+// seconds, it is a date more than 30 000 years away.
+const epochMillisThreshold = 1e12
+
+// FlexibleTime decodes RFC3339 strings as well as Unix epoch seconds or
+// milliseconds, as integers or floats. It always encodes as RFC3339.
+type FlexibleTime time.Time
+
+func (t FlexibleTime) Time() time.Time {
+	return time.Time(t)
+}
+
+func (t FlexibleTime) IsZero() bool {
+	return time.Time(t).IsZero()
+}
+
+func (t FlexibleTime) String() string {
+	return time.Time(t).String()
+}
+
+func (t FlexibleTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Time(t).Format(time.RFC3339Nano))
+}
+
+func (t *FlexibleTime) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if bytes.Equal(b, []byte("null")) {
+		*t = FlexibleTime{}
+		return nil
+	}
+
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		parsed, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return fmt.Errorf("invalid time '%s': %s", s, err)
+		}
+		*t = FlexibleTime(parsed)
+		return nil
+	}
+
+	if epoch, err := strconv.ParseInt(string(b), 10, 64); err == nil {
+		if epoch >= epochMillisThreshold || epoch <= -epochMillisThreshold {
+			*t = FlexibleTime(time.Unix(0, epoch*int64(time.Millisecond)).UTC())
+		} else {
+			*t = FlexibleTime(time.Unix(epoch, 0).UTC())
+		}
+		return nil
+	}
+
+	epoch, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return fmt.Errorf("invalid time %s: expecting RFC3339 string or Unix epoch", b)
+	}
+	whole, frac := math.Modf(epoch)
+	if epoch >= epochMillisThreshold || epoch <= -epochMillisThreshold {
+		*t = FlexibleTime(time.Unix(0, int64(whole)*int64(time.Millisecond)+int64(frac*float64(time.Millisecond))).UTC())
+	} else {
+		*t = FlexibleTime(time.Unix(int64(whole), int64(frac*float64(time.Second))).UTC())
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+// createTaskPair creates a run task and its revert task, the revert task
+// depending on the run task and being cancelled along with it.
+func createTaskPair(w http.ResponseWriter, r *http.Request) {
+	var pair model.TaskPair
+	if err := json.NewDecoder(r.Body).Decode(&pair); err != nil {
+		http.Error(w, "invalid json task pair body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	run, ok := readPairPart(w, r, pair.Run)
+	if !ok {
+		return
+	}
+	revert, ok := readPairPart(w, r, pair.Revert)
+	if !ok {
+		return
+	}
+	revert.DependsOn = append(revert.DependsOn, run.AsFilename())
+	run.RevertID = revert.AsFilename()
+
+	if err := taskStore.Create(run); err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := taskStore.Create(revert); err != nil {
+		taskStore.Remove(run.AsFilename())
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	b, err := json.Marshal(model.TaskPairIDs{RunID: run.AsFilename(), RevertID: revert.AsFilename()})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+}
+
+// readPairPart reads a part as the POST /tasks request it stands for.
+func readPairPart(w http.ResponseWriter, r *http.Request, part model.TaskPairPart) (*model.Task, bool) {
+	sub, err := http.NewRequest(http.MethodPost, "/tasks?"+part.Query, strings.NewReader(part.Body))
+	if err != nil {
+		http.Error(w, "invalid task pair part", http.StatusBadRequest)
+		return nil, false
+	}
+	sub.Header.Set("Content-Type", part.ContentType)
+	sub.Header.Set(model.TaskGroupHeader, r.Header.Get(model.TaskGroupHeader))
+	return readTask(w, sub)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+// readRecurrence reads the cron and max_retries params of the task.
+func readRecurrence(w http.ResponseWriter, r *http.Request, tk *model.Task) bool {
+	if cron := r.FormValue("cron"); cron != "" {
+		if _, err := model.ParseCron(cron); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return false
+		}
+		tk.CronExpr = cron
+	}
+	if retries := r.FormValue("max_retries"); retries != "" {
+		maxRetries, err := strconv.Atoi(retries)
+		if err != nil || maxRetries < 0 {
+			http.Error(w, "invalid count for 'max_retries' param", http.StatusBadRequest)
+			return false
+		}
+		tk.MaxRetries = maxRetries
+	}
+	return true
+}
+
+// parseTags reads tags given as key=value.
+func parseTags(params []string) (map[string]string, error) {
+	if len(params) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string, len(params))
+	for _, param := range params {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid tag '%s', expected key=value", param)
+		}
+		tags[kv[0]] = kv[1]
+	}
+	return tags, nil
+}
+
+// spawnNextRun schedules the next run of a cron task, its revert keeping the
+// same delay after the run.
+func spawnNextRun(tk *model.Task) {
+	schedule, err := model.ParseCron(tk.CronExpr)
+	if err != nil {
+		log.Printf("cannot schedule next run of task %s: %s", tk.ID, err)
+		return
+	}
+	from := tk.RunAt.Time()
+	if now := time.Now().UTC(); now.After(from) {
+		from = now
+	}
+	next := schedule.Next(from)
+	if next.IsZero() {
+		log.Printf("no next run for cron '%s' of task %s", tk.CronExpr, tk.ID)
+		return
+	}
+	if _, err = respawn(tk, next); err != nil {
+		log.Printf("cannot schedule next run of task %s: %s", tk.ID, err)
+	}
+}
+
+// spawnRetry schedules a failed task to run again at the next tick, its
+// dependents then depending on the retry. Retries do not schedule the next
+// cron run, already scheduled by the first run.
+func spawnRetry(tk *model.Task) (string, error) {
+	retry := *tk
+	retry.CronExpr, retry.MaxRetries = "", tk.MaxRetries-1
+	runAt := time.Now().UTC()
+	// ids have a one second resolution: a retry in the second the task was
+	// due would take over its id
+	if runAt.Truncate(time.Second).Equal(tk.RunAt.Time().UTC().Truncate(time.Second)) {
+		runAt = runAt.Add(time.Second)
+	}
+	retryID, err := respawn(&retry, runAt)
+	if err != nil {
+		return "", err
+	}
+	if _, err = taskStore.ReplaceDependency(tk.AsFilename(), retryID); err != nil {
+		taskStore.ReplaceDependency(retryID, tk.AsFilename())
+		taskStore.Remove(retryID)
+		return "", err
+	}
+	return retryID, nil
+}
+
+// respawn creates a copy of the task running at the given time, without its
+// dependencies nor spawned callbacks.
+func respawn(tk *model.Task, runAt time.Time) (string, error) {
+	spawned := *tk
+	spawned.ID, spawned.Status, spawned.DependsOn, spawned.RevertID = "", "", nil, ""
+	spawned.AfterSuccessID, spawned.OnFailureID = "", ""
+	spawned.RunAt = model.FlexibleTime(runAt)
+	if !tk.RevertAt.IsZero() {
+		spawned.RevertAt = model.FlexibleTime(tk.RevertAt.Time().Add(runAt.Sub(tk.RunAt.Time())))
+	}
+	if err := taskStore.Create(&spawned); err != nil {
+		return "", err
+	}
+	return spawned.AsFilename(), nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+func TestSpawnRetry(t *testing.T) {
+	taskStore = createTmpFSStore()
+	defer taskStore.Destroy()
+
+	now := time.Now().UTC()
+	failed := &model.Task{
+		Content: "create user name=toto", Region: "us-west-1",
+		RunAt: model.FlexibleTime(now.Add(-time.Minute)), RevertAt: model.FlexibleTime(now.Add(time.Hour)),
+		CronExpr: "0 3 * * *", MaxRetries: 2, DependsOn: []string{"other"},
+	}
+	dependent := &model.Task{Content: "attach user name=toto group=admins", Region: "us-west-1", RunAt: model.FlexibleTime(now.Add(time.Hour)), DependsOn: []string{failed.AsFilename()}}
+	for _, tk := range []*model.Task{failed, dependent} {
+		if err := taskStore.Create(tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	retryID, err := spawnRetry(failed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retry, err := taskStore.GetTask(retryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retry.CronExpr != "" || retry.MaxRetries != 1 || retry.DependsOn != nil {
+		t.Fatalf("got cron %q, %d retries and dependencies %v", retry.CronExpr, retry.MaxRetries, retry.DependsOn)
+	}
+	if retry.RunAt.Time().Before(now.Truncate(time.Second)) {
+		t.Fatalf("got retry at %s, want after %s", retry.RunAt.Time(), now)
+	}
+	if got, want := retry.RevertAt.Time().Sub(retry.RunAt.Time()), failed.RevertAt.Time().Sub(failed.RunAt.Time()); got != want {
+		t.Fatalf("got revert %s after run, want %s", got, want)
+	}
+
+	tk, err := taskStore.GetTask(dependent.AsFilename())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tk.DependsOn, []string{retryID}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got dependencies %v, want %v", got, want)
+	}
+}
+
+func TestSpawnRetryInTheSecondOfTheTask(t *testing.T) {
+	taskStore = createTmpFSStore()
+	defer taskStore.Destroy()
+
+	failed := &model.Task{Content: "create user name=toto", Region: "us-west-1", RunAt: model.FlexibleTime(time.Now().UTC()), MaxRetries: 1}
+	if err := taskStore.Create(failed); err != nil {
+		t.Fatal(err)
+	}
+
+	retryID, err := spawnRetry(failed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retryID == failed.AsFilename() {
+		t.Fatalf("got retry with the id of the failed task %s", retryID)
+	}
+	if _, err := taskStore.GetTask(failed.AsFilename()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := taskStore.GetTask(retryID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSpawnNextRun(t *testing.T) {
+	taskStore = createTmpFSStore()
+	defer taskStore.Destroy()
+
+	runAt := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	tk := &model.Task{
+		Content: "create user name=toto", Region: "us-west-1",
+		RunAt: model.FlexibleTime(runAt), RevertAt: model.FlexibleTime(runAt.Add(time.Hour)),
+		CronExpr: "0 3 * * *", MaxRetries: 1, DependsOn: []string{"other"}, OnFailureID: "callback",
+	}
+	spawnNextRun(tk)
+
+	tasks, err := taskStore.GetTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 {
+		t.Fatalf("got %d tasks, want 1", len(tasks))
+	}
+	next := tasks[0]
+	if got := next.RunAt.Time(); got.Hour() != 3 || got.Minute() != 0 || !got.After(runAt) || got.Sub(runAt) > 24*time.Hour {
+		t.Fatalf("got next run at %s after %s", got, runAt)
+	}
+	if got, want := next.RevertAt.Time().Sub(next.RunAt.Time()), time.Hour; got != want {
+		t.Fatalf("got revert %s after run, want %s", got, want)
+	}
+	if next.CronExpr != tk.CronExpr || next.MaxRetries != 1 || next.DependsOn != nil || next.OnFailureID != "" {
+		t.Fatalf("got cron %q, %d retries, dependencies %v and callback %q", next.CronExpr, next.MaxRetries, next.DependsOn, next.OnFailureID)
+	}
+
+	invalid := *tk
+	invalid.CronExpr = "0 25 * * *"
+	spawnNextRun(&invalid)
+	if tasks, _ = taskStore.GetTasks(); len(tasks) != 1 {
+		t.Fatalf("got %d tasks, want 1", len(tasks))
+	}
+}
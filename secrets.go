@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+var secretsKey *rsa.PrivateKey
+
+func loadSecretsKey(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read secrets key: %s", err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return fmt.Errorf("no PEM data in secrets key %s", path)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		secretsKey = key
+		return nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("cannot parse secrets key: %s", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return errors.New("secrets key is not a RSA key")
+	}
+	secretsKey = rsaKey
+	return nil
+}
+
+func decryptSecrets(secrets map[string]string, encryption string) (map[string]string, error) {
+	switch encryption {
+	case "":
+		return secrets, nil
+	case model.SecretEncryptionRSAOAEP:
+	default:
+		return nil, fmt.Errorf("unknown secrets encryption '%s'", encryption)
+	}
+	if secretsKey == nil {
+		return nil, errors.New("scheduler has no key to decrypt secrets")
+	}
+
+	decrypted := make(map[string]string)
+	for k, v := range secrets {
+		cipher, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode secret '%s': %s", k, err)
+		}
+		plain, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, secretsKey, cipher, nil)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decrypt secret '%s': %s", k, err)
+		}
+		decrypted[k] = string(plain)
+	}
+	return decrypted, nil
+}
+
+func secretsFile(taskFile string) string {
+	return fmt.Sprintf("%s.%s", taskFile, model.SecretsFileExt)
+}
+
+// taskSecrets are the secrets of a task as posted, still encrypted if they
+// were. They are only decrypted at execution.
+type taskSecrets struct {
+	Env        map[string]string
+	Encryption string `json:",omitempty"`
+}
+
+func writeSecrets(taskFile string, tk *model.Task) error {
+	if len(tk.SecretEnv) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(taskSecrets{Env: tk.SecretEnv, Encryption: tk.SecretEncryption})
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(secretsFile(taskFile), b, 0600); err != nil {
+		return fmt.Errorf("cannot create task secrets as file: %s", err)
+	}
+	return nil
+}
+
+func readSecrets(taskFile string) (taskSecrets, error) {
+	var secrets taskSecrets
+	b, err := ioutil.ReadFile(secretsFile(taskFile))
+	if os.IsNotExist(err) {
+		return secrets, nil
+	}
+	if err != nil {
+		return secrets, err
+	}
+	err = json.Unmarshal(b, &secrets)
+	return secrets, err
+}
+
+func secretFillers(secrets map[string]string) map[string]interface{} {
+	fillers := make(map[string]interface{})
+	for k, v := range secrets {
+		fillers[k] = v
+	}
+	return fillers
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -8,6 +9,7 @@ import (
 	"path/filepath"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/wallix/awless-scheduler/model"
 )
@@ -15,9 +17,16 @@ import (
 type store interface {
 	Create(tk *model.Task) error
 	Remove(id string) error
+	RemoveFailure(id string) error
+	GetTask(id string) (*model.Task, error)
 	GetTasks() ([]*model.Task, error)
 	GetFailures() ([]*model.Task, error)
 	MarkAsFailed(id string) error
+	SaveCallbacks(tk *model.Task) error
+	PurgeExpired(before time.Time) (int, []string, error)
+	PurgeFailures(before time.Time) (int, error)
+	PatchTags(id string, patch model.TagPatch) error
+	ReplaceDependency(oldID, newID string) ([]string, error)
 	Cleanup() error
 	Destroy() error
 }
@@ -47,13 +56,38 @@ func (fs *fsStore) Create(tk *model.Task) error {
 	fs.mux.Lock()
 	defer fs.mux.Unlock()
 
-	err := ioutil.WriteFile(filepath.Join(fs.tasksDir, tk.AsFilename()), []byte(tk.Content), 0644)
+	file := filepath.Join(fs.tasksDir, tk.AsFilename())
+	if err := writeMeta(file, metaOf(tk)); err != nil {
+		return err
+	}
+	if err := writeSecrets(file, tk); err != nil {
+		return err
+	}
+	err := ioutil.WriteFile(file, []byte(tk.Content), 0644)
 	if err != nil {
 		return fmt.Errorf("cannot create task as file: %s", err)
 	}
 	return nil
 }
 
+func (fs *fsStore) GetTask(id string) (*model.Task, error) {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	for dir, status := range map[string]string{fs.tasksDir: model.StatusPending, fs.failuresDir: model.StatusFailed} {
+		file := filepath.Join(dir, filepath.Base(id))
+		if _, err := os.Stat(file); err == nil {
+			tk, err := New(file)
+			if err != nil {
+				return nil, err
+			}
+			tk.Status = status
+			return tk, nil
+		}
+	}
+	return nil, os.ErrNotExist
+}
+
 func (fs *fsStore) GetTasks() ([]*model.Task, error) {
 	tasks := make([]*model.Task, 0)
 
@@ -62,6 +96,7 @@ func (fs *fsStore) GetTasks() ([]*model.Task, error) {
 		if err != nil {
 			return tasks, err
 		}
+		tk.Status = model.StatusPending
 		tasks = append(tasks, tk)
 	}
 
@@ -76,6 +111,7 @@ func (fs *fsStore) GetFailures() ([]*model.Task, error) {
 		if err != nil {
 			return tasks, err
 		}
+		tk.Status = model.StatusFailed
 		tasks = append(tasks, tk)
 	}
 
@@ -83,21 +119,210 @@ func (fs *fsStore) GetFailures() ([]*model.Task, error) {
 }
 
 func (fs *fsStore) Remove(id string) error {
+	return fs.remove(fs.tasksDir, id)
+}
+
+func (fs *fsStore) RemoveFailure(id string) error {
+	return fs.remove(fs.failuresDir, id)
+}
+
+func (fs *fsStore) remove(dir, id string) error {
 	fs.mux.Lock()
 	defer fs.mux.Unlock()
 
-	return os.Remove(filepath.Join(fs.tasksDir, id))
+	file := filepath.Join(dir, filepath.Base(id))
+	if err := os.Remove(file); err != nil {
+		return err
+	}
+	for _, sidecar := range sidecarFiles(file) {
+		if err := os.Remove(sidecar); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
 }
 
 func (fs *fsStore) MarkAsFailed(id string) error {
-	return os.Rename(filepath.Join(fs.tasksDir, id), filepath.Join(fs.failuresDir, id))
+	file, failed := filepath.Join(fs.tasksDir, id), filepath.Join(fs.failuresDir, id)
+	if err := os.Rename(file, failed); err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := os.Chtimes(failed, now, now); err != nil {
+		return err
+	}
+	if err := os.Rename(metaFile(file), metaFile(failed)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// secrets are not kept once the task has run
+	if err := os.Remove(secretsFile(file)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (fs *fsStore) SaveCallbacks(tk *model.Task) error {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	file := filepath.Join(fs.tasksDir, tk.AsFilename())
+	meta, err := readMeta(file)
+	if err != nil {
+		return err
+	}
+	meta.AfterSuccess, meta.OnFailure = tk.AfterSuccess, tk.OnFailure
+	meta.AfterSuccessID, meta.OnFailureID = tk.AfterSuccessID, tk.OnFailureID
+	return writeMeta(file, meta)
+}
+
+// PurgeExpired removes failed tasks that failed before the given time and
+// pending tasks that could no longer be executed at that time. It returns
+// how many tasks were removed and the ids of the pending ones.
+func (fs *fsStore) PurgeExpired(before time.Time) (int, []string, error) {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	failed, err := fs.failedBefore(before)
+	if err != nil {
+		return 0, nil, err
+	}
+	count, err := removeFiles(failed)
+	if err != nil {
+		return count, nil, err
+	}
+
+	var expired, ids []string
+	for _, file := range glob(fs.tasksDir) {
+		tk, err := New(file)
+		if err != nil {
+			return count, nil, err
+		}
+		if tk.RunAt.Time().Add(-stillExecutable).Before(before) {
+			expired = append(expired, file)
+			ids = append(ids, filepath.Base(file))
+		}
+	}
+	removed, err := removeFiles(expired)
+	return count + removed, ids[:removed], err
+}
+
+// PurgeFailures removes failed tasks that failed before the given time.
+func (fs *fsStore) PurgeFailures(before time.Time) (int, error) {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	failed, err := fs.failedBefore(before)
+	if err != nil {
+		return 0, err
+	}
+	return removeFiles(failed)
+}
+
+// failedBefore must be called with fs.mux held.
+func (fs *fsStore) failedBefore(before time.Time) ([]string, error) {
+	var failed []string
+	for _, file := range glob(fs.failuresDir) {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		if info.ModTime().Before(before) {
+			failed = append(failed, file)
+		}
+	}
+	return failed, nil
+}
+
+// removeFiles removes the task files and their sidecars, returning how many
+// tasks were removed.
+func removeFiles(files []string) (int, error) {
+	for i, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return i, err
+		}
+		for _, sidecar := range sidecarFiles(file) {
+			if err := os.Remove(sidecar); err != nil && !os.IsNotExist(err) {
+				return i, err
+			}
+		}
+	}
+	return len(files), nil
+}
+
+// PatchTags applies the patch to the tags of a pending or failed task at once.
+func (fs *fsStore) PatchTags(id string, patch model.TagPatch) error {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	for _, dir := range []string{fs.tasksDir, fs.failuresDir} {
+		file := filepath.Join(dir, filepath.Base(id))
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		meta, err := readMeta(file)
+		if err != nil {
+			return err
+		}
+		if meta.Tags == nil {
+			meta.Tags = make(map[string]string)
+		}
+		for k, v := range patch.Add {
+			meta.Tags[k] = v
+		}
+		for _, k := range patch.Remove {
+			delete(meta.Tags, k)
+		}
+		if len(meta.Tags) == 0 {
+			meta.Tags = nil
+		}
+		return writeMeta(file, meta)
+	}
+	return os.ErrNotExist
+}
+
+// ReplaceDependency makes the pending tasks depending on oldID depend on
+// newID instead, returning their ids. A task missing from the store counts as
+// done for its dependents, so an id must be replaced before its task is
+// removed.
+func (fs *fsStore) ReplaceDependency(oldID, newID string) ([]string, error) {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	var replaced []string
+	for _, file := range glob(fs.tasksDir) {
+		meta, err := readMeta(file)
+		if err != nil {
+			return replaced, err
+		}
+		var found bool
+		for i, dep := range meta.DependsOn {
+			if dep == oldID {
+				meta.DependsOn[i], found = newID, true
+			}
+		}
+		if !found {
+			continue
+		}
+		if err = writeMeta(file, meta); err != nil {
+			return replaced, err
+		}
+		replaced = append(replaced, filepath.Base(file))
+	}
+	return replaced, nil
 }
 
 func (fs *fsStore) Cleanup() error {
 	fs.mux.Lock()
 	defer fs.mux.Unlock()
 
-	files, _ := filepath.Glob(filepath.Join(fs.root, "*", fmt.Sprintf("*.%s", model.AwlessFileExt)))
+	var files []string
+	for _, ext := range []string{model.AwlessFileExt, model.MetaFileExt, model.SecretsFileExt, model.MetaFileExt + ".tmp"} {
+		matches, _ := filepath.Glob(filepath.Join(fs.root, "*", fmt.Sprintf("*.%s", ext)))
+		files = append(files, matches...)
+	}
 	for _, file := range files {
 		err := os.Remove(file)
 		if err != nil {
@@ -129,6 +354,89 @@ func (fs *fsStore) getFailures() []string {
 	return glob(fs.failuresDir)
 }
 
+func metaFile(taskFile string) string {
+	return fmt.Sprintf("%s.%s", taskFile, model.MetaFileExt)
+}
+
+func sidecarFiles(taskFile string) []string {
+	return []string{metaFile(taskFile), secretsFile(taskFile)}
+}
+
+// taskMeta holds the fields of a task neither in its id nor in its content.
+type taskMeta struct {
+	DependsOn                   []string          `json:",omitempty"`
+	AfterSuccess, OnFailure     *model.Callback   `json:",omitempty"`
+	AfterSuccessID, OnFailureID string            `json:",omitempty"`
+	Group                       string            `json:",omitempty"`
+	ContentLanguage             string            `json:",omitempty"`
+	RevertID                    string            `json:",omitempty"`
+	Owner                       string            `json:",omitempty"`
+	CronExpr                    string            `json:",omitempty"`
+	MaxRetries                  int               `json:",omitempty"`
+	Tags                        map[string]string `json:",omitempty"`
+	Description                 string            `json:",omitempty"`
+}
+
+func metaOf(tk *model.Task) taskMeta {
+	return taskMeta{
+		DependsOn:       tk.DependsOn,
+		AfterSuccess:    tk.AfterSuccess,
+		OnFailure:       tk.OnFailure,
+		AfterSuccessID:  tk.AfterSuccessID,
+		OnFailureID:     tk.OnFailureID,
+		Group:           tk.Group,
+		ContentLanguage: tk.ContentLanguage,
+		RevertID:        tk.RevertID,
+		Owner:           tk.Owner,
+		CronExpr:        tk.CronExpr,
+		MaxRetries:      tk.MaxRetries,
+		Tags:            tk.Tags,
+		Description:     tk.Description,
+	}
+}
+
+func (m taskMeta) applyTo(tk *model.Task) {
+	tk.DependsOn = m.DependsOn
+	tk.AfterSuccess, tk.OnFailure = m.AfterSuccess, m.OnFailure
+	tk.AfterSuccessID, tk.OnFailureID = m.AfterSuccessID, m.OnFailureID
+	tk.Group, tk.ContentLanguage, tk.RevertID, tk.Owner = m.Group, m.ContentLanguage, m.RevertID, m.Owner
+	tk.CronExpr, tk.MaxRetries = m.CronExpr, m.MaxRetries
+	tk.Tags, tk.Description = m.Tags, m.Description
+}
+
+// writeMeta replaces the meta sidecar of the task at once, through a rename,
+// removing it when the task has no meta.
+func writeMeta(taskFile string, m taskMeta) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if string(b) == "{}" {
+		if err = os.Remove(metaFile(taskFile)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	tmp := metaFile(taskFile) + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return fmt.Errorf("cannot write task meta as file: %s", err)
+	}
+	return os.Rename(tmp, metaFile(taskFile))
+}
+
+func readMeta(taskFile string) (taskMeta, error) {
+	var m taskMeta
+	b, err := ioutil.ReadFile(metaFile(taskFile))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(b, &m)
+	return m, err
+}
+
 func glob(root string) []string {
 	files, err := filepath.Glob(filepath.Join(root, fmt.Sprintf("*.%s", model.AwlessFileExt)))
 	if err != nil {
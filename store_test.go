@@ -0,0 +1,129 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wallix/awless-scheduler/model"
+)
+
+func TestStoreKeepsTaskMetaInOneSidecar(t *testing.T) {
+	fs := createTmpFSStore()
+	defer fs.Destroy()
+
+	tk := &model.Task{
+		Content:         "create user name=toto",
+		RunAt:           model.FlexibleTime(time.Now().UTC().Add(time.Hour)),
+		Region:          "us-west-1",
+		DependsOn:       []string{"1", "2"},
+		OnFailure:       &model.Callback{Template: "delete user name=toto", RunIn: "1m"},
+		Group:           "deploy",
+		ContentLanguage: "awless",
+		Owner:           "alice",
+		CronExpr:        "0 * * * *",
+		MaxRetries:      2,
+		Tags:            map[string]string{"env": "prod"},
+		Description:     "users",
+	}
+	if err := fs.Create(tk); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(fs.(*fsStore).tasksDir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(files), 2; got != want {
+		t.Fatalf("got %d files %v, want %d", got, files, want)
+	}
+
+	if err = fs.PatchTags(tk.AsFilename(), model.TagPatch{Add: map[string]string{"team": "ops"}, Remove: []string{"env"}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := fs.GetTask(tk.AsFilename())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := *tk
+	want.ID, want.ContentHash, want.Status = tk.AsFilename(), model.ContentHash(tk.Content), model.StatusPending
+	want.RunAt = model.FlexibleTime(tk.RunAt.Time().Truncate(time.Second))
+	want.Tags = map[string]string{"team": "ops"}
+	if !reflect.DeepEqual(got, &want) {
+		t.Fatalf("got %+v, want %+v", got, &want)
+	}
+
+	if err = fs.PatchTags(tk.AsFilename(), model.TagPatch{Remove: []string{"team"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = fs.GetTask(tk.AsFilename()); err != nil {
+		t.Fatal(err)
+	}
+	if got.Tags != nil || got.Owner != "alice" {
+		t.Fatalf("got tags %v and owner %s", got.Tags, got.Owner)
+	}
+}
+
+func TestStoreReplaceDependency(t *testing.T) {
+	fs := createTmpFSStore()
+	defer fs.Destroy()
+
+	runAt := model.FlexibleTime(time.Now().UTC().Add(time.Hour))
+	dependency := &model.Task{Content: "create user name=toto", RunAt: runAt, Region: "us-west-1"}
+	dependent := &model.Task{Content: "attach user name=toto group=admins", RunAt: runAt, Region: "us-west-1", DependsOn: []string{dependency.AsFilename()}}
+	other := &model.Task{Content: "create group name=admins", RunAt: runAt, Region: "us-west-1"}
+	for _, tk := range []*model.Task{dependency, dependent, other} {
+		if err := fs.Create(tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replaced, err := fs.ReplaceDependency(dependency.AsFilename(), "new-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := replaced, []string{dependent.AsFilename()}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	tk, err := fs.GetTask(dependent.AsFilename())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tk.DependsOn, []string{"new-id"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestJournaledStorePurgeExpired(t *testing.T) {
+	fs := createTmpFSStore()
+	defer fs.Destroy()
+	s := &journaledStore{fs}
+
+	expired := &model.Task{Content: "create user name=toto", RunAt: model.FlexibleTime(time.Now().UTC().Add(-2 * time.Hour)), Region: "us-west-1"}
+	pending := &model.Task{Content: "create user name=tata", RunAt: model.FlexibleTime(time.Now().UTC().Add(time.Hour)), Region: "us-west-1"}
+	for _, tk := range []*model.Task{expired, pending} {
+		if err := fs.Create(tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	token := taskChanges.token()
+	count, removed, err := s.PurgeExpired(time.Now().UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := removed, []string{expired.AsFilename()}; count != 1 || !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %d removed %v, want 1 %v", count, got, want)
+	}
+	changes, _, ok := taskChanges.since(token)
+	if !ok {
+		t.Fatal("token expired")
+	}
+	if len(changes) != 1 || changes[0].ChangeType != model.ChangeDeleted || changes[0].Task.ID != expired.AsFilename() {
+		t.Fatalf("got changes %v, want deletion of %s", changes, expired.AsFilename())
+	}
+	if _, err := fs.GetTask(pending.AsFilename()); err != nil {
+		t.Fatal(err)
+	}
+}
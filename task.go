@@ -4,61 +4,123 @@ import (
 	"fmt"
 	"hash/adler32"
 	"io/ioutil"
+	"log"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/wallix/awless-scheduler/model"
+	"github.com/wallix/awless/aws/driver"
 	"github.com/wallix/awless/template"
 	"github.com/wallix/awless/template/driver"
 )
 
 func New(filePath string) (tk *model.Task, err error) {
-	tk = &model.Task{}
-
 	var content []byte
 	content, err = ioutil.ReadFile(filePath)
 	if err != nil {
 		return
 	}
-	tk.Content = string(content)
-	fileName := filepath.Base(filePath)
-	name := strings.TrimSuffix(fileName, filepath.Ext(fileName))
-	splits := strings.SplitN(name, "_", 4)
-	checksum, err := strconv.ParseUint(splits[0], 10, 32)
-	if err != nil {
+	var checksum uint32
+	if tk, checksum, err = parseTaskID(filepath.Base(filePath)); err != nil {
 		return
 	}
-	if cs := adler32.Checksum([]byte(tk.Content)); uint32(checksum) != cs {
-		err = fmt.Errorf("unexpected checksum for file %s. Exepcted %d", name, cs)
+	tk.Content = string(content)
+	tk.ContentHash = model.ContentHash(tk.Content)
+	if cs := adler32.Checksum([]byte(tk.Content)); checksum != cs {
+		err = fmt.Errorf("unexpected checksum for file %s. Exepcted %d", tk.ID, cs)
 		return
 	}
-	tk.RunAt, err = time.Parse(model.StampLayout, splits[1])
-	if err != nil {
+
+	var meta taskMeta
+	if meta, err = readMeta(filePath); err != nil {
 		return
 	}
-	tk.RevertAt, err = time.Parse(model.StampLayout, splits[2])
-	if err != nil {
+	meta.applyTo(tk)
+
+	var secrets taskSecrets
+	if secrets, err = readSecrets(filePath); err != nil {
 		return
 	}
-	tk.Region = splits[3]
-
+	tk.SecretEnv, tk.SecretEncryption = secrets.Env, secrets.Encryption
 	return
 }
 
+// taskFromID returns the task with the run time, revert time and region held
+// by its ID.
+func taskFromID(id string) (*model.Task, error) {
+	tk, _, err := parseTaskID(id)
+	return tk, err
+}
+
+func parseTaskID(id string) (*model.Task, uint32, error) {
+	name := strings.TrimSuffix(id, filepath.Ext(id))
+	splits := strings.SplitN(name, "_", 4)
+	if len(splits) != 4 {
+		return nil, 0, fmt.Errorf("invalid task id %s", id)
+	}
+	checksum, err := strconv.ParseUint(splits[0], 10, 32)
+	if err != nil {
+		return nil, 0, err
+	}
+	runAt, err := time.Parse(model.StampLayout, splits[1])
+	if err != nil {
+		return nil, 0, err
+	}
+	revertAt, err := time.Parse(model.StampLayout, splits[2])
+	if err != nil {
+		return nil, 0, err
+	}
+	tk := &model.Task{ID: id, RunAt: model.FlexibleTime(runAt), RevertAt: model.FlexibleTime(revertAt), Region: splits[3]}
+	return tk, uint32(checksum), nil
+}
+
 func executeTask(tk *model.Task, d driver.Driver, env *template.Env) (executed *template.Template, err error) {
 	defer func() {
 		id := tk.AsFilename()
+		if tk.CronExpr != "" {
+			spawnNextRun(tk)
+		}
+		if err != nil && tk.MaxRetries > 0 {
+			retryID, rerr := spawnRetry(tk)
+			if rerr == nil {
+				log.Printf("retrying task %s as %s: %s", id, retryID, err)
+				taskStore.Remove(id)
+				return
+			}
+			log.Printf("cannot retry task %s: %s", id, rerr)
+		}
 		if err != nil {
-			taskStore.MarkAsFailed(id)
+			if tk.OnFailure != nil {
+				tk.OnFailureID = spawnCallback(tk, tk.OnFailure)
+				taskStore.SaveCallbacks(tk)
+			}
+			if taskStore.MarkAsFailed(id) == nil {
+				if failed, err := taskStore.GetTask(id); err == nil {
+					deadLetters.publish(failed)
+				}
+			}
 		} else {
+			if tk.AfterSuccess != nil {
+				tk.AfterSuccessID = spawnCallback(tk, tk.AfterSuccess)
+			}
 			err = taskStore.Remove(id)
 		}
 	}()
 
 	var tpl, compiled, revertTmp *template.Template
 
+	if len(tk.SecretEnv) > 0 {
+		var secrets map[string]string
+		if secrets, err = decryptSecrets(tk.SecretEnv, tk.SecretEncryption); err != nil {
+			return
+		}
+		// secrets must not leak to the shared env of other tasks
+		env = awsdriver.DefaultTemplateEnv()
+		env.AddFillers(secretFillers(secrets))
+	}
+
 	if tpl, err = template.Parse(tk.Content); err != nil {
 		return
 	}
@@ -92,10 +154,36 @@ func executeTask(tk *model.Task, d driver.Driver, env *template.Env) (executed *
 		if revertTmp, err = executed.Revert(); err != nil {
 			return
 		}
-		revertTask := &model.Task{RunAt: tk.RevertAt, Region: tk.Region, Content: revertTmp.String()}
+		revertTask := &model.Task{RunAt: tk.RevertAt, Region: tk.Region, Content: revertTmp.String(), Group: tk.Group, ContentLanguage: tk.ContentLanguage, Owner: tk.Owner}
 		if err = taskStore.Create(revertTask); err != nil {
 			return
 		}
+		taskHistory.recordRevertTask(revertTask.AsFilename(), tk.AsFilename())
 	}
 	return
 }
+
+func spawnCallback(parent *model.Task, cb *model.Callback) string {
+	now := time.Now().UTC()
+	tk := &model.Task{Content: cb.Template, Region: cb.Region, Group: parent.Group, Owner: parent.Owner}
+	if tk.Region == "" {
+		tk.Region = parent.Region
+	}
+
+	runAt, err := getTimeParam(cb.RunIn, now)
+	if err != nil {
+		log.Printf("cannot spawn callback of task %s: %s", parent.ID, err)
+		return ""
+	}
+	revertAt, err := getTimeParam(cb.RevertIn, time.Time{})
+	if err != nil {
+		log.Printf("cannot spawn callback of task %s: %s", parent.ID, err)
+		return ""
+	}
+	tk.RunAt, tk.RevertAt = model.FlexibleTime(runAt), model.FlexibleTime(revertAt)
+	if err = taskStore.Create(tk); err != nil {
+		log.Printf("cannot spawn callback of task %s: %s", parent.ID, err)
+		return ""
+	}
+	return tk.AsFilename()
+}
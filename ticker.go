@@ -2,6 +2,8 @@ package main
 
 import (
 	"log"
+	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/wallix/awless-scheduler/model"
@@ -34,14 +36,42 @@ func (t *ticker) start() {
 					continue
 				}
 
-				evt := &event{tk: s}
+				evt := &event{tk: s, start: time.Now().UTC()}
+				atomic.AddInt32(&runningTasks, 1)
+				runningID.Store(s.ID)
 				evt.tpl, evt.err = executeTask(s, d, defaultCompileEnv)
+				runningID.Store("")
+				atomic.AddInt32(&runningTasks, -1)
+				evt.end = time.Now().UTC()
 				eventc <- evt
 			}
 		}
 	}
 }
 
+// tasks are executed one at a time by the ticker
+const maxWorkers = 1
+
+var runningTasks int32
+
+// runningID is the id of the task executed by the ticker, empty if none.
+var runningID atomic.Value
+
+// queueDepth counts the pending tasks whose execution time has come.
+func queueDepth() int {
+	tasks, err := taskStore.GetTasks()
+	if err != nil {
+		log.Println(err)
+	}
+	var depth int
+	for _, tk := range tasks {
+		if isExecutable(tk) {
+			depth++
+		}
+	}
+	return depth
+}
+
 func (t *ticker) stop() {
 	t.tick.Stop()
 }
@@ -54,7 +84,7 @@ func (t *ticker) retrieveExecutableTasks() []*model.Task {
 
 	var executables []*model.Task
 	for _, tk := range tasks {
-		if isExecutable(tk) {
+		if isExecutable(tk) && t.dependenciesDone(tk) {
 			executables = append(executables, tk)
 		}
 	}
@@ -62,8 +92,20 @@ func (t *ticker) retrieveExecutableTasks() []*model.Task {
 	return executables
 }
 
+// dependenciesDone tells whether the dependencies of the task ran, executed
+// tasks leaving the store. Tasks replaced under a new id have their
+// dependents moved to that id with store.ReplaceDependency.
+func (t *ticker) dependenciesDone(tk *model.Task) bool {
+	for _, id := range tk.DependsOn {
+		if _, err := t.store.GetTask(id); !os.IsNotExist(err) {
+			return false
+		}
+	}
+	return true
+}
+
 func isExecutable(tk *model.Task) bool {
 	now := time.Now().UTC()
 	limit := now.Add(stillExecutable)
-	return tk.RunAt.After(limit) && now.After(tk.RunAt)
+	return tk.RunAt.Time().After(limit) && now.After(tk.RunAt.Time())
 }
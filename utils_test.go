@@ -4,6 +4,7 @@ import (
 	"errors"
 	"io/ioutil"
 
+	"github.com/wallix/awless-scheduler/model"
 	"github.com/wallix/awless/logger"
 	"github.com/wallix/awless/template"
 	"github.com/wallix/awless/template/driver"
@@ -50,3 +51,16 @@ func (*failDriver) Lookup(...string) (driver.DriverFn, error) {
 }
 func (*failDriver) SetDryRun(bool)           {}
 func (*failDriver) SetLogger(*logger.Logger) {}
+
+// failCreateStore fails the creation of the tasks matching fail.
+type failCreateStore struct {
+	store
+	fail func(*model.Task) bool
+}
+
+func (s *failCreateStore) Create(tk *model.Task) error {
+	if s.fail(tk) {
+		return errors.New("mock store failure")
+	}
+	return s.store.Create(tk)
+}